@@ -0,0 +1,284 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"github.com/tvdburgt/passman/store"
+	"github.com/tvdburgt/passman/strength"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+var cmdAudit = &Command{
+	UsageLine: "audit [-f file] [-min-score n] [-max-age age] [-format format]",
+	Short:     "report weak, reused and stale passwords",
+	Long: `
+audit checks all entries in the store and reports:
+
+    empty		entries without a password
+    reused		passwords that are used by more than one entry
+    weak		passwords with an estimated strength below -min-score
+    stale		passwords that haven't changed for longer than -max-age
+    duplicate-user	entries with the same name (user) and url metadata
+
+    -min-score n
+	Minimum strength score, from 0 (too guessable) to 4 (very
+	unguessable). The default is 3.
+
+    -max-age age
+	Maximum age of passwords, e.g. "90d", "26w" or "8760h" (default
+	"365d"). Zero disables the check.
+
+    -format format
+	Output format: "text" (default) or "json".
+
+passman exits with status 1 if anything is reported, so audit can be run
+from scripts and CI jobs without decrypting an export of the store:
+
+    $ passman audit -format json -passphrase-file ~/.secret > audit.json
+	`,
+}
+
+var (
+	auditMinScore = defaultMinScore
+	auditMaxAge   = "365d"
+	auditFormat   = "text"
+)
+
+func init() {
+	cmdAudit.Run = runAudit
+	cmdAudit.Flag.IntVar(&auditMinScore, "min-score", auditMinScore, "")
+	cmdAudit.Flag.StringVar(&auditMaxAge, "max-age", auditMaxAge, "")
+	addFormatFlag(cmdAudit, &auditFormat)
+	addFileFlag(cmdAudit)
+}
+
+// Adds the -format flag for commands that write an audit report.
+func addFormatFlag(cmd *Command, format *string) {
+	cmd.Flag.StringVar(format, "format", *format, "")
+}
+
+func runAudit(cmd *Command, args []string) error {
+	maxAge, err := store.ParseInterval(auditMaxAge)
+	if err != nil {
+		return fmt.Errorf("passman audit: %s", err)
+	}
+	if auditFormat != "text" && auditFormat != "json" {
+		return fmt.Errorf("passman audit: unknown format %q", auditFormat)
+	}
+
+	s, err := openStore()
+	if err != nil {
+		return err
+	}
+	r := newAuditReport(s)
+	auditPasswords(r, s, auditMinScore)
+	auditAge(r, s, maxAge)
+	auditUsers(r, s)
+
+	if err := r.write(os.Stdout, auditFormat); err != nil {
+		return fmt.Errorf("passman audit: %s", err)
+	}
+	if len(r.Findings) > 0 {
+		return exitStatus(1)
+	}
+	return nil
+}
+
+// auditFinding is a problem with a single entry.
+type auditFinding struct {
+	Id     string `json:"id"`
+	Check  string `json:"check"`
+	Detail string `json:"detail"`
+}
+
+// auditReport holds the findings of a check of the store.
+type auditReport struct {
+	Store    string         `json:"store"`
+	Time     time.Time      `json:"time"`
+	Entries  int            `json:"entries"`
+	Findings []auditFinding `json:"findings"`
+}
+
+func newAuditReport(s *store.Store) *auditReport {
+	return &auditReport{
+		Store:    storeFile,
+		Time:     time.Now().Truncate(time.Second),
+		Entries:  len(s.Entries),
+		Findings: []auditFinding{},
+	}
+}
+
+func (r *auditReport) add(id, check, format string, args ...interface{}) {
+	r.Findings = append(r.Findings, auditFinding{id, check, fmt.Sprintf(format, args...)})
+}
+
+// write writes the report in the given format ("text" or "json"). Findings
+// are sorted by check (in order of addition) and id.
+func (r *auditReport) write(w io.Writer, format string) error {
+	order := make(map[string]int)
+	for _, f := range r.Findings {
+		if _, ok := order[f.Check]; !ok {
+			order[f.Check] = len(order)
+		}
+	}
+	sort.Stable(byCheck{r.Findings, order})
+
+	if format == "json" {
+		b, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", b)
+		return err
+	}
+
+	fmt.Fprintf(w, "Checked %d entries in '%s': %d findings.\n", r.Entries, r.Store, len(r.Findings))
+	if len(r.Findings) == 0 {
+		return nil
+	}
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "CHECK\tID\tDETAIL\n")
+	for _, f := range r.Findings {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", f.Check, f.Id, f.Detail)
+	}
+	return tw.Flush()
+}
+
+type byCheck struct {
+	findings []auditFinding
+	order    map[string]int
+}
+
+func (s byCheck) Len() int      { return len(s.findings) }
+func (s byCheck) Swap(i, j int) { s.findings[i], s.findings[j] = s.findings[j], s.findings[i] }
+func (s byCheck) Less(i, j int) bool {
+	a, b := s.findings[i], s.findings[j]
+	if a.Check != b.Check {
+		return s.order[a.Check] < s.order[b.Check]
+	}
+	return a.Id < b.Id
+}
+
+// sortedIds returns the ids of the entries in s in alphabetical order.
+func sortedIds(s *store.Store) []string {
+	ids := make([]string, 0, len(s.Entries))
+	for id := range s.Entries {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// auditPasswords reports empty, reused and weak passwords.
+func auditPasswords(r *auditReport, s *store.Store, minScore int) {
+	// Group ids by a hash of the password, so passwords aren't copied
+	users := make(map[[sha256.Size]byte][]string)
+	for _, id := range sortedIds(s) {
+		if len(s.Entries[id].Password) > 0 {
+			h := sha256.Sum256(s.Entries[id].Password)
+			users[h] = append(users[h], id)
+		}
+	}
+
+	for _, id := range sortedIds(s) {
+		if len(s.Entries[id].Password) == 0 {
+			r.add(id, "empty", "no password")
+		}
+	}
+	for _, id := range sortedIds(s) {
+		if len(s.Entries[id].Password) == 0 {
+			continue
+		}
+		ids := users[sha256.Sum256(s.Entries[id].Password)]
+		if len(ids) > 1 {
+			var others []string
+			for _, other := range ids {
+				if other != id {
+					others = append(others, other)
+				}
+			}
+			r.add(id, "reused", "same password as %s", strings.Join(others, ", "))
+		}
+	}
+	for _, id := range sortedIds(s) {
+		e := s.Entries[id]
+		if len(e.Password) == 0 {
+			continue
+		}
+		est := strength.Estimate(string(e.Password), id, e.Name)
+		if est.Score < minScore {
+			detail := fmt.Sprintf("score %d/4, cracked in %s", est.Score, est.CrackTimeString())
+			if est.Warning != "" {
+				detail += " (" + strings.TrimSuffix(est.Warning, ".") + ")"
+			}
+			r.add(id, "weak", "%s", detail)
+		}
+	}
+}
+
+// auditAge reports passwords that are older than maxAge, unless it is zero.
+func auditAge(r *auditReport, s *store.Store, maxAge time.Duration) {
+	if maxAge <= 0 {
+		return
+	}
+	for _, id := range sortedIds(s) {
+		e := s.Entries[id]
+		if age := e.Age(); age > maxAge {
+			r.add(id, "stale", "unchanged for %d days (since %s)",
+				int(age.Hours()/24), e.Mtime.Format("2006-01-02"))
+		}
+	}
+}
+
+// auditUsers reports entries that share both name and url.
+func auditUsers(r *auditReport, s *store.Store) {
+	accounts := make(map[string][]string)
+	for _, id := range sortedIds(s) {
+		e := s.Entries[id]
+		site := normalizeURL(e.Metadata["url"])
+		if e.Name == "" || site == "" {
+			continue
+		}
+		key := strings.ToLower(e.Name) + "@" + site
+		accounts[key] = append(accounts[key], id)
+	}
+	for _, id := range sortedIds(s) {
+		e := s.Entries[id]
+		key := strings.ToLower(e.Name) + "@" + normalizeURL(e.Metadata["url"])
+		if ids := accounts[key]; len(ids) > 1 {
+			var others []string
+			for _, other := range ids {
+				if other != id {
+					others = append(others, other)
+				}
+			}
+			r.add(id, "duplicate-user", "user %q on %s is also stored in %s",
+				e.Name, normalizeURL(e.Metadata["url"]), strings.Join(others, ", "))
+		}
+	}
+}
+
+// normalizeURL reduces a url to its host (and port), so different pages of
+// a site compare equal. A leading "www." is removed.
+func normalizeURL(rawurl string) string {
+	rawurl = strings.TrimSpace(rawurl)
+	if rawurl == "" {
+		return ""
+	}
+	if !strings.Contains(rawurl, "://") {
+		rawurl = "http://" + rawurl
+	}
+	u, err := url.Parse(rawurl)
+	if err != nil || u.Host == "" {
+		return strings.ToLower(rawurl)
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/tvdburgt/passman/crypto"
+	"github.com/tvdburgt/passman/store"
+	"io"
+	"os"
+	"strings"
+)
+
+var cmdBatch = &Command{
+	UsageLine: "batch [-f file] [script]",
+	Short:     "apply a script of modifications to the store",
+	Long: `
+The batch command unlocks the store once and applies the operations in the
+given script file (or stdin, if no file is given) as a single transaction. The
+store is written once after all operations have succeeded. If any operation
+fails, nothing is written and passman exits with a non-zero status. Batch mode
+never prompts for entry passwords.
+
+Each line of the script holds one operation, either as a command line or as a
+JSON object. Empty lines and lines starting with '#' are ignored.
+
+    set [-name name] [-id new_id] [-meta key=value] [-secret password]
+        [-gen method[:length]] [-policy policy] [-expires expiry]
+        [-otp uri] id
+    delete id
+    mv old_id new_id
+    meta id key=value...
+
+    {"op": "set", "id": "...", "name": "...", "password": "...",
+        "gen": "...", "policy": "...", "expires": "...", "otp": "...",
+        "new_id": "...", "meta": {"key": "value"}}
+    {"op": "delete", "id": "..."}
+    {"op": "mv", "id": "...", "to": "..."}
+    {"op": "meta", "id": "...", "meta": {"key": "value"}}
+
+A password (or generator, see 'passman help gen') is required when set
+creates a new entry. Metadata with an empty
+value is removed from the entry. The meta operation only modifies existing
+entries.
+
+For every operation, a JSON object with the result is written to stdout:
+
+    {"line": 1, "op": "set", "id": "github", "ok": true}
+    {"line": 2, "op": "delete", "id": "foo", "ok": false, "error": "no such entry \"foo\""}
+
+When the script is read from stdin, the passphrase can't be prompted for. Use
+one of the passphrase flags (-passphrase-fd, -passphrase-file or
+-passphrase-env) or $PASSMAN_PASSPHRASE_COMMAND instead.
+	`,
+}
+
+func init() {
+	cmdBatch.Run = runBatch
+	addFileFlag(cmdBatch)
+}
+
+// batchOp is a single operation of a batch script.
+type batchOp struct {
+	Op       string            `json:"op"`
+	Id       string            `json:"id"`
+	To       string            `json:"to"`
+	NewId    string            `json:"new_id"`
+	Name     string            `json:"name"`
+	Password *string           `json:"password"`
+	Gen      string            `json:"gen"`
+	Policy   string            `json:"policy"`
+	Expires  string            `json:"expires"`
+	Otp      string            `json:"otp"`
+	Meta     map[string]string `json:"meta"`
+}
+
+// batchResult reports the outcome of a batch operation.
+type batchResult struct {
+	Line  int    `json:"line"`
+	Op    string `json:"op"`
+	Id    string `json:"id,omitempty"`
+	Ok    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+func runBatch(cmd *Command, args []string) error {
+	in := os.Stdin
+	if len(args) > 0 {
+		file, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("passman batch: %s", err)
+		}
+		defer file.Close()
+		in = file
+	}
+
+	s, passphrase, err := openRwStore()
+	if err != nil {
+		return err
+	}
+	defer crypto.Clear(passphrase)
+
+	failed, err := applyBatch(s, in, os.Stdout)
+	if err != nil {
+		return fmt.Errorf("passman batch: %s", err)
+	}
+	if failed > 0 {
+		return fmt.Errorf("passman batch: %d operation(s) failed, store not modified", failed)
+	}
+	return saveStore(s, passphrase)
+}
+
+// applyBatch applies each operation read from r to s and writes the results
+// to w. It returns the number of failed operations.
+func applyBatch(s *store.Store, r io.Reader, w io.Writer) (failed int, err error) {
+	enc := json.NewEncoder(w)
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		result := batchResult{Line: n}
+		op, err := parseBatchLine(line)
+		if err == nil {
+			result.Op, result.Id = op.Op, op.Id
+			err = op.apply(s)
+		}
+		if err != nil {
+			result.Error = err.Error()
+			failed++
+		} else {
+			result.Ok = true
+		}
+		if err := enc.Encode(result); err != nil {
+			return failed, err
+		}
+	}
+	return failed, scanner.Err()
+}
+
+// parseBatchLine parses a JSON object or command line into an operation.
+func parseBatchLine(line string) (*batchOp, error) {
+	op := new(batchOp)
+	if strings.HasPrefix(line, "{") {
+		if err := json.Unmarshal([]byte(line), op); err != nil {
+			return nil, err
+		}
+		return op, nil
+	}
+
+	args, err := splitLine(line)
+	if err != nil {
+		return nil, err
+	}
+	op.Op, args = args[0], args[1:]
+
+	switch op.Op {
+	case "set":
+		o := newSetOptions()
+		fs := newShellFlagSet("set")
+		addSetFlags(fs, o)
+		secret := fs.String("secret", "", "")
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() != 1 {
+			return nil, errors.New("usage: set [options] id")
+		}
+		if o.password {
+			return nil, errors.New("-password is not supported in batch mode")
+		}
+		fs.Visit(func(f *flag.Flag) {
+			if f.Name == "secret" {
+				op.Password = secret
+			}
+		})
+		op.Id, op.Name, op.NewId, op.Meta = fs.Arg(0), o.name, o.id, o.meta
+		op.Gen, op.Policy, op.Expires, op.Otp = o.gen, o.policy, o.expires, o.otp
+	case "delete":
+		if len(args) != 1 {
+			return nil, errors.New("usage: delete id")
+		}
+		op.Id = args[0]
+	case "mv":
+		if len(args) != 2 {
+			return nil, errors.New("usage: mv old_id new_id")
+		}
+		op.Id, op.To = args[0], args[1]
+	case "meta":
+		if len(args) < 2 {
+			return nil, errors.New("usage: meta id key=value...")
+		}
+		op.Id, op.Meta = args[0], make(metadata)
+		for _, pair := range args[1:] {
+			if err := metadata(op.Meta).Set(pair); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return op, nil
+}
+
+func (op *batchOp) apply(s *store.Store) error {
+	switch op.Op {
+	case "set", "delete", "mv", "meta":
+	default:
+		return fmt.Errorf("unknown operation %q", op.Op)
+	}
+	if op.Id == "" {
+		return errors.New("missing id")
+	}
+
+	switch op.Op {
+	case "set":
+		o := newSetOptions()
+		o.readPassword = nil
+		o.name, o.id, o.gen, o.policy = op.Name, op.NewId, op.Gen, op.Policy
+		o.expires, o.otp = op.Expires, op.Otp
+		for key, val := range op.Meta {
+			o.meta[key] = val
+		}
+		if op.Password != nil {
+			o.secret = []byte(*op.Password)
+		}
+		_, err := setEntry(s, op.Id, o)
+		return err
+	case "delete":
+		return deleteEntry(s, op.Id)
+	case "mv":
+		return moveEntry(s, op.Id, op.To)
+	case "meta":
+		if _, err := getEntry(s, op.Id); err != nil {
+			return err
+		}
+		o := newSetOptions()
+		o.readPassword = nil
+		for key, val := range op.Meta {
+			o.meta[key] = val
+		}
+		_, err := setEntry(s, op.Id, o)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/tvdburgt/passman/store"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyBatchErrors(t *testing.T) {
+	tests := map[string]string{
+		`{"op":"bogus"}`:           `unknown operation "bogus"`,
+		`{"op":"bogus","id":"x"}`:  `unknown operation "bogus"`,
+		`{"op":"delete"}`:          "missing id",
+		`{"op":"mv","to":"other"}`: "missing id",
+	}
+	for line, expected := range tests {
+		var out bytes.Buffer
+		failed, err := applyBatch(store.NewStore(), strings.NewReader(line), &out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result batchResult
+		if err := json.Unmarshal(out.Bytes(), &result); err != nil {
+			t.Fatal(err)
+		}
+		if failed != 1 || result.Ok || result.Error != expected {
+			t.Errorf("%s: result %+v, expected error %q", line, result, expected)
+		}
+	}
+}
+
+// testStoreEntries returns a store with the entries a, b and c.
+func testStoreEntries() *store.Store {
+	s := store.NewStore()
+	for _, id := range []string{"a", "b", "c"} {
+		e := store.NewEntry()
+		e.Password = []byte("secret-" + id)
+		s.Entries[id] = e
+	}
+	return s
+}
+
+// runBatchScript runs 'passman batch' on a script file with the given lines,
+// discarding the results on stdout.
+func runBatchScript(t *testing.T, dir string, lines ...string) error {
+	script := filepath.Join(dir, "script")
+	if err := ioutil.WriteFile(script, []byte(strings.Join(lines, "\n")), 0600); err != nil {
+		t.Fatal(err)
+	}
+	null, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer null.Close()
+	stdout := os.Stdout
+	os.Stdout = null
+	defer func() { os.Stdout = stdout }()
+	return runBatch(cmdBatch, []string{script})
+}
+
+func TestRunBatch(t *testing.T) {
+	dir := tempStore(t, testStoreEntries())
+	err := runBatchScript(t, dir,
+		"set -secret pw -name Mail -meta user=me new",
+		"meta a url=https://example.com",
+		"mv b moved",
+		`{"op":"delete","id":"c"}`,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := readStore([]byte(testPassphrase))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := s.Ids(nil); !reflect.DeepEqual(ids, []string{"a", "moved", "new"}) {
+		t.Fatalf("ids %v", ids)
+	}
+	if e := s.Entries["new"]; string(e.Password) != "pw" || e.Name != "Mail" || e.Metadata["user"] != "me" {
+		t.Errorf("set: %+v", e)
+	}
+	if e := s.Entries["a"]; e.Metadata["url"] != "https://example.com" || string(e.Password) != "secret-a" {
+		t.Errorf("meta: %+v", e)
+	}
+	if e := s.Entries["moved"]; string(e.Password) != "secret-b" {
+		t.Errorf("mv: %+v", e)
+	}
+}
+
+// A script with a failing operation leaves the store file untouched, also if
+// the other operations succeed.
+func TestRunBatchAtomic(t *testing.T) {
+	dir := tempStore(t, testStoreEntries())
+	before, err := ioutil.ReadFile(storeFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = runBatchScript(t, dir,
+		"set -secret pw new",
+		"mv a moved",
+		"delete missing",
+		"meta b url=https://example.com",
+	)
+	if err == nil || err.Error() != "passman batch: 1 operation(s) failed, store not modified" {
+		t.Errorf("error %v", err)
+	}
+	after, err := ioutil.ReadFile(storeFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Error("store modified")
+	}
+}
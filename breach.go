@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"github.com/tvdburgt/passman/breach"
+	"os"
+)
+
+var cmdBreachCheck = &Command{
+	UsageLine: "breach-check [-f file] [-db file] [-format format] | -build-index index -db file",
+	Short:     "check passwords against a local list of breached passwords",
+	Long: `
+breach-check looks up the password of every entry in a locally downloaded
+copy of the Have I Been Pwned password list and reports the entries whose
+password appeared in a data breach, with the number of times it was seen.
+Nothing is sent over the network.
+
+Download the SHA-1 version of the list ordered by hash, e.g.
+pwned-passwords-sha1-ordered-by-hash-v8.txt, from
+https://haveibeenpwned.com/Passwords.
+
+    -db file
+	The hash list or an index built with -build-index. Defaults to
+	$PASSMAN_BREACH_DB.
+
+    -build-index index
+	Convert the hash list given with -db to a compact binary index, which
+	is about a third of its size and faster to search. The store is not
+	opened.
+
+    -format format
+	Output format: "text" (default) or "json" (see 'passman help audit').
+
+passman exits with status 1 if any breached password is found.
+	`,
+}
+
+const breachDbEnvKey = "PASSMAN_BREACH_DB"
+
+var (
+	breachDb         = os.Getenv(breachDbEnvKey)
+	breachBuildIndex = ""
+	breachFormat     = "text"
+)
+
+func init() {
+	cmdBreachCheck.Run = runBreachCheck
+	cmdBreachCheck.Flag.StringVar(&breachDb, "db", breachDb, "")
+	cmdBreachCheck.Flag.StringVar(&breachBuildIndex, "build-index", breachBuildIndex, "")
+	addFormatFlag(cmdBreachCheck, &breachFormat)
+	addFileFlag(cmdBreachCheck)
+}
+
+func runBreachCheck(cmd *Command, args []string) error {
+	if breachDb == "" {
+		return fmt.Errorf("passman breach-check: no hash list given (use -db or $%s)", breachDbEnvKey)
+	}
+	if breachBuildIndex != "" {
+		return buildBreachIndex(breachDb, breachBuildIndex)
+	}
+	if breachFormat != "text" && breachFormat != "json" {
+		return fmt.Errorf("passman breach-check: unknown format %q", breachFormat)
+	}
+
+	db, err := breach.Open(breachDb)
+	if err != nil {
+		return fmt.Errorf("passman breach-check: %s", err)
+	}
+	defer db.Close()
+
+	s, err := openStore()
+	if err != nil {
+		return err
+	}
+	r := newAuditReport(s)
+	for _, id := range sortedIds(s) {
+		e := s.Entries[id]
+		if len(e.Password) == 0 {
+			continue
+		}
+		count, err := db.LookupPassword(e.Password)
+		if err != nil {
+			return fmt.Errorf("passman breach-check: %s", err)
+		}
+		if count > 0 {
+			r.add(id, "breached", "password appeared %d times in data breaches", count)
+		}
+	}
+
+	if err := r.write(os.Stdout, breachFormat); err != nil {
+		return fmt.Errorf("passman breach-check: %s", err)
+	}
+	if len(r.Findings) > 0 {
+		return exitStatus(1)
+	}
+	return nil
+}
+
+// buildBreachIndex converts the hash list in src to an index in dst.
+func buildBreachIndex(src, dst string) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("passman breach-check: %s", err)
+	}
+	defer r.Close()
+
+	w, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("passman breach-check: %s", err)
+	}
+	n, err := breach.BuildIndex(w, r)
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		w.Close()
+		os.Remove(dst)
+		return fmt.Errorf("passman breach-check: %s", err)
+	}
+	fmt.Printf("Indexed %d hashes in '%s'.\n", n, dst)
+	return nil
+}
@@ -0,0 +1,267 @@
+// Package breach looks up passwords in a local copy of the Have I Been Pwned
+// password list (https://haveibeenpwned.com/Passwords), without any network
+// access.
+//
+// The list is the SHA-1 version ordered by hash, a text file with lines of
+// the form
+//
+//	000000005AD76BD555C1D6D771DE417A4B87E4B4:4
+//
+// Lookups binary search the file. BuildIndex converts the list to a compact
+// binary index: a table with the position of the records for every 16-bit
+// hash prefix, followed by records of the next 80 bits of the hash and the
+// count. The false positive rate of the truncated hashes is negligible.
+package breach
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// Signature of index files
+var indexMagic = []byte("PMHIBP\x00\x01")
+
+const (
+	prefixes   = 1 << 16
+	tableSize  = (prefixes + 1) * 8
+	suffixLen  = 10 // Hash bytes after the prefix stored per record
+	recordSize = suffixLen + 4
+	headerSize = 8 + tableSize // Magic and table
+	hashHexLen = 2 * sha1.Size
+)
+
+// ErrFormat is returned for files that are neither a hash list nor an index.
+var ErrFormat = errors.New("breach: not a SHA-1 hash list or index")
+
+// DB is an open hash list or index.
+type DB struct {
+	f     *os.File
+	size  int64
+	index bool
+}
+
+// Open opens a hash list or an index built by BuildIndex.
+func Open(name string) (*DB, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	db := &DB{f: f, size: fi.Size()}
+
+	magic := make([]byte, len(indexMagic))
+	if _, err := f.ReadAt(magic, 0); err == nil && bytes.Equal(magic, indexMagic) {
+		db.index = true
+	} else if db.size > 0 {
+		// Check the first line of a hash list
+		line, _, err := db.lineAt(0)
+		if err != nil || len(line) < hashHexLen+2 || line[hashHexLen] != ':' {
+			f.Close()
+			return nil, ErrFormat
+		}
+	}
+	return db, nil
+}
+
+// Close closes the underlying file.
+func (db *DB) Close() error {
+	return db.f.Close()
+}
+
+// IsIndex reports whether db is an index built by BuildIndex.
+func (db *DB) IsIndex() bool {
+	return db.index
+}
+
+// LookupPassword returns the number of times password appears in the list,
+// or zero if it doesn't.
+func (db *DB) LookupPassword(password []byte) (int, error) {
+	return db.Lookup(sha1.Sum(password))
+}
+
+// Lookup returns the count of the given SHA-1 hash in the list, or zero if
+// it doesn't appear.
+func (db *DB) Lookup(hash [sha1.Size]byte) (int, error) {
+	if db.index {
+		return db.lookupIndex(hash)
+	}
+	return db.lookupList(hash)
+}
+
+// lookupList binary searches the lines of a hash list. The invariant is that
+// the line with hash, if any, starts in [lo, hi).
+func (db *DB) lookupList(hash [sha1.Size]byte) (int, error) {
+	target := []byte(fmt.Sprintf("%X", hash[:]))
+	lo, hi := int64(0), db.size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		line, start, err := db.lineAt(mid)
+		if err == io.EOF || start >= hi {
+			hi = mid
+			continue
+		} else if err != nil {
+			return 0, err
+		}
+		if len(line) < hashHexLen+2 || line[hashHexLen] != ':' {
+			return 0, fmt.Errorf("breach: invalid line at offset %d", start)
+		}
+		switch bytes.Compare(bytes.ToUpper(line[:hashHexLen]), target) {
+		case 0:
+			return strconv.Atoi(string(line[hashHexLen+1:]))
+		case -1:
+			lo = start + int64(len(line)) + 1
+		case 1:
+			hi = mid
+		}
+	}
+	return 0, nil
+}
+
+// Maximum length of a line in a hash list
+const maxLineLen = 64
+
+// lineAt returns the first line that starts at or after off, without line
+// terminator, and its offset.
+func (db *DB) lineAt(off int64) ([]byte, int64, error) {
+	start := off
+	buf := make([]byte, maxLineLen+1)
+	if off > 0 {
+		// Skip to the start of the next line
+		n, err := db.f.ReadAt(buf, off-1)
+		i := bytes.IndexByte(buf[:n], '\n')
+		if i < 0 {
+			if err == nil {
+				err = errors.New("breach: line too long")
+			}
+			return nil, 0, err
+		}
+		start = off + int64(i)
+	}
+	if start >= db.size {
+		return nil, start, io.EOF
+	}
+	n, err := db.f.ReadAt(buf, start)
+	line := buf[:n]
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	} else if err != io.EOF {
+		return nil, 0, errors.New("breach: line too long")
+	}
+	return bytes.TrimRight(line, "\r"), start, nil
+}
+
+func (db *DB) lookupIndex(hash [sha1.Size]byte) (int, error) {
+	prefix := int64(hash[0])<<8 | int64(hash[1])
+	var bounds [16]byte
+	if _, err := db.f.ReadAt(bounds[:], int64(len(indexMagic))+prefix*8); err != nil {
+		return 0, err
+	}
+	lo := int64(binary.BigEndian.Uint64(bounds[:8]))
+	hi := int64(binary.BigEndian.Uint64(bounds[8:]))
+
+	target := hash[2 : 2+suffixLen]
+	record := make([]byte, recordSize)
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		if _, err := db.f.ReadAt(record, headerSize+mid*recordSize); err != nil {
+			return 0, err
+		}
+		switch bytes.Compare(record[:suffixLen], target) {
+		case 0:
+			return int(binary.BigEndian.Uint32(record[suffixLen:])), nil
+		case -1:
+			lo = mid + 1
+		case 1:
+			hi = mid
+		}
+	}
+	return 0, nil
+}
+
+// BuildIndex reads a hash list ordered by hash from r and writes an index to
+// w. It returns the number of hashes.
+func BuildIndex(w io.WriterAt, r io.Reader) (int, error) {
+	if _, err := w.WriteAt(indexMagic, 0); err != nil {
+		return 0, err
+	}
+
+	var counts [prefixes]uint64
+	var prev [sha1.Size]byte
+	var buf []byte
+	off := int64(headerSize)
+	n := 0
+	flush := func() error {
+		_, err := w.WriteAt(buf, off)
+		off += int64(len(buf))
+		buf = buf[:0]
+		return err
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := bytes.TrimRight(scanner.Bytes(), "\r")
+		if len(line) == 0 {
+			continue
+		}
+		if len(line) < hashHexLen+2 || line[hashHexLen] != ':' {
+			return n, fmt.Errorf("breach: invalid line %d", n+1)
+		}
+		var hash [sha1.Size]byte
+		if _, err := hex.Decode(hash[:], line[:hashHexLen]); err != nil {
+			return n, fmt.Errorf("breach: line %d: %s", n+1, err)
+		}
+		if n > 0 && bytes.Compare(hash[:], prev[:]) <= 0 {
+			return n, fmt.Errorf("breach: line %d: hashes are not in ascending order", n+1)
+		}
+		count, err := strconv.ParseUint(string(line[hashHexLen+1:]), 10, 64)
+		if err != nil {
+			return n, fmt.Errorf("breach: line %d: invalid count", n+1)
+		}
+		if count > 1<<32-1 {
+			count = 1<<32 - 1
+		}
+
+		buf = append(buf, hash[2:2+suffixLen]...)
+		buf = append(buf, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(buf[len(buf)-4:], uint32(count))
+		counts[int(hash[0])<<8|int(hash[1])]++
+		prev = hash
+		n++
+
+		if len(buf) >= 1<<16 {
+			if err := flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return n, err
+	}
+	if err := flush(); err != nil {
+		return n, err
+	}
+
+	// Table of the first record of each prefix
+	table := make([]byte, tableSize)
+	var total uint64
+	for p := 0; p <= prefixes; p++ {
+		binary.BigEndian.PutUint64(table[p*8:], total)
+		if p < prefixes {
+			total += counts[p]
+		}
+	}
+	_, err := w.WriteAt(table, int64(len(indexMagic)))
+	return n, err
+}
@@ -0,0 +1,130 @@
+package breach
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// writeList writes a hash list of n passwords ("password0", ...) with count
+// i+1 for password i, plus the lowest and highest possible hash.
+func writeList(t *testing.T, dir string, n int, eol string) string {
+	lines := []string{
+		strings.Repeat("0", hashHexLen) + ":7",
+		strings.Repeat("F", hashHexLen) + ":8",
+	}
+	for i := 0; i < n; i++ {
+		lines = append(lines, fmt.Sprintf("%X:%d", sha1.Sum([]byte(fmt.Sprint("password", i))), i+1))
+	}
+	sort.Strings(lines)
+	name := filepath.Join(dir, "pwned"+fmt.Sprint(len(eol))+".txt")
+	if err := ioutil.WriteFile(name, []byte(strings.Join(lines, eol)+eol), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func checkLookups(t *testing.T, db *DB, n int) {
+	for i := 0; i < n; i++ {
+		count, err := db.LookupPassword([]byte(fmt.Sprint("password", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != i+1 {
+			t.Errorf("password%d: count %d, expected %d", i, count, i+1)
+		}
+	}
+	for _, p := range []string{"", "password", "correct horse battery staple"} {
+		if count, err := db.LookupPassword([]byte(p)); err != nil || count != 0 {
+			t.Errorf("%q: count %d, error %v", p, count, err)
+		}
+	}
+	var lowest, highest [sha1.Size]byte
+	copy(highest[:], bytes.Repeat([]byte{0xff}, sha1.Size))
+	if count, _ := db.Lookup(lowest); count != 7 {
+		t.Errorf("lowest hash: count %d, expected 7", count)
+	}
+	if count, _ := db.Lookup(highest); count != 8 {
+		t.Errorf("highest hash: count %d, expected 8", count)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "breach")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	const n = 500
+	for _, eol := range []string{"\n", "\r\n"} {
+		list := writeList(t, dir, n, eol)
+		db, err := Open(list)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if db.IsIndex() {
+			t.Error("hash list opened as index")
+		}
+		checkLookups(t, db, n)
+		db.Close()
+
+		// Build and check the index
+		r, err := os.Open(list)
+		if err != nil {
+			t.Fatal(err)
+		}
+		index := list + ".idx"
+		w, err := os.Create(index)
+		if err != nil {
+			t.Fatal(err)
+		}
+		count, err := BuildIndex(w, r)
+		r.Close()
+		w.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != n+2 {
+			t.Errorf("BuildIndex indexed %d hashes, expected %d", count, n+2)
+		}
+		if db, err = Open(index); err != nil {
+			t.Fatal(err)
+		}
+		if !db.IsIndex() {
+			t.Error("index opened as hash list")
+		}
+		checkLookups(t, db, n)
+		db.Close()
+	}
+}
+
+func TestBuildIndexErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "breach")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	lists := []string{
+		"not a hash\n",
+		strings.Repeat("B", hashHexLen) + ":1\n" + strings.Repeat("A", hashHexLen) + ":1\n",
+		strings.Repeat("A", hashHexLen) + ":x\n",
+	}
+	for _, list := range lists {
+		w, err := os.Create(filepath.Join(dir, "index"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := BuildIndex(w, strings.NewReader(list)); err == nil {
+			t.Errorf("BuildIndex succeeded for %q", list)
+		}
+		w.Close()
+	}
+}
@@ -1,18 +1,43 @@
 package cache
 
 import (
+	"crypto/sha256"
 	"fmt"
+	"io"
 	"net/rpc"
+	"os"
+	"path/filepath"
+	"syscall"
 )
 
 const (
-	Address = "/tmp/passman.sock" // Socket path
-	Network = "unix"              // Unix domain socket
-	ChecksumSize = 256
+	Network      = "unix" // Unix domain socket
+	ChecksumSize = sha256.Size
 )
 
+// Address returns the path of the socket of the agent: passman.sock in
+// $XDG_RUNTIME_DIR or, if unset, a socket of the current user in the
+// temporary directory.
+func Address() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "passman.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("passman-%d.sock", os.Getuid()))
+}
+
+// Checksum identifies a store file by the SHA-256 hash of its contents.
 type Checksum [ChecksumSize]byte
 
+// Sum returns the checksum of the store file read from r.
+func Sum(r io.Reader) (sum Checksum, err error) {
+	h := sha256.New()
+	if _, err = io.Copy(h, r); err != nil {
+		return
+	}
+	copy(sum[:], h.Sum(nil))
+	return
+}
+
 type SumKeyPair struct {
 	Sum Checksum
 	Key []byte
@@ -36,27 +61,42 @@ func (c Cache) SetKey(pair SumKeyPair, reply *bool) error {
 	return nil
 }
 
-func CacheKey(key []byte) error {
-	client, err := rpc.Dial(Network, Address)
+// dial connects to the agent. Keys are only exchanged with an agent whose
+// socket is owned by the current user.
+func dial() (*rpc.Client, error) {
+	addr := Address()
+	fi, err := os.Lstat(addr)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	var sum Checksum
-	var ok bool
-	fmt.Printf("caching key (key=%s keyp=%p)\n", key, &key)
-	client.Call("Cache.SetKey", SumKeyPair{sum, key}, &ok)
-	fmt.Println("call completed")
-	return nil
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if fi.Mode()&os.ModeSocket == 0 || !ok || int(st.Uid) != os.Getuid() {
+		return nil, fmt.Errorf("%s is not a socket of the current user", addr)
+	}
+	return rpc.Dial(Network, addr)
 }
 
-func GetKey() (reply *KeyReply, err error) {
-	reply = new(KeyReply)
-	client, err := rpc.Dial(Network, Address)
+// CacheKey hands the key of the store with checksum sum to the agent.
+func CacheKey(sum Checksum, key []byte) error {
+	client, err := dial()
 	if err != nil {
-		return
+		return err
 	}
+	defer client.Close()
+	var replaced bool
+	return client.Call("Cache.SetKey", SumKeyPair{sum, key}, &replaced)
+}
 
-	var sum Checksum
-	client.Call("Cache.RequestKey", sum, reply)
-	return
+// GetKey requests the key of the store with checksum sum from the agent.
+func GetKey(sum Checksum) (*KeyReply, error) {
+	client, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	reply := new(KeyReply)
+	if err := client.Call("Cache.RequestKey", sum, reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
 }
@@ -2,8 +2,10 @@ package main
 
 import (
 	"errors"
+	"flag"
 	"fmt"
 	"github.com/tvdburgt/passman/clipboard"
+	"github.com/tvdburgt/passman/crypto"
 	"github.com/tvdburgt/passman/store"
 	"os"
 	"os/signal"
@@ -26,8 +28,10 @@ Available flags:
 	multiple fields are supplied, the associated values are copied
 	consecutively after each selection request (in the same order as they
 	are provided). Possible fields are "password", "name" and any of the
-	metadata keys that are set for that particular entry. The default value
-	is "password". Multiple values are separated with a comma (without
+	metadata keys that are set for that particular entry. The field "otp"
+	holds the current one-time password of entries with a one-time
+	password key (see 'passman help otp'). The default value is
+	"password". Multiple values are separated with a comma (without
 	spaces). If a metadata field is provided that collides with another
 	field, the non-metadata field will be used.
 
@@ -69,40 +73,105 @@ func printMessage(s string, args ...interface{}) {
 	currentMessage = s
 }
 
-var (
-	clipTimeout = 20 * time.Second
-	clipPersist = false
-	clipFields  = fieldSlice{"password"}
-)
+// clipOptions holds the settings of 'passman clip'.
+type clipOptions struct {
+	timeout time.Duration
+	persist bool
+	fields  fieldSlice
+
+	// save, if non-nil, is called when the entry is modified by clipping
+	// the one-time password of an HOTP key (which advances its counter).
+	save func() error
+}
+
+var clipOpts = newClipOptions()
+
+func newClipOptions() *clipOptions {
+	return &clipOptions{
+		timeout: 20 * time.Second,
+		fields:  fieldSlice{"password"},
+	}
+}
 
 func init() {
 	cmdClip.Run = runClip
-	cmdClip.Flag.DurationVar(&clipTimeout, "timeout", clipTimeout, "")
-	cmdClip.Flag.BoolVar(&clipPersist, "persist", clipPersist, "")
-	cmdClip.Flag.Var(&clipFields, "fields", "")
+	addClipFlags(&cmdClip.Flag, clipOpts)
 	addFileFlag(cmdClip)
 }
 
-func runClip(cmd *Command, args []string) {
+// addClipFlags defines the flags of 'passman clip' on fs, storing their
+// values in o.
+func addClipFlags(fs *flag.FlagSet, o *clipOptions) {
+	fs.DurationVar(&o.timeout, "timeout", o.timeout, "")
+	fs.BoolVar(&o.persist, "persist", o.persist, "")
+	fs.Var(&o.fields, "fields", "")
+}
+
+func runClip(cmd *Command, args []string) error {
 	if len(args) < 1 {
-		cmd.Usage()
+		return errUsage
 	}
 	id := args[0]
 
-	s := openStore()
-	e, ok := s.Entries[id]
-	if !ok {
-		fatalf("Entry %q does not exist.", id)
+	s, passphrase, err := openRwStore()
+	if err != nil {
+		return err
+	}
+	defer crypto.Clear(passphrase)
+	e, err := getEntry(s, id)
+	if err != nil {
+		return err
+	}
+	clipOpts.save = func() error {
+		return saveStore(s, passphrase)
 	}
 
-	if err := clipboard.Setup(); err != nil {
-		fatalf("Clipboard error: %s", err)
+	if err := clipEntry(e, clipOpts); err != errClipTimeout {
+		return err
 	}
+	return nil
+}
+
+// errClipTimeout is returned by clipEntry if the timeout is reached before
+// all fields are delivered.
+var errClipTimeout = errors.New("reached timeout")
 
-	// Call getValue for each field to trigger possible fatal errors for
-	// invalid fields.
-	for _, f := range clipFields {
-		getValue(e, f)
+// Set once the X connection for clipboard requests has been established.
+var clipReady bool
+
+// clipEntry makes the values of the entry fields in o consecutively
+// available for clipboard requests.
+func clipEntry(e *store.Entry, o *clipOptions) error {
+	// Call getValue for each field to trigger possible errors for invalid
+	// fields.
+	values := make([][]byte, len(o.fields))
+	modified := false
+	for i, f := range o.fields {
+		if f == "otp" && e.OTP != nil {
+			code, changed, err := otpCode(e)
+			if err != nil {
+				return err
+			}
+			values[i], modified = []byte(code), modified || changed
+			continue
+		}
+		value, err := getValue(e, f)
+		if err != nil {
+			return err
+		}
+		values[i] = value
+	}
+	if modified && o.save != nil {
+		if err := o.save(); err != nil {
+			return err
+		}
+	}
+
+	if !clipReady {
+		if err := clipboard.Setup(); err != nil {
+			return fmt.Errorf("Clipboard error: %s", err)
+		}
+		clipReady = true
 	}
 
 	// Disallow -persist flag if more than one field is provided
@@ -115,71 +184,80 @@ func runClip(cmd *Command, args []string) {
 
 	// Only set timeout/ticker if timeout duration is positive
 	var timeout, tick <-chan time.Time
-	if clipTimeout > 0 {
-		timeout = time.After(clipTimeout)
-		tick = time.Tick(time.Second)
-		printMessage("Closing in %s...", clipTimeout)
+	remaining := o.timeout
+	if remaining > 0 {
+		timeout = time.After(remaining)
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		tick = ticker.C
+		printMessage("Closing in %s...", remaining)
 	}
 
 	sig := make(chan os.Signal, 1)
 	signal.Notify(sig, os.Interrupt)
+	defer signal.Stop(sig)
 
-	for _, field := range clipFields {
-		value := getValue(e, field)
-		clipValue(field, value, timeout, tick, sig)
+	for i, field := range o.fields {
+		err := clipValue(field, values[i], o.persist, &remaining, timeout, tick, sig)
+		if err != nil {
+			return err
+		}
 	}
 
 	fmt.Println("All field values are copied. Exiting.")
+	return nil
 }
 
-func clipValue(field string, value []byte, timeout, tick <-chan time.Time,
-	sig <-chan os.Signal) {
+func clipValue(field string, value []byte, persist bool, remaining *time.Duration,
+	timeout, tick <-chan time.Time, sig <-chan os.Signal) error {
 
 	request, err := clipboard.Put(value)
+	defer clipboard.Clear()
 	for {
 		select {
 		case name := <-request:
 			printMessage("Field %q requested by %q\n", field, name)
-			if !clipPersist {
-				clipboard.Clear()
-				return
+			if !persist {
+				return nil
 			}
 		case e := <-err:
-			printMessage("Clipboard error: %s\n", e)
-			os.Exit(1)
+			printMessage("\n")
+			return fmt.Errorf("Clipboard error: %s", e)
 		case s := <-sig:
-			printMessage("Received %s signal. Exiting.\n", s)
-			os.Exit(1)
+			printMessage("\n")
+			return fmt.Errorf("Received %s signal. Exiting.", s)
 		case <-tick:
-			clipTimeout -= time.Second
-			printMessage("Closing in %s...", clipTimeout)
+			*remaining -= time.Second
+			printMessage("Closing in %s...", *remaining)
 		case <-timeout:
 			printMessage("Reached timeout. Exiting.\n")
-			os.Exit(0)
+			return errClipTimeout
 		}
 	}
 }
 
 func validFields(e *store.Entry) []string {
 	fields := []string{`"password"`, `"name"`}
+	if e.OTP != nil {
+		fields = append(fields, `"otp"`)
+	}
 	for key, _ := range e.Metadata {
 		fields = append(fields, fmt.Sprintf("%q", key))
 	}
 	return fields
 }
 
-func getValue(e *store.Entry, field string) []byte {
+func getValue(e *store.Entry, field string) ([]byte, error) {
 	switch field {
 	case "password":
-		return e.Password
+		return e.Password, nil
 	case "name":
-		return []byte(e.Name)
+		return []byte(e.Name), nil
 	default:
 		if value, ok := e.Metadata[field]; ok {
-			return []byte(value)
+			return []byte(value), nil
 		}
-		fatalf("Invalid field %q (possible fields: %s)",
+		return nil, fmt.Errorf("Invalid field %q (possible fields: %s)",
 			field, strings.Join(validFields(e), ", "))
 	}
-	return nil
 }
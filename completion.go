@@ -0,0 +1,283 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/tvdburgt/passman/cache"
+	"github.com/tvdburgt/passman/crypto"
+	"io"
+	"os"
+	"strings"
+)
+
+var cmdCompletion = &Command{
+	UsageLine: "completion bash|zsh|fish",
+	Short:     "generate shell completion scripts",
+	Long: `
+The completion command writes a completion script for the given shell to
+stdout. The script completes subcommand names, the flags of each subcommand
+and, where a subcommand expects one, entry ids. For example:
+
+    $ passman completion bash > /etc/bash_completion.d/passman
+    $ passman completion zsh > "${fpath[1]}/_passman"
+    $ passman completion fish > ~/.config/fish/completions/passman.fish
+
+Entry ids can only be listed while the store is unlocked, since completing
+must never prompt for a passphrase. Ids are therefore completed only if the
+passman cache agent (passman-cache) is running: every command that unlocks
+or writes a store hands the passphrase of the store to the agent, which holds
+it by the checksum of the store file. Otherwise, no ids are suggested.
+
+The ids are obtained with 'passman completion ids', which prints one id per
+line (or nothing at all). If the command line being completed selects a store
+with -f, the ids of that store are listed.
+	`,
+}
+
+func init() {
+	cmdCompletion.Run = runCompletion
+	addFileFlag(cmdCompletion)
+}
+
+var completionScripts = map[string]func(w io.Writer){
+	"bash": writeBashCompletion,
+	"zsh":  writeZshCompletion,
+	"fish": writeFishCompletion,
+}
+
+func runCompletion(cmd *Command, args []string) error {
+	if len(args) < 1 {
+		return errUsage
+	}
+	if args[0] == "ids" {
+		for _, id := range cachedIds() {
+			fmt.Println(id)
+		}
+		return nil
+	}
+	write, ok := completionScripts[args[0]]
+	if !ok {
+		return fmt.Errorf("passman completion: unsupported shell %q", args[0])
+	}
+	write(os.Stdout)
+	return nil
+}
+
+// cachedIds returns the entry ids of the store, decrypted with the
+// passphrase held by the cache agent. It returns nil if the agent is not
+// running, has no passphrase or if the store can't be read.
+func cachedIds() []string {
+	sum, err := storeSum()
+	if err != nil {
+		return nil
+	}
+	reply, err := cache.GetKey(sum)
+	if err != nil || !reply.Available {
+		return nil
+	}
+	defer crypto.Clear(reply.Key)
+	s, err := readStore(reply.Key)
+	if err != nil {
+		return nil
+	}
+	return s.Ids(nil)
+}
+
+// takesId reports whether the command expects an entry id argument, based
+// on its usage line (e.g., "get entry_id" or "set [options] <id>").
+func (c *Command) takesId() bool {
+	for _, arg := range strings.Fields(c.UsageLine)[1:] {
+		if !strings.HasPrefix(arg, "[") && strings.Contains(arg, "id") {
+			return true
+		}
+	}
+	return false
+}
+
+// flagNames returns the names of the command's flags, prefixed with '-'.
+func (c *Command) flagNames() []string {
+	var names []string
+	c.Flag.VisitAll(func(f *flag.Flag) {
+		names = append(names, "-"+f.Name)
+	})
+	return names
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface {
+		IsBoolFlag() bool
+	})
+	return ok && b.IsBoolFlag()
+}
+
+func commandNames() []string {
+	var names []string
+	for _, cmd := range commands {
+		names = append(names, cmd.Name())
+	}
+	return names
+}
+
+func writeBashCompletion(w io.Writer) {
+	fmt.Fprintf(w, `# bash completion for passman
+# _passman_ids completes the ids of the store given with -f, if any.
+_passman_ids() {
+	local i file=()
+	for (( i = 2; i < COMP_CWORD - 1; i++ )); do
+		case "${COMP_WORDS[i]}" in
+		-f|-file|--f|--file)
+			file=(-f "${COMP_WORDS[i+1]/#\~/$HOME}")
+			;;
+		esac
+	done
+	COMPREPLY=( $(compgen -W "$(passman completion "${file[@]}" ids 2>/dev/null)" -- "$1") )
+}
+
+_passman() {
+	local cur="${COMP_WORDS[COMP_CWORD]}"
+	local prev="${COMP_WORDS[COMP_CWORD-1]}"
+	if [ "$COMP_CWORD" -eq 1 ]; then
+		COMPREPLY=( $(compgen -W "%s" -- "$cur") )
+		return
+	fi
+	case "$prev" in
+	-f|-file)
+		COMPREPLY=( $(compgen -f -- "$cur") )
+		return
+		;;
+	esac
+	case "${COMP_WORDS[1]}" in
+`, strings.Join(commandNames(), " "))
+
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "\t%s)\n", cmd.Name())
+		fmt.Fprintf(w, "\t\tif [[ \"$cur\" == -* ]]; then\n")
+		fmt.Fprintf(w, "\t\t\tCOMPREPLY=( $(compgen -W %q -- \"$cur\") )\n",
+			strings.Join(cmd.flagNames(), " "))
+		if cmd.takesId() {
+			fmt.Fprintf(w, "\t\telse\n\t\t\t_passman_ids \"$cur\"\n")
+		}
+		fmt.Fprintf(w, "\t\tfi\n\t\t;;\n")
+	}
+
+	fmt.Fprint(w, `	esac
+}
+
+complete -F _passman passman
+`)
+}
+
+func writeZshCompletion(w io.Writer) {
+	fmt.Fprint(w, `#compdef passman
+
+# _passman_ids completes the ids of the store given with -f, if any.
+_passman_ids() {
+	local -a ids file
+	local i
+	for (( i = 3; i < CURRENT - 1; i++ )); do
+		case $words[i] in
+		-f|-file|--f|--file)
+			file=(-f "${words[i+1]/#\~/$HOME}")
+			;;
+		esac
+	done
+	ids=(${(f)"$(passman completion $file ids 2>/dev/null)"})
+	compadd -a ids
+}
+
+_passman() {
+	local -a commands
+	commands=(
+`)
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "\t\t%s\n", zshQuote(cmd.Name()+":"+cmd.Short))
+	}
+	fmt.Fprint(w, `	)
+	if (( CURRENT == 2 )); then
+		_describe -t commands 'passman command' commands
+		return
+	fi
+	case $words[2] in
+`)
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "\t%s)\n\t\t_arguments", cmd.Name())
+		cmd.Flag.VisitAll(func(f *flag.Flag) {
+			spec := "-" + f.Name
+			switch {
+			case isBoolFlag(f):
+			case f.Name == "f" || f.Name == "file":
+				spec += ":file:_files"
+			default:
+				spec += ":" + f.Name + ":"
+			}
+			fmt.Fprintf(w, " \\\n\t\t\t%s", zshQuote(spec))
+		})
+		if cmd.takesId() {
+			fmt.Fprintf(w, " \\\n\t\t\t'*:entry id:_passman_ids'")
+		}
+		fmt.Fprint(w, "\n\t\t;;\n")
+	}
+	fmt.Fprint(w, `	esac
+}
+
+# Autoloaded from $fpath, the file's contents are the body of _passman;
+# when sourced, the function is registered instead.
+if [ "$funcstack[1]" = "_passman" ]; then
+	_passman "$@"
+else
+	compdef _passman passman
+fi
+`)
+}
+
+func writeFishCompletion(w io.Writer) {
+	fmt.Fprintln(w, "# fish completion for passman")
+	fmt.Fprintln(w, "complete -c passman -f")
+	fmt.Fprint(w, `
+# __passman_ids lists the ids of the store given with -f, if any.
+function __passman_ids
+	set -l tokens (commandline -opc)
+	set -l file
+	for i in (seq 3 (math (count $tokens) - 1))
+		switch $tokens[$i]
+			case -f -file --f --file
+				set file -f (string replace -r '^~' $HOME -- $tokens[(math $i + 1)])
+		end
+	end
+	passman completion $file ids 2>/dev/null
+end
+
+`)
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "complete -c passman -n __fish_use_subcommand -a %s -d %s\n",
+			cmd.Name(), fishQuote(cmd.Short))
+	}
+	for _, cmd := range commands {
+		cond := fishQuote("__fish_seen_subcommand_from " + cmd.Name())
+		cmd.Flag.VisitAll(func(f *flag.Flag) {
+			fmt.Fprintf(w, "complete -c passman -n %s -o %s", cond, f.Name)
+			switch {
+			case isBoolFlag(f):
+			case f.Name == "f" || f.Name == "file":
+				fmt.Fprint(w, " -r -F")
+			default:
+				fmt.Fprint(w, " -r")
+			}
+			fmt.Fprintln(w)
+		})
+		if cmd.takesId() {
+			fmt.Fprintf(w, "complete -c passman -n %s -a %s\n",
+				cond, fishQuote("(__passman_ids)"))
+		}
+	}
+}
+
+// zshQuote and fishQuote return s as a single-quoted shell word.
+func zshQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+func fishQuote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	return "'" + strings.Replace(s, "'", `\'`, -1) + "'"
+}
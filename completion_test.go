@@ -0,0 +1,70 @@
+package main
+
+import (
+	"github.com/tvdburgt/passman/cache"
+	"github.com/tvdburgt/passman/crypto"
+	"github.com/tvdburgt/passman/store"
+	"io/ioutil"
+	"net"
+	"net/rpc"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Ids are completed from a store once it has been unlocked while the cache
+// agent is running, also after the store has been written again.
+func TestCachedIds(t *testing.T) {
+	s := store.NewStore()
+	s.Entries["github"] = store.NewEntry()
+	s.Entries["work/mail"] = store.NewEntry()
+	dir := tempStore(t, s)
+	if ids := cachedIds(); ids != nil {
+		t.Errorf("completed %v without agent", ids)
+	}
+
+	l, err := net.Listen(cache.Network, cache.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	server := rpc.NewServer()
+	server.Register(make(cache.Cache))
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go server.ServeConn(conn)
+		}
+	}()
+
+	if ids := cachedIds(); ids != nil {
+		t.Errorf("completed %v from a locked store", ids)
+	}
+	s, p, err := openRwStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer crypto.Clear(p)
+	if ids := cachedIds(); !reflect.DeepEqual(ids, []string{"github", "work/mail"}) {
+		t.Errorf("completed %v after unlocking", ids)
+	}
+
+	delete(s.Entries, "github")
+	if err := saveStore(s, p); err != nil {
+		t.Fatal(err)
+	}
+	if ids := cachedIds(); !reflect.DeepEqual(ids, []string{"work/mail"}) {
+		t.Errorf("completed %v after writing", ids)
+	}
+
+	storeFile = filepath.Join(dir, "other")
+	if err := ioutil.WriteFile(storeFile, []byte("not a store"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if ids := cachedIds(); ids != nil {
+		t.Errorf("completed %v from another store", ids)
+	}
+}
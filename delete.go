@@ -1,8 +1,10 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"github.com/tvdburgt/passman/crypto"
+	"github.com/tvdburgt/passman/store"
 )
 
 var cmdDelete = &Command{
@@ -15,22 +17,38 @@ exact match. To search or display multiple entries, see passman list.
 	`,
 }
 
-func runDelete(cmd *Command, args []string) {
+func init() {
+	addFileFlag(cmdDelete)
+}
+
+func runDelete(cmd *Command, args []string) error {
 	if len(args) < 1 {
-		fatalf("passman delete: missing identifier")
+		return errors.New("passman delete: missing identifier")
 	}
 	id := args[0]
 
-	s, passphrase := openRwStore()
+	s, passphrase, err := openRwStore()
+	if err != nil {
+		return err
+	}
 	defer crypto.Clear(passphrase)
 
+	if err := deleteEntry(s, id); err != nil {
+		return fmt.Errorf("passman delete: %s", err)
+	}
+
+	if err := saveStore(s, passphrase); err != nil {
+		return err
+	}
+	fmt.Printf("Removed entry %q from store\n", id)
+	return nil
+}
+
+func deleteEntry(s *store.Store, id string) error {
 	// Check existance before deleting
 	if _, ok := s.Entries[id]; !ok {
-		fatalf("passman delete: no such entry %q", id)
+		return fmt.Errorf("no such entry %q", id)
 	}
-
 	delete(s.Entries, id)
-
-	writeStore(s, passphrase)
-	fmt.Printf("Removed entry %q from store\n", id)
+	return nil
 }
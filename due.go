@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"github.com/tvdburgt/passman/store"
+	"os"
+)
+
+var cmdDue = &Command{
+	UsageLine: "due [-f file] [-within interval] [-format format]",
+	Short:     "report passwords that are due for rotation",
+	Long: `
+due reports the entries whose password has expired (overdue) or expires
+within the given interval (expiring). Expiry dates and rotation intervals are
+set with 'passman set -expires'.
+
+    -within interval
+	Also report passwords that expire within the interval, e.g. "14d"
+	(default) or "2w". Zero only reports overdue passwords.
+
+    -format format
+	Output format: "text" (default) or "json" (see 'passman help audit').
+
+passman exits with status 1 if any password is overdue, so due can be run
+from a login shell or a systemd timer:
+
+    passman due -passphrase-file ~/.secret || notify-send "Passwords are due"
+	`,
+}
+
+var (
+	dueWithin = "14d"
+	dueFormat = "text"
+)
+
+func init() {
+	cmdDue.Run = runDue
+	cmdDue.Flag.StringVar(&dueWithin, "within", dueWithin, "")
+	addFormatFlag(cmdDue, &dueFormat)
+	addFileFlag(cmdDue)
+}
+
+func runDue(cmd *Command, args []string) error {
+	within, err := store.ParseInterval(dueWithin)
+	if err != nil {
+		return fmt.Errorf("passman due: %s", err)
+	}
+	if dueFormat != "text" && dueFormat != "json" {
+		return fmt.Errorf("passman due: unknown format %q", dueFormat)
+	}
+
+	s, err := openStore()
+	if err != nil {
+		return err
+	}
+	r := newAuditReport(s)
+	overdue := false
+	for _, id := range sortedIds(s) {
+		e := s.Entries[id]
+		if t, ok := e.ExpiresAt(); ok && e.Expired() {
+			r.add(id, "overdue", "expired on %s", t.Format("2006-01-02"))
+			overdue = true
+		}
+	}
+	for _, id := range sortedIds(s) {
+		e := s.Entries[id]
+		if t, ok := e.ExpiresAt(); ok && !e.Expired() && e.ExpiresWithin(within) {
+			r.add(id, "expiring", "expires on %s", t.Format("2006-01-02"))
+		}
+	}
+
+	if err := r.write(os.Stdout, dueFormat); err != nil {
+		return fmt.Errorf("passman due: %s", err)
+	}
+	if overdue {
+		return exitStatus(1)
+	}
+	return nil
+}
@@ -1,56 +1,160 @@
 package main
 
 import (
-	"encoding/json"
+	"bytes"
+	"errors"
 	"fmt"
+	"github.com/tvdburgt/passman/crypto"
+	"github.com/tvdburgt/passman/import"
+	"github.com/tvdburgt/passman/import/csv"
+	"github.com/tvdburgt/passman/import/keepass2"
+	"github.com/tvdburgt/passman/kdbx"
+	"github.com/tvdburgt/passman/store"
+	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 )
 
 var cmdExport = &Command{
-	UsageLine: "export file",
+	UsageLine: "export [-f file] [-format format] [-pattern regex] [-redact] [-encrypt] [-map mapping] [-keyfile file] [-min-score n] [file]",
 	Short:     "export passman store",
 	Long: `
 JSON-formatted, defaults to stdout.
+
+	-format format
+	The following formats are available:
+		- passman (JSON, read by 'passman import', default)
+		- keepass2-xml (KeePass 2.x XML, for KeePass' and KeePassXC's
+		  XML import)
+		- kdbx (KeePass 2 database, version 4; requires an output file)
+		- csv (columns id, name, password, otp, ctime, mtime and a
+		  column per metadata key)
+		- bitwarden-csv, lastpass-csv, chrome-csv, firefox-csv,
+		  1password-csv (CSV in the format of the respective
+		  application)
+	For KeePass, ids are split at slashes into groups, e.g. "work/github"
+	becomes the entry "github" in the group "work". Metadata become custom
+	fields.
+
+	-pattern regex
+	Only export the entries whose id matches regex, e.g. '^team/infra/'.
+
+	-redact
+	Leave out the secrets of the entries: their passwords, one-time
+	password keys and all metadata except the URLs and user names (keys
+	url, username, user, login and email, in any case and with the
+	suffixes of duplicate keys, e.g. url-2). Ids, names, times, expiry
+	and password policy are kept.
+
+	-encrypt
+	Write the entries as a new passman store, protected by its own
+	passphrase (which is prompted for), instead of plaintext. The file can
+	be used with -f or $PASSMAN_STORE directly. Requires an output file
+	and the passman format.
+
+	-map mapping
+	Columns of CSV exports, see 'passman help import'.
+
+	-keyfile file
+	Key file that protects the kdbx database, in addition to its password,
+	which is prompted for.
+
+	-min-score n
+	Minimum estimated strength of the kdbx password or the passphrase of
+	the encrypted store (see 'passman help init').
 	`,
 }
 
+const kdbxDatabaseName = "passman"
+
+var (
+	exportFormat  = "passman"
+	exportKeyFile = ""
+	exportMap     = ""
+	exportPattern = ""
+	exportRedact  = false
+	exportEncrypt = false
+)
+
 func init() {
 	cmdExport.Run = runExport
+	cmdExport.Flag.StringVar(&exportFormat, "format", exportFormat, "")
+	cmdExport.Flag.StringVar(&exportKeyFile, "keyfile", exportKeyFile, "")
+	cmdExport.Flag.StringVar(&exportMap, "map", exportMap, "")
+	cmdExport.Flag.StringVar(&exportPattern, "pattern", exportPattern, "")
+	cmdExport.Flag.BoolVar(&exportRedact, "redact", exportRedact, "")
+	cmdExport.Flag.BoolVar(&exportEncrypt, "encrypt", exportEncrypt, "")
+	addFileFlag(cmdExport)
+	addMinScoreFlag(cmdExport)
 	// cmdExport.Flag.StringVar(&exportOutput, "o", "", "")
 	// cmdExport.Flag.StringVar(&exportOutput, "output", "", "")
 }
 
 // Writes a JSON-formatted output of the password store to stdout or file.
-func runExport(cmd *Command, args []string) {
+func runExport(cmd *Command, args []string) error {
 	var err error
 	var out *os.File = os.Stdout
 
-	s := openStore()
+	switch exportFormat {
+	case "passman", "keepass2-xml":
+	case "kdbx":
+		if len(args) == 0 {
+			return errors.New("passman export: -format kdbx requires an output file")
+		}
+	default:
+		preset, ok := csvPreset(exportFormat)
+		if !ok {
+			return fmt.Errorf("passman export: unknown format %q", exportFormat)
+		}
+		if _, err := csv.NewMapping(preset, exportMap); err != nil {
+			return fmt.Errorf("passman export: %s", err)
+		}
+	}
+
+	var pattern *regexp.Regexp
+	if exportPattern != "" {
+		if pattern, err = regexp.Compile(exportPattern); err != nil {
+			return fmt.Errorf("passman export: invalid pattern: %s", err)
+		}
+	}
+	if exportEncrypt {
+		if exportFormat != "passman" {
+			return errors.New("passman export: -encrypt requires -format passman")
+		} else if len(args) == 0 {
+			return errors.New("passman export: -encrypt requires an output file")
+		}
+	}
+
+	s, err := openStore()
+	if err != nil {
+		return err
+	}
+	s = selectEntries(s, pattern, exportRedact)
+	if pattern != nil && len(s.Entries) == 0 {
+		return fmt.Errorf("passman export: no entries match %q", exportPattern)
+	}
 
 	if len(args) > 0 {
 		filename := args[0]
 		if _, err := os.Stat(filename); err == nil {
-			fatalf("passman init: '%s' already exists", filename)
+			return fmt.Errorf("passman export: '%s' already exists", filename)
 		}
 		out, err = os.OpenFile(filename, storeFileCreateFlag, storeFilePerm)
 		if err != nil {
-			fatalf("passman export: %s", err)
+			return fmt.Errorf("passman export: %s", err)
 		}
 		defer out.Close()
 	}
 
 	// Serialize store and output it
-	content, err := json.MarshalIndent(s, "", "  ")
-	if err != nil {
-		fatalf("%s", err)
-	}
-	if _, err = out.Write(content); err != nil {
-		fatalf("%s", err)
-	}
-
-	if _, err = fmt.Fprintln(out); err != nil {
-		fatalf("%s", err)
+	if err = exportStore(out, s); err != nil {
+		if out != os.Stdout {
+			os.Remove(out.Name())
+		}
+		return fmt.Errorf("passman export: %s", err)
 	}
 
 	if out != os.Stdout {
@@ -60,4 +164,110 @@ func runExport(cmd *Command, args []string) {
 			fmt.Printf("Created export file at '%s'\n", path)
 		}
 	}
+	return nil
+}
+
+// Metadata keys that are kept by -redact. Any other metadata (notes, custom
+// fields, card numbers, ...) may hold secrets.
+var publicMetadata = map[string]bool{
+	"url":      true,
+	"username": true,
+	"user":     true,
+	"login":    true,
+	"email":    true,
+}
+
+// Suffix of duplicate metadata keys added by the importers, e.g. "url-2"
+var duplicateKeySuffix = regexp.MustCompile(`-[0-9]+$`)
+
+// selectEntries returns a store with the entries of s whose id matches pattern
+// (all entries if pattern is nil). With redact, the entries are copied
+// without password, OTP key and non-public metadata.
+func selectEntries(s *store.Store, pattern *regexp.Regexp, redact bool) *store.Store {
+	sub := store.NewStore()
+	sub.Header = s.Header
+	for _, id := range s.Ids(pattern) {
+		e := s.Entries[id]
+		if redact {
+			redacted := *e
+			redacted.Password = nil
+			redacted.OTP = nil
+			redacted.Metadata = make(store.Metadata)
+			for key, val := range e.Metadata {
+				if publicMetadata[strings.ToLower(duplicateKeySuffix.ReplaceAllString(key, ""))] {
+					redacted.Metadata[key] = val
+				}
+			}
+			e = &redacted
+		}
+		sub.Entries[id] = e
+	}
+	return sub
+}
+
+// exportStore writes all entries of s in the export format.
+func exportStore(w io.Writer, s *store.Store) error {
+	ids := s.Ids(nil)
+	if exportEncrypt {
+		fmt.Fprintln(os.Stderr, "Choose a passphrase for the exported store.")
+		passphrase, err := readVerifiedPassphrase(minScore)
+		if err != nil {
+			return err
+		}
+		defer crypto.Clear(passphrase)
+		return encryptStore(w, s, passphrase)
+	}
+	switch exportFormat {
+	case "keepass2-xml":
+		return keepass2.Export(w, s, ids, kdbxDatabaseName, false)
+	case "kdbx":
+		key, err := newKdbxKey()
+		if err != nil {
+			return err
+		}
+		var doc bytes.Buffer
+		if err := keepass2.Export(&doc, s, ids, kdbxDatabaseName, true); err != nil {
+			return err
+		}
+		defer crypto.Clear(doc.Bytes())
+		return kdbx.Encrypt(w, doc.Bytes(), key)
+	}
+	if preset, ok := csvPreset(exportFormat); ok {
+		m, err := csv.NewMapping(preset, exportMap)
+		if err != nil {
+			return err
+		}
+		return csv.Export(w, s, ids, m)
+	}
+	return imprt.ExportStore(w, s)
+}
+
+// csvPreset returns the CSV preset of a format: "" for "csv", "chrome" for
+// "chrome-csv" and so on.
+func csvPreset(format string) (string, bool) {
+	if format == "csv" {
+		return "", true
+	}
+	preset := strings.TrimSuffix(format, "-csv")
+	_, ok := csv.Presets[preset]
+	return preset, ok && preset != format
+}
+
+// newKdbxKey prompts for the password of a new kdbx database and combines it
+// with the key file, if any.
+func newKdbxKey() (*kdbx.Key, error) {
+	var keyFile []byte
+	if exportKeyFile != "" {
+		var err error
+		if keyFile, err = ioutil.ReadFile(exportKeyFile); err != nil {
+			return nil, err
+		}
+	}
+	fmt.Fprintln(os.Stderr, "Choose a password for the kdbx database.")
+	password, err := readVerifiedPassphrase(minScore)
+	if err != nil {
+		return nil, err
+	}
+	defer crypto.Clear(password)
+	return kdbx.NewKey(password, keyFile)
 }
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"github.com/tvdburgt/passman/otp"
+	"github.com/tvdburgt/passman/store"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+// Redacted entries keep only their URLs and user names; the store itself is
+// not modified.
+func TestSelectEntriesRedact(t *testing.T) {
+	key, err := otp.Parse("JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := store.NewStore()
+	e := store.NewEntry()
+	e.Name = "octocat"
+	e.Password = []byte("hunter2")
+	e.OTP = key
+	e.Metadata = store.Metadata{
+		"url":    "https://github.com",
+		"url-2":  "https://gist.github.com",
+		"Email":  "octocat@example.com",
+		"notes":  "recovery codes: 1234 5678",
+		"PIN":    "0000",
+		"totp":   "not a key",
+		"secret": "s3cr3t",
+	}
+	s.Entries["work/github"] = e
+	s.Entries["home/mail"] = store.NewEntry()
+
+	sub := selectEntries(s, regexp.MustCompile("^work/"), true)
+	if ids := sub.Ids(nil); !reflect.DeepEqual(ids, []string{"work/github"}) {
+		t.Fatalf("ids %v", ids)
+	}
+	r := sub.Entries["work/github"]
+	expected := store.Metadata{
+		"url":   "https://github.com",
+		"url-2": "https://gist.github.com",
+		"Email": "octocat@example.com",
+	}
+	if r.Password != nil || r.OTP != nil || r.Name != "octocat" || !reflect.DeepEqual(r.Metadata, expected) {
+		t.Errorf("redacted %+v", r)
+	}
+	if string(e.Password) != "hunter2" || e.OTP == nil || len(e.Metadata) != 7 {
+		t.Errorf("store entry modified: %+v", e)
+	}
+}
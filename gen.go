@@ -1,10 +1,14 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"github.com/tvdburgt/passgen"
+	"github.com/tvdburgt/passman/pwgen"
 	"os"
 	"strconv"
+	"strings"
 	"unicode"
 )
 
@@ -16,33 +20,307 @@ const (
 	methodHex
 	methodBase32
 	methodDiceware
+	methodPronounceable
+	methodPattern
 )
 
 const (
 	defaultMethod       = methodAscii
 	defaultLen          = 64
 	defaultDicewareLen  = 6
-	defaultDicewareDict = "/usr/share/dict/words"
+	defaultDicewareList = "large"
+	defaultPronounceLen = 16
+	defaultPattern      = "Cvccvc-99-Cvccvc"
 )
 
+var methodNames = map[passMethod]string{
+	methodManual:        "manual",
+	methodAscii:         "ascii",
+	methodHex:           "hex",
+	methodBase32:        "base32",
+	methodDiceware:      "diceware",
+	methodPronounceable: "pronounceable",
+	methodPattern:       "pattern",
+}
+
+func (m passMethod) String() string {
+	if name, ok := methodNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("method %d", int(m))
+}
+
+// parseMethod returns the generation method with the given name.
+func parseMethod(name string) (passMethod, error) {
+	for m, n := range methodNames {
+		if n == name && m != methodManual {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown generation method %q", name)
+}
+
+// parseGenSpec parses a generator specification of the form method[:length]
+// (e.g., "ascii:32" or "diceware"). n is zero if no length is given.
+func parseGenSpec(spec string) (method passMethod, n int, err error) {
+	name, length := spec, ""
+	if i := strings.Index(spec, ":"); i >= 0 {
+		name, length = spec[:i], spec[i+1:]
+	}
+	if method, err = parseMethod(name); err != nil {
+		return
+	}
+	if length != "" {
+		if n, err = strconv.Atoi(length); err != nil || n <= 0 {
+			return 0, 0, fmt.Errorf("invalid length in %q", spec)
+		}
+	}
+	return
+}
+
+// defaultLength returns the default password length (or number of words)
+// for method. The length of a policy takes precedence, if given.
+func defaultLength(method passMethod, policy *pwgen.Policy) int {
+	switch method {
+	case methodDiceware:
+		return defaultDicewareLen
+	case methodPronounceable:
+		return defaultPronounceLen
+	}
+	if policy != nil {
+		return policy.DefaultLength(defaultLen)
+	}
+	return defaultLen
+}
+
+// Policy profiles of the user are read from $PASSMAN_POLICIES, or
+// ~/.passman_policies.json if unset.
+const (
+	policiesEnvKey      = "PASSMAN_POLICIES"
+	defaultPoliciesFile = "$HOME/.passman_policies.json"
+)
+
+// policyNone clears the policy of an entry.
+const policyNone = "none"
+
+// resolvePolicy returns the policy for s, which is either the name of a
+// profile (user profiles take precedence over built-in ones) or a rule
+// specification as accepted by pwgen.ParseSpec.
+func resolvePolicy(s string) (*pwgen.Policy, error) {
+	if s == policyNone {
+		return nil, nil
+	}
+	profiles, err := loadPolicies()
+	if err != nil {
+		return nil, err
+	}
+	if p, ok := profiles[s]; ok {
+		return p, nil
+	}
+	if p, ok := pwgen.Profiles[s]; ok {
+		return p, nil
+	}
+	p, err := pwgen.ParseSpec(s)
+	if err != nil {
+		return nil, fmt.Errorf("%s (not a policy profile either)", err)
+	}
+	return p, nil
+}
+
+// loadPolicies reads the policy profiles of the user. A missing file is not
+// an error, unless $PASSMAN_POLICIES explicitly refers to it.
+func loadPolicies() (map[string]*pwgen.Policy, error) {
+	path := os.Getenv(policiesEnvKey)
+	explicit := path != ""
+	if !explicit {
+		path = os.ExpandEnv(defaultPoliciesFile)
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) && !explicit {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	profiles, err := pwgen.LoadProfiles(f)
+	if err != nil {
+		return nil, fmt.Errorf("invalid policy file %s: %s", path, err)
+	}
+	return profiles, nil
+}
+
+// errAborted is returned when the user quits password generation.
+var errAborted = errors.New("aborted")
+
 var cmdGen = &Command{
-	Run:       runGen,
-	UsageLine: "gen",
+	UsageLine: "gen [-method method] [-length n] [-count k] [-policy policy]",
 	Short:     "generates a password",
 	Long: `
-long description
+gen prints newly generated passwords to stdout, one per line. It doesn't
+access the store.
+
+    -method method
+	Generation method: "ascii" (printable ASCII characters, default), "hex",
+	"base32", "diceware", "pronounceable" (alternating consonants and
+	vowels) or "pattern".
+
+    -length n
+	Password length, or number of words for diceware. The default is 64
+	characters, 16 for pronounceable passwords or 6 words. Patterns
+	determine their own length.
+
+    -count k
+	Number of passwords to generate (default 1).
+
+    -policy policy
+	Rules for ascii passwords: the name of a profile or a comma-separated
+	list of rules. Built-in profiles are "default", "alnum", "pin",
+	"readable" and "strict"; more can be defined in the JSON file
+	$PASSMAN_POLICIES (default ~/.passman_policies.json), e.g.
+
+		{"bank": {"max_length": 16, "min_digit": 1, "exclude": "%&"}}
+
+	The rules are:
+
+		length=n		default password length
+		max-length=n		maximum password length
+		alphabet=chars		allowed characters (default: printable ASCII)
+		exclude=chars		characters that are not allowed
+		no-lookalikes		exclude easily confused characters (0Oo1lI|)
+		min-lower=n		minimum number of lowercase letters
+		min-upper=n		minimum number of uppercase letters
+		min-digit=n		minimum number of digits
+		min-symbol=n		minimum number of symbols
+
+	Commas and backslashes in values are escaped with a backslash, e.g.
+	"max-length=16,exclude=\,;".
+
+    -v
+	Print the entropy of each password to stderr.
+
+    -wordlist list
+	Word list for diceware: "large" (EFF large list, 12.9 bits per word,
+	default), "short" (EFF short list, 10.3 bits per word) or the path of a
+	file with one word per line. Lines may be prefixed with dice rolls.
+
+    -separator sep
+	String between diceware words (default " ").
+
+    -capitalize
+	Capitalize the first letter of each diceware word.
+
+    -digits n
+	Append n random digits to randomly chosen diceware words.
+
+    -pattern template
+	Template for the pattern method (default "Cvccvc-99-Cvccvc"). Each
+	placeholder is replaced by a random character:
+
+		c	lowercase consonant
+		C	uppercase consonant
+		v	lowercase vowel
+		V	uppercase vowel
+		l	lowercase letter
+		L	uppercase letter
+		a	letter of either case
+		9	digit
+		s	symbol
+		x	printable ASCII character
+
+	Other characters are copied; a backslash makes the next character
+	literal (e.g. "\9").
 
-	-n -name <name>		set name
-	-p -password		prompt for password
-	-id <identifier>	change id of existing entry
+To create an entry with a generated password without any prompts, use
+'passman set -gen method[:length] id'.
 	`,
 }
 
-func runGen(cmd *Command, args []string) {
-	_, err := readPassword()
+var (
+	genMethod  = defaultMethod.String()
+	genLength  = 0
+	genCount   = 1
+	genVerbose = false
+	genPolicy  = ""
+)
+
+func init() {
+	cmdGen.Run = runGen
+	cmdGen.Flag.StringVar(&genMethod, "method", genMethod, "")
+	cmdGen.Flag.IntVar(&genLength, "length", genLength, "")
+	cmdGen.Flag.IntVar(&genCount, "count", genCount, "")
+	cmdGen.Flag.BoolVar(&genVerbose, "v", genVerbose, "")
+	cmdGen.Flag.StringVar(&genPolicy, "policy", genPolicy, "")
+	addGeneratorFlags(&cmdGen.Flag)
+}
+
+// Options for diceware passphrases and patterns
+var (
+	dicewareList       = defaultDicewareList
+	dicewareSeparator  = " "
+	dicewareCapitalize = false
+	dicewareDigits     = 0
+	genPattern         = defaultPattern
+)
+
+// addGeneratorFlags defines the option flags of the generation methods on fs.
+func addGeneratorFlags(fs *flag.FlagSet) {
+	fs.StringVar(&dicewareList, "wordlist", dicewareList, "")
+	fs.StringVar(&dicewareSeparator, "separator", dicewareSeparator, "")
+	fs.BoolVar(&dicewareCapitalize, "capitalize", dicewareCapitalize, "")
+	fs.IntVar(&dicewareDigits, "digits", dicewareDigits, "")
+	fs.StringVar(&genPattern, "pattern", genPattern, "")
+}
+
+// newDiceware returns a diceware generator for the configured options. The
+// word list is either a bundled list or a file.
+func newDiceware() (*pwgen.Diceware, error) {
+	list, ok := pwgen.Wordlists[dicewareList]
+	if !ok {
+		f, err := os.Open(dicewareList)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		if list, err = pwgen.ReadWordlist(f); err != nil {
+			return nil, fmt.Errorf("%s: %s", dicewareList, err)
+		}
+	}
+	return &pwgen.Diceware{
+		Words:      list,
+		Separator:  dicewareSeparator,
+		Capitalize: dicewareCapitalize,
+		Digits:     dicewareDigits,
+	}, nil
+}
+
+func runGen(cmd *Command, args []string) error {
+	method, err := parseMethod(genMethod)
 	if err != nil {
-		fatalf("passman gen: %s", err)
+		return fmt.Errorf("passman gen: %s", err)
 	}
+	var policy *pwgen.Policy
+	if genPolicy != "" {
+		if policy, err = resolvePolicy(genPolicy); err != nil {
+			return fmt.Errorf("passman gen: %s", err)
+		}
+	}
+	n := genLength
+	if n <= 0 {
+		n = defaultLength(method, policy)
+	}
+
+	for i := 0; i < genCount; i++ {
+		password, bits, err := genPassword(method, n, policy)
+		if err != nil {
+			return fmt.Errorf("passman gen: %s", err)
+		}
+		fmt.Printf("%s\n", password)
+		if genVerbose {
+			fmt.Fprintf(os.Stderr, "(%.2f bits)\n", bits)
+		}
+	}
+	return nil
 }
 
 // Helper method for reading numbers from stdin; uses default value if
@@ -58,8 +336,9 @@ func scanNumber(value int) (n int, err error) {
 	return
 }
 
-// TODO: scheme
-func readPassword() (password []byte, err error) {
+// readPassword prompts for a password or for a method to generate one with.
+// Generated ascii passwords follow policy, if non-nil.
+func readPassword(policy *pwgen.Policy) (password []byte, err error) {
 
 	var method passMethod
 
@@ -69,8 +348,11 @@ func readPassword() (password []byte, err error) {
   [%d] hex
   [%d] base32
   [%d] diceware
-  
-`, methodManual, methodAscii, methodHex, methodBase32, methodDiceware)
+  [%d] pronounceable
+  [%d] pattern
+
+`, methodManual, methodAscii, methodHex, methodBase32, methodDiceware,
+		methodPronounceable, methodPattern)
 
 	for {
 		for {
@@ -83,33 +365,49 @@ func readPassword() (password []byte, err error) {
 
 		switch method {
 		case methodManual:
-			return readVerifiedPassphrase(), nil
-		case methodAscii, methodHex, methodBase32, methodDiceware:
-			password, err := generatePassword(method)
+			return readVerifiedPassphrase(0)
+		case methodAscii, methodHex, methodBase32, methodDiceware,
+			methodPronounceable, methodPattern:
+			password, err := generatePassword(method, policy)
 			switch {
 			case err != nil:
 				return nil, err
 			case password == nil:
-				return readPassword()
+				return readPassword(policy)
 			default:
 				return password, nil
 			}
 		}
 	}
-	return
 }
 
-func generatePassword(method passMethod) (password []byte, err error) {
+// generatePassword interactively generates a password until the user accepts
+// one.
+func generatePassword(method passMethod, policy *pwgen.Policy) (password []byte, err error) {
 	var n int // Password length
-	var m int // Password symbol space
 
-	for {
+	if method != methodAscii {
+		policy = nil
+	}
+	for method == methodPattern {
+		fmt.Printf("Pattern [%s]: ", genPattern)
+		var s string
+		fmt.Scanln(&s)
+		if s == "" {
+			break
+		}
+		if _, err = pwgen.ParsePattern(s); err == nil {
+			genPattern = s
+			break
+		}
+		fmt.Println(err)
+	}
+	for method != methodPattern {
+		n = defaultLength(method, policy)
 		switch method {
 		case methodDiceware:
-			n = defaultDicewareLen
 			fmt.Printf("Number of words [%d]: ", n)
 		default:
-			n = defaultLen
 			fmt.Printf("Password length [%d]: ", n)
 		}
 		if n, err = scanNumber(n); err == nil {
@@ -118,31 +416,14 @@ func generatePassword(method passMethod) (password []byte, err error) {
 	}
 
 	for {
-		switch method {
-		case methodAscii:
-			password, err = passgen.Ascii(n, passgen.SetComplete)
-			m = passgen.SetComplete.Cardinality()
-		case methodHex:
-			password, err = passgen.Hex(n)
-			m = 16
-		case methodBase32:
-			password, err = passgen.Base32(n)
-			m = 32
-		case methodDiceware:
-			// TODO: prompt for diceware dict location
-			dict, err := os.Open(defaultDicewareDict)
-			if err != nil {
-				password, m, err = passgen.Diceware(dict, n, " ")
-			}
-		}
-
+		var bits float64
+		password, bits, err = genPassword(method, n, policy)
 		if err != nil {
 			return
 		}
 
-		fmt.Println("Generated password:\n")
-		fmt.Printf("\t%q (%.2f bits)\n\n",
-			password, passgen.Entropy(n, m))
+		fmt.Print("Generated password:\n\n")
+		fmt.Printf("\t%q (%.2f bits)\n\n", password, bits)
 
 	accept:
 		for {
@@ -159,9 +440,67 @@ func generatePassword(method passMethod) (password []byte, err error) {
 			case 'u':
 				return nil, nil
 			case 'q':
-				// Perhaps exit gracefully, so deferred functions can run...
-				os.Exit(0)
+				return nil, errAborted
 			}
 		}
 	}
 }
+
+// genPassword generates a password of n symbols (or words, for diceware)
+// with the given method. It also returns the entropy of the password in
+// bits. A non-nil policy restricts the characters of ascii passwords.
+func genPassword(method passMethod, n int, policy *pwgen.Policy) (password []byte, bits float64, err error) {
+	var m int // Password symbol space
+
+	if policy != nil {
+		if method != methodAscii {
+			return nil, 0, fmt.Errorf("policies only apply to ascii passwords, not %s", method)
+		}
+		if password, err = policy.Generate(n); err != nil {
+			return nil, 0, err
+		}
+		return password, policy.Entropy(n), nil
+	}
+
+	switch method {
+	case methodAscii:
+		password, err = passgen.Ascii(n, passgen.SetComplete)
+		m = passgen.SetComplete.Cardinality()
+	case methodHex:
+		password, err = passgen.Hex(n)
+		m = 16
+	case methodBase32:
+		password, err = passgen.Base32(n)
+		m = 32
+	case methodDiceware:
+		d, err := newDiceware()
+		if err != nil {
+			return nil, 0, err
+		}
+		if password, err = d.Generate(n); err != nil {
+			return nil, 0, err
+		}
+		return password, d.Entropy(n), nil
+	case methodPronounceable:
+		if password, err = pwgen.Pronounceable(n); err != nil {
+			return nil, 0, err
+		}
+		return password, pwgen.PronounceableEntropy(n), nil
+	case methodPattern:
+		p, err := pwgen.ParsePattern(genPattern)
+		if err != nil {
+			return nil, 0, err
+		}
+		if password, err = p.Generate(); err != nil {
+			return nil, 0, err
+		}
+		return password, p.Entropy(), nil
+	default:
+		return nil, 0, fmt.Errorf("%s can't generate passwords", method)
+	}
+
+	if err != nil {
+		return nil, 0, err
+	}
+	return password, passgen.Entropy(n, m), nil
+}
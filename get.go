@@ -2,6 +2,8 @@ package main
 
 import (
 	"fmt"
+	"github.com/tvdburgt/passman/store"
+	"os"
 )
 
 var cmdGet = &Command{
@@ -19,15 +21,37 @@ func init() {
 	addFileFlag(cmdGet)
 }
 
-func runGet(cmd *Command, args []string) {
+func runGet(cmd *Command, args []string) error {
 	if len(args) < 1 {
-		cmd.Usage()
+		return errUsage
 	}
 	id := args[0]
-	s := openStore()
+	s, err := openStore()
+	if err != nil {
+		return err
+	}
+	e, err := getEntry(s, id)
+	if err != nil {
+		return err
+	}
+	fmt.Print(e)
+	warnExpired(id, e)
+	return nil
+}
+
+// getEntry returns the entry with the given id.
+func getEntry(s *store.Store, id string) (*store.Entry, error) {
 	e, ok := s.Entries[id]
 	if !ok {
-		fatalf("Entry %q does not exist.", id)
+		return nil, fmt.Errorf("Entry %q does not exist.", id)
+	}
+	return e, nil
+}
+
+// warnExpired prints a warning to stderr if the password of e has expired.
+func warnExpired(id string, e *store.Entry) {
+	if t, ok := e.ExpiresAt(); ok && e.Expired() {
+		fmt.Fprintf(os.Stderr, "Warning: the password of %q expired on %s.\n",
+			id, t.Format("2006-01-02"))
 	}
-	fmt.Print(e)
 }
@@ -1,29 +1,96 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/tvdburgt/passman/crypto"
 	"github.com/tvdburgt/passman/import"
+	_ "github.com/tvdburgt/passman/import/bitwarden"
+	_ "github.com/tvdburgt/passman/import/csv"
+	_ "github.com/tvdburgt/passman/import/keepass"
+	_ "github.com/tvdburgt/passman/import/keepass2"
+	_ "github.com/tvdburgt/passman/import/keepassx"
+	_ "github.com/tvdburgt/passman/import/pass"
+	"github.com/tvdburgt/passman/import/util"
+	"github.com/tvdburgt/passman/store"
+	"io"
 	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
 )
 
 var cmdImport = &Command{
-	UsageLine: "import [-f file] [-format format] [-normalize] [-groups] import-file",
+	UsageLine: "import [-f file] [-format format] [-normalize] [-groups] [-min-score n] [-merge [-conflict strategy]] [-dry-run [-report format]] [-keyfile file] [-map mapping] [-decrypt-command command] import-file",
 	Short:     "import passwords from an export file",
 	Long: `
 JSON-formatted, defaults to stdout.
 
 
 	-format [format]
-	The following formats are available:
+	Format of the import file. By default, the format is detected from the
+	contents of the file (CSV files only with a preset's columns) and
+	directories are imported as pass stores. The following formats are
+	available:
+		- passman (JSON written by 'passman export')
+		- keepass (KeePass 1.x XML export)
+		- keepass2 (KeePass 2.x XML export)
 		- keepassx (XML export)
+		- kdbx (KeePass 2.x and KeePassXC database)
+		- bitwarden (Bitwarden JSON export, optionally password protected)
+		- csv (see -map), or a CSV preset: bitwarden-csv, lastpass-csv,
+		  chrome-csv, firefox-csv or 1password-csv
+		- pass (directory of a pass store, e.g. ~/.password-store)
+
+	-keyfile file
+	Key file of a kdbx database.
+
+	-map mapping
+	Column mapping of CSV files, e.g. "id=title,name=username,meta.url=url".
+
+	-decrypt-command command
+	Shell command that decrypts the files of a pass store (read from
+	stdin). Defaults to "gpg --quiet --batch --yes --decrypt".
+
+	-min-score n
+	Minimum estimated strength of the store passphrase (see 'passman help
+	init').
+
+	-merge
+	Merge the imported entries into an existing store, instead of creating
+	a new store.
+
+	-conflict strategy
+	What to do with imported entries whose id already exists in the store
+	(with -merge). Identical entries are always skipped.
+		- skip: keep the existing entry (default)
+		- overwrite: replace the existing entry
+		- rename: add the entry with a numeric suffix, e.g. "github-2"
+		- newer-wins: keep the most recently modified entry
+
+	-dry-run
+	Only show which entries would be imported and under which id, which
+	fields are stored as metadata and which entries are dropped. Passwords
+	are not shown, and the store is not written. With -merge, the store is
+	opened to show whether each entry would be added, updated or skipped,
+	and the ids of entries renamed by -conflict rename.
+
+	-report format
+	Format of the -dry-run report: "text" (default) or "json".
 	`,
 }
 
 var (
-	importFormat    = "passman"
+	importFormat    = ""
 	importNormalize = false
 	importGroups    = false
+	importMerge     = false
+	importConflict  = util.MergeSkip
+	importDryRun    = false
+	importReport    = "text"
+	importKeyFile   = ""
+	importMap       = ""
+	importDecrypt   = ""
 )
 
 func init() {
@@ -31,38 +98,205 @@ func init() {
 	cmdImport.Flag.StringVar(&importFormat, "format", importFormat, "")
 	cmdImport.Flag.BoolVar(&importNormalize, "normalize", importNormalize, "")
 	cmdImport.Flag.BoolVar(&importGroups, "groups", importGroups, "")
+	cmdImport.Flag.BoolVar(&importMerge, "merge", importMerge, "")
+	cmdImport.Flag.StringVar(&importConflict, "conflict", importConflict, "")
+	cmdImport.Flag.BoolVar(&importDryRun, "dry-run", importDryRun, "")
+	cmdImport.Flag.StringVar(&importReport, "report", importReport, "")
+	cmdImport.Flag.StringVar(&importKeyFile, "keyfile", importKeyFile, "")
+	cmdImport.Flag.StringVar(&importMap, "map", importMap, "")
+	cmdImport.Flag.StringVar(&importDecrypt, "decrypt-command", importDecrypt, "")
 	addFileFlag(cmdImport)
+	addMinScoreFlag(cmdImport)
 }
 
-func runImport(cmd *Command, args []string) {
+func runImport(cmd *Command, args []string) error {
 	if len(args) < 1 {
-		cmd.Usage()
+		return errUsage
+	}
+	if importDryRun {
+		if importReport != "text" && importReport != "json" {
+			return fmt.Errorf("Unknown report format %q", importReport)
+		}
+	} else if _, err := os.Stat(storeFile); err == nil && !importMerge {
+		return fmt.Errorf("Output file '%s' already exists (use -merge to import into it)", storeFile)
+	} else if err != nil && importMerge {
+		return fmt.Errorf("Import failed: %s", err)
 	}
-	if _, err := os.Stat(storeFile); err == nil {
-		fatalf("Output file '%s' already exists", storeFile)
+	if importMerge && !util.ValidStrategy(importConflict) {
+		return fmt.Errorf("Unknown conflict strategy %q (expected one of %s)",
+			importConflict, strings.Join(util.MergeStrategies, ", "))
 	}
 
 	filename := args[0]
 	file, err := os.Open(filename)
 	if err != nil {
-		fatalf("Failed to open import file: %s", err)
+		return fmt.Errorf("Failed to open import file: %s", err)
 	}
 	defer file.Close()
 
-	settings := &imprt.Settings{
+	settings := &util.ImportSettings{
 		NameGroups:       importGroups,
 		NormalizeEntries: importNormalize,
+		KeyFile:          importKeyFile,
+		Password:         importPassword(filename),
+		Map:              importMap,
+		Path:             filename,
+		DecryptCommand:   importDecrypt,
+	}
+	if importDryRun {
+		settings.Report = &util.Report{}
 	}
 
-	s, err := imprt.ImportStore(file, importFormat, settings)
+	var r io.Reader = file
+	var importer imprt.Importer
+	if importFormat == "" {
+		if importer, r, err = imprt.DetectFile(file); err != nil {
+			return fmt.Errorf("Failed to read import file: %s", err)
+		} else if importer == nil {
+			return fmt.Errorf("Unknown format of '%s' (use -format)", filename)
+		}
+	} else if importer = imprt.Lookup(importFormat); importer == nil {
+		return fmt.Errorf("Unknown format %q (expected one of %s)",
+			importFormat, strings.Join(imprt.Formats(), ", "))
+	}
 
+	s, err := importer.Import(r, settings)
 	if err != nil {
-		fatalf("Import failed: %s", err)
+		return fmt.Errorf("Import failed: %s", err)
 	}
 
-	passphrase := readVerifiedPassphrase()
+	if importDryRun {
+		if importMerge {
+			if err := dryRunMerge(s, settings.Report); err != nil {
+				return err
+			}
+		}
+		return writeImportReport(os.Stdout, settings.Report, filename, importReport)
+	}
+
+	if importMerge {
+		return mergeStore(s, filename)
+	}
+
+	passphrase, err := newStorePassphrase()
+	if err != nil {
+		return fmt.Errorf("Import failed: %s", err)
+	}
 	defer crypto.Clear(passphrase)
-	writeStore(s, passphrase)
+	if err := saveStore(s, passphrase); err != nil {
+		return err
+	}
 	fmt.Printf("Imported %d entries to '%s'.\n",
 		len(s.Entries), storeFile)
+	return nil
+}
+
+// mergeStore merges the imported entries of src into the store.
+func mergeStore(src *store.Store, filename string) error {
+	s, passphrase, err := openRwStore()
+	if err != nil {
+		return err
+	}
+	defer crypto.Clear(passphrase)
+
+	r, err := util.Merge(s, src, importConflict)
+	if err != nil {
+		return fmt.Errorf("Import failed: %s", err)
+	}
+	if err := saveStore(s, passphrase); err != nil {
+		return err
+	}
+
+	renamed := make([]string, 0, len(r.Renamed))
+	for id := range r.Renamed {
+		renamed = append(renamed, id)
+	}
+	sort.Strings(renamed)
+	for _, id := range renamed {
+		fmt.Printf("Renamed %q to %q.\n", id, r.Renamed[id])
+	}
+	fmt.Printf("Merged '%s' into '%s': %d added, %d updated, %d skipped.\n",
+		filename, storeFile, len(r.Added), len(r.Updated), len(r.Skipped))
+	return nil
+}
+
+// dryRunMerge merges the imported entries of src into the store without
+// writing it, and adds the outcome to the report.
+func dryRunMerge(src *store.Store, report *util.Report) error {
+	s, err := openStore()
+	if err != nil {
+		return err
+	}
+	r, err := util.Merge(s, src, importConflict)
+	if err != nil {
+		return fmt.Errorf("Import failed: %s", err)
+	}
+	report.Merged(r)
+	return nil
+}
+
+// importPassword returns a function that prompts for the password of the
+// encrypted import file filename. Without a way to prompt, a kdbx database
+// with a key file is opened without password.
+func importPassword(filename string) func() ([]byte, error) {
+	return func() ([]byte, error) {
+		p, err := prompter()
+		if err == errNoPassphrase && importKeyFile != "" {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		return p.ReadPassphrase(fmt.Sprintf("Enter password for '%s': ", filename))
+	}
+}
+
+// writeImportReport writes the report of a dry run in the given format.
+func writeImportReport(w io.Writer, r *util.Report, filename, format string) error {
+	if format == "json" {
+		b, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", b)
+		return err
+	}
+
+	if importMerge {
+		fmt.Fprintf(w, "Would merge %d entries from '%s' into '%s' (%d dropped).\n",
+			len(r.Entries), filename, storeFile, len(r.Dropped))
+	} else {
+		fmt.Fprintf(w, "Would import %d entries from '%s' (%d dropped).\n",
+			len(r.Entries), filename, len(r.Dropped))
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if len(r.Entries) > 0 {
+		renamed := false
+		if importMerge {
+			fmt.Fprintf(tw, "\nID\tSOURCE\tMERGE\tMETADATA\n")
+		} else {
+			fmt.Fprintf(tw, "\nID\tSOURCE\tMETADATA\n")
+		}
+		for _, e := range r.Entries {
+			id := e.Id
+			if e.Renamed {
+				id += " *"
+				renamed = true
+			}
+			if importMerge {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", id, e.Source, e.Merge, strings.Join(e.Metadata, ", "))
+			} else {
+				fmt.Fprintf(tw, "%s\t%s\t%s\n", id, e.Source, strings.Join(e.Metadata, ", "))
+			}
+		}
+		if renamed {
+			fmt.Fprintf(tw, "\n* renamed to resolve an id collision\n")
+		}
+	}
+	if len(r.Dropped) > 0 {
+		fmt.Fprintf(tw, "\nDROPPED\tREASON\n")
+		for _, d := range r.Dropped {
+			fmt.Fprintf(tw, "%s\t%s\n", d.Source, d.Reason)
+		}
+	}
+	return tw.Flush()
 }
@@ -0,0 +1,200 @@
+// Package bitwarden imports the JSON export of Bitwarden, unencrypted or
+// encrypted with a password ("password protected" export).
+package bitwarden
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/tvdburgt/passman/crypto"
+	"github.com/tvdburgt/passman/import"
+	"github.com/tvdburgt/passman/import/util"
+	"github.com/tvdburgt/passman/otp"
+	"github.com/tvdburgt/passman/store"
+	"io"
+	"sort"
+	"time"
+)
+
+// Type of custom fields that refer to another field of the item
+const fieldLinked = 3
+
+type export struct {
+	Encrypted         bool
+	PasswordProtected bool
+
+	// Password protected exports
+	Salt           string
+	KdfType        int
+	KdfIterations  int
+	KdfMemory      int // MiB
+	KdfParallelism int
+	KeyValidation  string `json:"encKeyValidation_DO_NOT_EDIT"`
+	Data           string
+
+	Folders     []folder
+	Collections []folder // Organization exports
+	Items       []item
+}
+
+type folder struct {
+	Id, Name string
+}
+
+type item struct {
+	FolderId      string
+	CollectionIds []string
+	Name          string
+	Notes         string
+	Fields        []struct {
+		Name  string
+		Value *string
+		Type  int
+	}
+	Login *struct {
+		Uris []struct {
+			Uri string
+		}
+		Username string
+		Password string
+		Totp     string
+	}
+	Card         map[string]interface{}
+	Identity     map[string]interface{}
+	SSHKey       map[string]interface{}
+	CreationDate time.Time
+	RevisionDate time.Time
+}
+
+func init() {
+	imprt.Register(imprt.NewFormat("bitwarden", imprt.JSONKeyDetector("encrypted", "items"), Import))
+}
+
+// Import reads a Bitwarden JSON export. The password of an encrypted export
+// is obtained with settings.Password.
+func Import(r io.Reader, settings *util.ImportSettings) (*store.Store, error) {
+	var ex export
+	if err := json.NewDecoder(r).Decode(&ex); err != nil {
+		return nil, err
+	}
+	if ex.Encrypted {
+		if !ex.PasswordProtected {
+			return nil, errors.New("bitwarden: export is encrypted with the account key " +
+				"(export with a password or unencrypted instead)")
+		}
+		if settings.Password == nil {
+			return nil, errors.New("bitwarden: export is encrypted, but no password is available")
+		}
+		password, err := settings.Password()
+		if err != nil {
+			return nil, err
+		}
+		data, err := decrypt(&ex, password)
+		crypto.Clear(password)
+		if err != nil {
+			return nil, err
+		}
+		ex = export{}
+		err = json.Unmarshal(data, &ex)
+		crypto.Clear(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	groups := make(map[string]string)
+	for _, f := range append(ex.Folders, ex.Collections...) {
+		groups[f.Id] = f.Name
+	}
+
+	s := store.NewStore()
+	for _, it := range ex.Items {
+		group := groups[it.FolderId]
+		if group == "" && len(it.CollectionIds) > 0 {
+			group = groups[it.CollectionIds[0]]
+		}
+		it.sync(s, group, settings)
+	}
+	return s, nil
+}
+
+func (it *item) sync(s *store.Store, group string, settings *util.ImportSettings) {
+	e := store.NewEntry()
+	if !it.CreationDate.IsZero() {
+		e.Ctime = it.CreationDate
+	}
+	if !it.RevisionDate.IsZero() {
+		e.Mtime = it.RevisionDate
+	}
+
+	setMetadata := func(key, value string) {
+		if value == "" {
+			return
+		}
+		if settings.NormalizeEntries {
+			key = util.Normalize(key)
+		}
+		// Keep values of duplicate keys, e.g. multiple URIs
+		base := key
+		for i := 2; e.Metadata[key] != ""; i++ {
+			key = fmt.Sprintf("%s-%d", base, i)
+		}
+		e.Metadata[key] = value
+	}
+
+	if l := it.Login; l != nil {
+		e.Name = l.Username
+		e.Password = []byte(l.Password)
+		for _, u := range l.Uris {
+			setMetadata("url", u.Uri)
+		}
+		if l.Totp != "" {
+			if key, err := otp.Parse(l.Totp); err == nil {
+				e.OTP = key
+			} else {
+				setMetadata("totp", l.Totp)
+			}
+		}
+	}
+	setMetadata("notes", it.Notes)
+	for _, f := range it.Fields {
+		if f.Value != nil && f.Type != fieldLinked {
+			setMetadata(f.Name, *f.Value)
+		}
+	}
+
+	// Cards, identities and SSH keys have no password; their properties
+	// become metadata.
+	for _, props := range []map[string]interface{}{it.Card, it.Identity, it.SSHKey} {
+		keys := make([]string, 0, len(props))
+		for key := range props {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if v, ok := props[key].(string); ok {
+				setMetadata(key, v)
+			}
+		}
+	}
+
+	id := it.Name
+	if id == "" {
+		id = util.DefaultId
+	}
+	source := it.Name
+	if group != "" {
+		source = group + "/" + it.Name
+		if settings.NameGroups {
+			id = group + "/" + id
+		}
+	}
+	if settings.NormalizeEntries {
+		id = util.Normalize(id)
+	}
+
+	requested := id
+	id = util.ResolveIdCollisions(s, id)
+	s.Entries[id] = e
+	settings.Report.Add(source, requested, id, e)
+}
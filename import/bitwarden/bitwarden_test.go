@@ -0,0 +1,105 @@
+package bitwarden
+
+import (
+	"bytes"
+	"github.com/tvdburgt/passman/import/util"
+	"github.com/tvdburgt/passman/store"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readExport(t *testing.T) []byte {
+	data, err := ioutil.ReadFile("testdata/bitwarden.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func checkImport(t *testing.T, s *store.Store) {
+	if ids := s.Ids(nil); !reflect.DeepEqual(ids, []string{"Visa", "Wi-Fi", "Work/GitHub"}) {
+		t.Fatalf("imported %v", ids)
+	}
+	github := s.Entries["Work/GitHub"]
+	if github.Name != "octocat" || string(github.Password) != "hunter2" ||
+		github.OTP == nil || github.OTP.Issuer != "GitHub" {
+		t.Errorf("imported %+v", github)
+	}
+	expected := store.Metadata{
+		"url":   "https://github.com",
+		"url-2": "https://gist.github.com",
+		"notes": "Recovery codes:\nabc-def",
+		"PIN":   "1234",
+	}
+	if !reflect.DeepEqual(github.Metadata, expected) {
+		t.Errorf("metadata %q, expected %q", github.Metadata, expected)
+	}
+	ctime := time.Date(2021, 3, 14, 15, 9, 26, 535000000, time.UTC)
+	if !github.Ctime.Equal(ctime) || !github.Mtime.Equal(ctime.AddDate(0, 0, 1)) {
+		t.Errorf("ctime %s, mtime %s", github.Ctime, github.Mtime)
+	}
+	if notes := s.Entries["Wi-Fi"].Metadata["notes"]; notes != "SSID: home" {
+		t.Errorf("notes %q", notes)
+	}
+	if card := s.Entries["Visa"].Metadata; card["number"] != "4111111111111111" || card["code"] != "123" {
+		t.Errorf("card %q", card)
+	}
+}
+
+func TestImport(t *testing.T) {
+	s, err := Import(bytes.NewReader(readExport(t)), &util.ImportSettings{NameGroups: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkImport(t, s)
+}
+
+// Password protected exports of testdata/bitwarden.json, with the default
+// PBKDF2 iterations of Bitwarden and Argon2id with 16 MiB. They weren't
+// exported by Bitwarden: they were written by testdata/gen/gen.py following
+// Bitwarden's export format, independently of this package (see
+// testdata/gen/README).
+var encryptedExports = []struct {
+	file     string
+	password string
+}{
+	{"pbkdf2.json", "correct horse"},
+	{"argon2id.json", "battery staple"},
+}
+
+func TestImportEncrypted(t *testing.T) {
+	for _, ex := range encryptedExports {
+		data, err := ioutil.ReadFile("testdata/" + ex.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, password := range []string{ex.password, "wrong"} {
+			settings := &util.ImportSettings{
+				NameGroups: true,
+				Password:   func() ([]byte, error) { return []byte(password), nil },
+			}
+			s, err := Import(bytes.NewReader(data), settings)
+			if password == "wrong" {
+				if err != ErrPassword {
+					t.Errorf("%s: error %v, expected %v", ex.file, err, ErrPassword)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("%s: %s", ex.file, err)
+			}
+			checkImport(t, s)
+		}
+	}
+}
+
+func TestImportAccountEncrypted(t *testing.T) {
+	data := `{"encrypted": true, "folders": [], "items": [{"name": "2.AAAA|BBBB|CCCC"}]}`
+	_, err := Import(strings.NewReader(data), &util.ImportSettings{})
+	if err == nil || !strings.Contains(err.Error(), "account key") {
+		t.Errorf("error %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package bitwarden
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/pbkdf2"
+	"io"
+	"strings"
+)
+
+// Key derivation functions
+const (
+	kdfPBKDF2   = 0
+	kdfArgon2id = 1
+)
+
+// Type of encrypted strings: AES-256 in CBC mode with an HMAC-SHA256
+const encTypeAesCbc256HmacSha256 = "2"
+
+// ErrPassword is returned if an encrypted export can't be decrypted with the
+// given password.
+var ErrPassword = errors.New("bitwarden: wrong password")
+
+// decrypt returns the decrypted data of a password protected export.
+func decrypt(ex *export, password []byte) ([]byte, error) {
+	encKey, macKey, err := deriveKeys(ex, password)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := decryptString(ex.KeyValidation, encKey, macKey); err != nil {
+		return nil, err
+	}
+	return decryptString(ex.Data, encKey, macKey)
+}
+
+// deriveKeys derives the encryption and MAC keys from the password.
+func deriveKeys(ex *export, password []byte) (encKey, macKey []byte, err error) {
+	var key []byte
+	switch ex.KdfType {
+	case kdfPBKDF2:
+		if ex.KdfIterations <= 0 {
+			return nil, nil, errors.New("bitwarden: invalid PBKDF2 iterations")
+		}
+		key = pbkdf2.Key(password, []byte(ex.Salt), ex.KdfIterations, 32, sha256.New)
+	case kdfArgon2id:
+		if ex.KdfIterations <= 0 || ex.KdfMemory <= 0 || ex.KdfParallelism <= 0 ||
+			ex.KdfParallelism > 255 {
+			return nil, nil, errors.New("bitwarden: invalid Argon2 parameters")
+		}
+		salt := sha256.Sum256([]byte(ex.Salt))
+		key = argon2.IDKey(password, salt[:], uint32(ex.KdfIterations),
+			uint32(ex.KdfMemory)*1024, uint8(ex.KdfParallelism), 32)
+	default:
+		return nil, nil, fmt.Errorf("bitwarden: unsupported key derivation function %d", ex.KdfType)
+	}
+
+	// Stretch the key into two keys with HKDF (expand only)
+	encKey, macKey = make([]byte, 32), make([]byte, 32)
+	io.ReadFull(hkdf.Expand(sha256.New, key, []byte("enc")), encKey)
+	io.ReadFull(hkdf.Expand(sha256.New, key, []byte("mac")), macKey)
+	return encKey, macKey, nil
+}
+
+// decryptString decrypts an encrypted string of the form
+// "2.iv|ciphertext|mac", with base64-encoded parts.
+func decryptString(s string, encKey, macKey []byte) ([]byte, error) {
+	invalid := errors.New("bitwarden: invalid encrypted string")
+	i := strings.Index(s, ".")
+	if i < 0 {
+		return nil, invalid
+	}
+	if s[:i] != encTypeAesCbc256HmacSha256 {
+		return nil, fmt.Errorf("bitwarden: unsupported encryption type %s", s[:i])
+	}
+	parts := strings.Split(s[i+1:], "|")
+	if len(parts) != 3 {
+		return nil, invalid
+	}
+	var iv, data, mac []byte
+	for j, p := range []*[]byte{&iv, &data, &mac} {
+		var err error
+		if *p, err = base64.StdEncoding.DecodeString(parts[j]); err != nil {
+			return nil, invalid
+		}
+	}
+
+	h := hmac.New(sha256.New, macKey)
+	h.Write(iv)
+	h.Write(data)
+	if !hmac.Equal(h.Sum(nil), mac) {
+		return nil, ErrPassword
+	}
+
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != aes.BlockSize || len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, invalid
+	}
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, data)
+	pad := int(plain[len(plain)-1])
+	if pad == 0 || pad > aes.BlockSize ||
+		!bytes.Equal(plain[len(plain)-pad:], bytes.Repeat([]byte{byte(pad)}, pad)) {
+		return nil, invalid
+	}
+	return plain[:len(plain)-pad], nil
+}
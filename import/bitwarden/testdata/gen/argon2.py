@@ -0,0 +1,115 @@
+# Argon2id (RFC 9106) with hashlib.blake2b only.
+import hashlib, struct
+M = (1 << 64) - 1
+
+def le32(n): return struct.pack('<I', n)
+
+def hprime(T, X):
+    if T <= 64:
+        return hashlib.blake2b(le32(T) + X, digest_size=T).digest()
+    r = (T + 31) // 32 - 2
+    v = hashlib.blake2b(le32(T) + X).digest()
+    out = v[:32]
+    for _ in range(r - 1):
+        v = hashlib.blake2b(v).digest()
+        out += v[:32]
+    v = hashlib.blake2b(v, digest_size=T - 32 * r).digest()
+    return out + v
+
+def gb(v, a, b, c, d):
+    va, vb, vc, vd = v[a], v[b], v[c], v[d]
+    va = (va + vb + 2 * (va & 0xffffffff) * (vb & 0xffffffff)) & M
+    vd ^= va; vd = ((vd >> 32) | (vd << 32)) & M
+    vc = (vc + vd + 2 * (vc & 0xffffffff) * (vd & 0xffffffff)) & M
+    vb ^= vc; vb = ((vb >> 24) | (vb << 40)) & M
+    va = (va + vb + 2 * (va & 0xffffffff) * (vb & 0xffffffff)) & M
+    vd ^= va; vd = ((vd >> 16) | (vd << 48)) & M
+    vc = (vc + vd + 2 * (vc & 0xffffffff) * (vd & 0xffffffff)) & M
+    vb ^= vc; vb = ((vb >> 63) | (vb << 1)) & M
+    v[a], v[b], v[c], v[d] = va, vb, vc, vd
+
+def perm(v):
+    gb(v, 0, 4, 8, 12); gb(v, 1, 5, 9, 13); gb(v, 2, 6, 10, 14); gb(v, 3, 7, 11, 15)
+    gb(v, 0, 5, 10, 15); gb(v, 1, 6, 11, 12); gb(v, 2, 7, 8, 13); gb(v, 3, 4, 9, 14)
+
+ROWS = [list(range(16 * i, 16 * i + 16)) for i in range(8)]
+COLS = [[2 * j + 16 * k + o for k in range(8) for o in (0, 1)] for j in range(8)]
+
+def compress(x, y):
+    r = [a ^ b for a, b in zip(x, y)]
+    q = r[:]
+    for idx in ROWS + COLS:
+        v = [q[i] for i in idx]
+        perm(v)
+        for i, w in zip(idx, v):
+            q[i] = w
+    return [a ^ b for a, b in zip(q, r)]
+
+def block(b): return list(struct.unpack('<128Q', b))
+
+def argon2id(password, salt, t, m, p, T, secret=b'', ad=b''):
+    h0 = hashlib.blake2b(le32(p) + le32(T) + le32(m) + le32(t) + le32(0x13) + le32(2) +
+        le32(len(password)) + password + le32(len(salt)) + salt +
+        le32(len(secret)) + secret + le32(len(ad)) + ad).digest()
+    mm = 4 * p * (m // (4 * p))
+    q = mm // p
+    seg = q // 4
+    B = [[None] * q for _ in range(p)]
+    for l in range(p):
+        B[l][0] = block(hprime(1024, h0 + le32(0) + le32(l)))
+        B[l][1] = block(hprime(1024, h0 + le32(1) + le32(l)))
+    zero = [0] * 128
+    for r in range(t):
+        for s in range(4):
+            for l in range(p):
+                indep = r == 0 and s < 2
+                inp = [r, l, s, mm, t, 2] + [0] * 122
+                addr = None
+                start = 2 if r == 0 and s == 0 else 0
+                def next_addr():
+                    inp[6] += 1
+                    return compress(zero, compress(zero, inp))
+                if indep and start == 2:
+                    addr = next_addr()
+                for i in range(start, seg):
+                    j = s * seg + i
+                    prev = B[l][j - 1] if j > 0 else B[l][q - 1]
+                    if indep:
+                        if i % 128 == 0:
+                            addr = next_addr()
+                        rand = addr[i % 128]
+                    else:
+                        rand = prev[0]
+                    j1, j2 = rand & 0xffffffff, rand >> 32
+                    rl = l if r == 0 and s == 0 else j2 % p
+                    same = rl == l
+                    if r == 0:
+                        if s == 0:
+                            size = i - 1
+                        elif same:
+                            size = s * seg + i - 1
+                        else:
+                            size = s * seg + (-1 if i == 0 else 0)
+                    else:
+                        if same:
+                            size = q - seg + i - 1
+                        else:
+                            size = q - seg + (-1 if i == 0 else 0)
+                    rel = (j1 * j1) >> 32
+                    rel = size - 1 - ((size * rel) >> 32)
+                    startpos = 0 if r == 0 or s == 3 else (s + 1) * seg
+                    ref = B[rl][(startpos + rel) % q]
+                    new = compress(prev, ref)
+                    if r > 0:
+                        new = [a ^ b for a, b in zip(new, B[l][j])]
+                    B[l][j] = new
+    c = B[0][q - 1]
+    for l in range(1, p):
+        c = [a ^ b for a, b in zip(c, B[l][q - 1])]
+    return hprime(T, struct.pack('<128Q', *c))
+
+if __name__ == '__main__':
+    # Test vector of RFC 9106, section 5.3
+    tag = argon2id(b'\x01' * 32, b'\x02' * 16, 3, 32, 4, 32, b'\x03' * 8, b'\x04' * 12)
+    assert tag.hex() == '0d640df58d78766c08c037a34a8b53c9d01ef0452d75b65eb52520e96b01e659'
+    print('ok')
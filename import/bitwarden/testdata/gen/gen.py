@@ -0,0 +1,37 @@
+# Writes a password protected Bitwarden export of a plain Bitwarden JSON export.
+#
+# Usage: python3 gen.py plain.json out.json kdf-json password
+import base64, hashlib, hmac, json, os, subprocess, sys, uuid
+from argon2 import argon2id
+
+def expand(prk, info):
+    return hmac.new(prk, info + b'\x01', hashlib.sha256).digest()
+
+def aes(key, iv, data):
+    return subprocess.run(['openssl', 'enc', '-aes-256-cbc', '-K', key.hex(), '-iv', iv.hex()],
+                          input=data, capture_output=True, check=True).stdout
+
+def enc_string(plain, ek, mk):
+    iv = os.urandom(16)
+    ct = aes(ek, iv, plain)
+    mac = hmac.new(mk, iv + ct, hashlib.sha256).digest()
+    b = lambda x: base64.b64encode(x).decode()
+    return '2.' + b(iv) + '|' + b(ct) + '|' + b(mac)
+
+def export(plain, password, kdf):
+    salt = base64.b64encode(os.urandom(16)).decode()
+    if kdf['kdfType'] == 0:
+        key = hashlib.pbkdf2_hmac('sha256', password, salt.encode(), kdf['kdfIterations'], 32)
+    else:
+        key = argon2id(password, hashlib.sha256(salt.encode()).digest(), kdf['kdfIterations'],
+                       kdf['kdfMemory'] * 1024, kdf['kdfParallelism'], 32)
+    ek, mk = expand(key, b'enc'), expand(key, b'mac')
+    ex = {'encrypted': True, 'passwordProtected': True, 'salt': salt}
+    ex.update(kdf)
+    ex['encKeyValidation_DO_NOT_EDIT'] = enc_string(str(uuid.uuid4()).encode(), ek, mk)
+    ex['data'] = enc_string(plain, ek, mk)
+    return json.dumps(ex, indent=2) + '\n'
+
+plain = open(sys.argv[1], 'rb').read()
+kdf = json.loads(sys.argv[3])
+open(sys.argv[2], 'w').write(export(plain, sys.argv[4].encode(), kdf))
@@ -0,0 +1,480 @@
+// Package csv imports and exports CSV files. A mapping determines which
+// column holds which entry field; presets are provided for the CSV exports of
+// common password managers and browsers.
+package csv
+
+import (
+	"bufio"
+	"bytes"
+	stdcsv "encoding/csv"
+	"fmt"
+	"github.com/tvdburgt/passman/import"
+	"github.com/tvdburgt/passman/import/util"
+	"github.com/tvdburgt/passman/otp"
+	"github.com/tvdburgt/passman/store"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Entry fields that columns are mapped to
+const (
+	FieldId       = "id"
+	FieldName     = "name"
+	FieldPassword = "password"
+	FieldOTP      = "otp"    // otpauth URI or base32 secret
+	FieldGroup    = "group"  // Folder, used as id prefix with NameGroups
+	FieldFields   = "fields" // Custom fields as "key: value" lines
+	FieldCtime    = "ctime"
+	FieldMtime    = "mtime"
+
+	// Prefix of metadata fields, e.g. "meta.url"
+	MetaPrefix = "meta."
+)
+
+var fields = []string{FieldId, FieldName, FieldPassword, FieldOTP, FieldGroup,
+	FieldFields, FieldCtime, FieldMtime}
+
+// Presets maps the names of CSV dialects to their mapping, in the column
+// order of the files written by the respective application.
+var Presets = map[string]string{
+	"bitwarden": "group=folder,id=name,meta.notes=notes,fields=fields," +
+		"meta.url=login_uri,name=login_username,password=login_password,otp=login_totp",
+	"lastpass": "meta.url=url,name=username,password=password,otp=totp," +
+		"meta.notes=extra,id=name,group=grouping",
+	"chrome": "id=name,meta.url=url,name=username,password=password,meta.notes=note",
+	"firefox": "id=url,name=username,password=password," +
+		"ctime=timeCreated,mtime=timePasswordChanged",
+	"1password": "id=Title,meta.url=Url,name=Username,password=Password," +
+		"otp=OTPAuth,meta.notes=Notes",
+}
+
+// The generic csv format needs a mapping (or field names as column headers),
+// so only the presets are detected.
+func init() {
+	imprt.Register(imprt.NewFormat("csv", nil, importer("")))
+	presets := make([]string, 0, len(Presets))
+	for preset := range Presets {
+		presets = append(presets, preset)
+	}
+	sort.Strings(presets)
+	for _, preset := range presets {
+		preset := preset
+		detect := func(header []byte) bool { return Detect(header) == preset }
+		imprt.Register(imprt.NewFormat(preset+"-csv", detect, importer(preset)))
+	}
+}
+
+// Columns with times in milliseconds since the Unix epoch (Firefox)
+var unixMillisColumns = map[string]bool{
+	"timeCreated":         true,
+	"timeLastUsed":        true,
+	"timePasswordChanged": true,
+}
+
+// Column maps an entry field to the column with the given header.
+type Column struct {
+	Field  string
+	Header string
+}
+
+// Mapping lists the columns of a CSV file. A nil Mapping is the default
+// mapping: columns named after an entry field hold that field, all other
+// columns hold metadata.
+type Mapping []Column
+
+// ParseMapping parses a comma-separated list of field=header pairs, e.g.
+// "id=title,password=password,meta.url=url".
+func ParseMapping(s string) (Mapping, error) {
+	var m Mapping
+	seen := make(map[string]bool)
+	for _, pair := range strings.Split(s, ",") {
+		i := strings.Index(pair, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("csv: invalid mapping %q (expected field=column)", pair)
+		}
+		c := Column{strings.TrimSpace(pair[:i]), strings.TrimSpace(pair[i+1:])}
+		if !validField(c.Field) {
+			return nil, fmt.Errorf("csv: unknown field %q (expected one of %s or %s<key>)",
+				c.Field, strings.Join(fields, ", "), MetaPrefix)
+		}
+		if c.Header == "" {
+			return nil, fmt.Errorf("csv: no column for field %q", c.Field)
+		}
+		if seen[c.Field] {
+			return nil, fmt.Errorf("csv: field %q is mapped twice", c.Field)
+		}
+		seen[c.Field] = true
+		m = append(m, c)
+	}
+	return m, nil
+}
+
+// NewMapping returns the parsed mapping s or, if s is empty, the mapping of
+// the named preset. Without either, the default mapping is returned.
+func NewMapping(preset, s string) (Mapping, error) {
+	if s == "" && preset != "" {
+		var ok bool
+		if s, ok = Presets[preset]; !ok {
+			return nil, fmt.Errorf("csv: unknown preset %q", preset)
+		}
+	}
+	if s == "" {
+		return nil, nil
+	}
+	return ParseMapping(s)
+}
+
+func validField(field string) bool {
+	if strings.HasPrefix(field, MetaPrefix) {
+		return len(field) > len(MetaPrefix)
+	}
+	for _, f := range fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultMapping maps the columns with the given headers: columns named after
+// an entry field hold that field, all other columns hold metadata.
+func defaultMapping(headers []string) Mapping {
+	var m Mapping
+	for _, h := range headers {
+		field := MetaPrefix + h
+		for _, f := range fields {
+			if strings.EqualFold(h, f) {
+				field = f
+			}
+		}
+		m = append(m, Column{field, h})
+	}
+	return m
+}
+
+// Detect returns the preset whose columns are all in the header row of a CSV
+// file, given by its first bytes, or "" if there is none. Of several matching
+// presets, the one with the most columns is returned.
+func Detect(header []byte) string {
+	cr := stdcsv.NewReader(bytes.NewReader(bytes.TrimPrefix(header, []byte("\xef\xbb\xbf"))))
+	cr.LazyQuotes = true
+	headers, err := cr.Read()
+	if err != nil {
+		return ""
+	}
+
+	var detected string
+	var columns int
+	for preset, s := range Presets {
+		m, err := ParseMapping(s)
+		if err != nil || !m.matches(headers) {
+			continue
+		}
+		// Map order is random; break ties by name
+		if len(m) > columns || len(m) == columns && preset < detected {
+			detected, columns = preset, len(m)
+		}
+	}
+	return detected
+}
+
+// matches reports whether all columns of m are in headers.
+func (m Mapping) matches(headers []string) bool {
+	for _, c := range m {
+		found := false
+		for _, h := range headers {
+			if strings.EqualFold(strings.TrimSpace(h), c.Header) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Import reads a CSV file with a header row. Quoted values may span multiple
+// lines, and a leading byte order mark is ignored.
+func Import(r io.Reader, settings *util.ImportSettings, m Mapping) (*store.Store, error) {
+	br := bufio.NewReader(r)
+	if bom, err := br.Peek(3); err == nil && bytes.Equal(bom, []byte("\xef\xbb\xbf")) {
+		br.Discard(3)
+	}
+	cr := stdcsv.NewReader(br)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+
+	headers, err := cr.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("csv: missing header row")
+	} else if err != nil {
+		return nil, fmt.Errorf("csv: %s", err)
+	}
+	if m == nil {
+		m = defaultMapping(headers)
+	}
+
+	// Column index of each field
+	index := make(map[string]int)
+	for _, c := range m {
+		index[c.Field] = -1
+		for i, h := range headers {
+			if strings.EqualFold(strings.TrimSpace(h), c.Header) {
+				index[c.Field] = i
+				break
+			}
+		}
+		if index[c.Field] < 0 {
+			return nil, fmt.Errorf("csv: no column %q in header row", c.Header)
+		}
+	}
+
+	s := store.NewStore()
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("csv: %s", err)
+		}
+		line, _ := cr.FieldPos(0)
+		values := make(map[string]string)
+		for field, i := range index {
+			if i < len(record) {
+				values[field] = record[i]
+			}
+		}
+		importRow(s, values, line, settings)
+	}
+	return s, nil
+}
+
+// importRow adds the entry with the given field values, read from the given
+// line.
+func importRow(s *store.Store, values map[string]string, line int, settings *util.ImportSettings) {
+	empty := true
+	for _, v := range values {
+		empty = empty && strings.TrimSpace(v) == ""
+	}
+	if empty {
+		settings.Report.Drop(fmt.Sprintf("line %d", line), "empty row")
+		return
+	}
+
+	e := store.NewEntry()
+	e.Name = values[FieldName]
+	e.Password = []byte(values[FieldPassword])
+	if t, ok := parseTime(values[FieldCtime]); ok {
+		e.Ctime, e.Mtime = t, t
+	}
+	if t, ok := parseTime(values[FieldMtime]); ok {
+		e.Mtime = t
+	}
+
+	setMetadata := func(key, value string) {
+		if value == "" {
+			return
+		}
+		if settings.NormalizeEntries {
+			key = util.Normalize(key)
+		}
+		e.Metadata[key] = value
+	}
+	if v := values[FieldOTP]; v != "" {
+		if key, err := otp.Parse(v); err == nil {
+			e.OTP = key
+		} else {
+			setMetadata("otp", v)
+		}
+	}
+	for field, v := range values {
+		if strings.HasPrefix(field, MetaPrefix) {
+			setMetadata(strings.TrimPrefix(field, MetaPrefix), v)
+		}
+	}
+	for _, l := range strings.Split(values[FieldFields], "\n") {
+		if i := strings.Index(l, ": "); i > 0 {
+			setMetadata(l[:i], strings.TrimRight(l[i+2:], "\r"))
+		}
+	}
+
+	// Use the host name of URLs as id (Firefox only exports URLs)
+	title := strings.TrimSpace(values[FieldId])
+	if u, err := url.Parse(title); err == nil && u.Scheme != "" && u.Host != "" {
+		title = u.Hostname()
+	}
+	id := title
+	if id == "" {
+		id = util.DefaultId
+	}
+	group := strings.Trim(strings.Replace(values[FieldGroup], "\\", "/", -1), "/")
+	source := title
+	if group != "" {
+		source = group + "/" + title
+		if settings.NameGroups {
+			id = group + "/" + id
+		}
+	}
+	if settings.NormalizeEntries {
+		id = util.Normalize(id)
+	}
+
+	requested := id
+	id = util.ResolveIdCollisions(s, id)
+	s.Entries[id] = e
+	settings.Report.Add(source, requested, id, e)
+}
+
+// parseTime parses a time in RFC 3339 format, or as seconds or milliseconds
+// since the Unix epoch.
+func parseTime(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, true
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n <= 0 {
+		return time.Time{}, false
+	}
+	if len(s) >= 13 {
+		return time.Unix(n/1000, n%1000*int64(time.Millisecond)).UTC(), true
+	}
+	return time.Unix(n, 0).UTC(), true
+}
+
+// Export writes the entries of s with the given ids as a CSV file. With the
+// default (nil) mapping, the columns are the entry fields and the metadata
+// keys of all entries.
+func Export(w io.Writer, s *store.Store, ids []string, m Mapping) error {
+	if m == nil {
+		m = exportMapping(s, ids)
+	}
+	hasGroup := false
+	mapped := make(map[string]bool) // Metadata keys with a column
+	header := make([]string, len(m))
+	for i, c := range m {
+		header[i] = c.Header
+		hasGroup = hasGroup || c.Field == FieldGroup
+		if strings.HasPrefix(c.Field, MetaPrefix) {
+			mapped[strings.ToLower(strings.TrimPrefix(c.Field, MetaPrefix))] = true
+		}
+	}
+
+	cw := stdcsv.NewWriter(w)
+	cw.Write(header)
+	for _, id := range ids {
+		e := s.Entries[id]
+		if e == nil {
+			return fmt.Errorf("entry '%s' does not exist", id)
+		}
+		title, group := id, ""
+		if i := strings.LastIndex(id, "/"); hasGroup && i >= 0 {
+			title, group = id[i+1:], id[:i]
+		}
+
+		record := make([]string, len(m))
+		for i, c := range m {
+			switch c.Field {
+			case FieldId:
+				record[i] = title
+			case FieldGroup:
+				record[i] = group
+			case FieldName:
+				record[i] = e.Name
+			case FieldPassword:
+				record[i] = string(e.Password)
+			case FieldOTP:
+				if e.OTP != nil {
+					record[i] = e.OTP.URI()
+				}
+			case FieldCtime:
+				record[i] = formatTime(e.Ctime, c.Header)
+			case FieldMtime:
+				record[i] = formatTime(e.Mtime, c.Header)
+			case FieldFields:
+				record[i] = customFields(e, mapped)
+			default:
+				record[i] = metadata(e, strings.TrimPrefix(c.Field, MetaPrefix))
+			}
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// exportMapping returns the default mapping of the entries with the given
+// ids.
+func exportMapping(s *store.Store, ids []string) Mapping {
+	m := Mapping{{FieldId, FieldId}, {FieldName, FieldName}, {FieldPassword, FieldPassword},
+		{FieldOTP, FieldOTP}, {FieldCtime, FieldCtime}, {FieldMtime, FieldMtime}}
+	keys := make(map[string]bool)
+	for _, id := range ids {
+		if e := s.Entries[id]; e != nil {
+			for key := range e.Metadata {
+				keys[key] = true
+			}
+		}
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+	for _, key := range sorted {
+		m = append(m, Column{MetaPrefix + key, key})
+	}
+	return m
+}
+
+// metadata returns the metadata value of e with the given key, which is
+// matched case-insensitively if there is no exact match.
+func metadata(e *store.Entry, key string) string {
+	if v, ok := e.Metadata[key]; ok {
+		return v
+	}
+	for k, v := range e.Metadata {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return ""
+}
+
+// customFields returns the metadata of e that isn't mapped to a column, as
+// "key: value" lines.
+func customFields(e *store.Entry, mapped map[string]bool) string {
+	var lines []string
+	for key, value := range e.Metadata {
+		if !mapped[strings.ToLower(key)] {
+			lines = append(lines, key+": "+value)
+		}
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+func formatTime(t time.Time, header string) string {
+	if unixMillisColumns[header] {
+		return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// importer returns an import function for CSV files with the mapping of the
+// given preset, unless the settings specify a mapping.
+func importer(preset string) func(r io.Reader, settings *util.ImportSettings) (*store.Store, error) {
+	return func(r io.Reader, settings *util.ImportSettings) (*store.Store, error) {
+		m, err := NewMapping(preset, settings.Map)
+		if err != nil {
+			return nil, err
+		}
+		return Import(r, settings, m)
+	}
+}
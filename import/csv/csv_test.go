@@ -0,0 +1,199 @@
+package csv
+
+import (
+	"bytes"
+	"github.com/tvdburgt/passman/import/util"
+	"github.com/tvdburgt/passman/store"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func importFile(t *testing.T, preset string, settings *util.ImportSettings) *store.Store {
+	f, err := os.Open("testdata/" + preset + ".csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	m, err := NewMapping(preset, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Import(f, settings, m)
+	if err != nil {
+		t.Fatalf("%s: %s", preset, err)
+	}
+	return s
+}
+
+// Every preset imports the GitHub login of its test file.
+func TestPresets(t *testing.T) {
+	for preset := range Presets {
+		s := importFile(t, preset, &util.ImportSettings{NameGroups: true})
+		var github *store.Entry
+		for id, e := range s.Entries {
+			if strings.Contains(strings.ToLower(id), "github") {
+				github = e
+			}
+		}
+		if github == nil {
+			t.Errorf("%s: no GitHub entry in %v", preset, s.Ids(nil))
+			continue
+		}
+		if github.Name != "octocat" || string(github.Password) != "hunter2" {
+			t.Errorf("%s: imported %+v", preset, github)
+		}
+	}
+}
+
+func TestImportBitwarden(t *testing.T) {
+	r := &util.Report{}
+	s := importFile(t, "bitwarden", &util.ImportSettings{NameGroups: true, Report: r})
+	if ids := s.Ids(nil); !reflect.DeepEqual(ids, []string{"Wi-Fi", "Work/GitHub"}) {
+		t.Fatalf("imported %v", ids)
+	}
+	github := s.Entries["Work/GitHub"]
+	expected := store.Metadata{
+		"url":   "https://github.com",
+		"notes": "Recovery codes:\nabc, def",
+		"PIN":   "1234",
+	}
+	if !reflect.DeepEqual(github.Metadata, expected) {
+		t.Errorf("metadata %q, expected %q", github.Metadata, expected)
+	}
+	if github.OTP == nil || string(github.OTP.Secret) != "Hello!\xde\xad\xbe\xef" {
+		t.Errorf("otp %+v", github.OTP)
+	}
+	if notes := s.Entries["Wi-Fi"].Metadata["notes"]; notes != `SSID "home"` {
+		t.Errorf("notes %q", notes)
+	}
+	if len(r.Entries) != 2 || r.Entries[0].Source != "Work/GitHub" {
+		t.Errorf("report %+v", r)
+	}
+}
+
+func TestImportLastPass(t *testing.T) {
+	s := importFile(t, "lastpass", &util.ImportSettings{NameGroups: true, NormalizeEntries: true})
+	if ids := s.Ids(nil); !reflect.DeepEqual(ids, []string{"note", "work/dev/github"}) {
+		t.Fatalf("imported %v", ids)
+	}
+	if notes := s.Entries["work/dev/github"].Metadata["notes"]; notes != "line 1\nline 2" {
+		t.Errorf("notes %q", notes)
+	}
+}
+
+func TestImportFirefox(t *testing.T) {
+	s := importFile(t, "firefox", &util.ImportSettings{})
+	e := s.Entries["github.com"]
+	if e == nil {
+		t.Fatalf("imported %v", s.Ids(nil))
+	}
+	ctime := time.Date(2021, 3, 14, 15, 9, 26, 0, time.UTC)
+	if !e.Ctime.Equal(ctime) || !e.Mtime.Equal(ctime.Add(24*time.Hour)) {
+		t.Errorf("ctime %s, mtime %s", e.Ctime, e.Mtime)
+	}
+}
+
+func TestImportChrome(t *testing.T) {
+	r := &util.Report{}
+	s := importFile(t, "chrome", &util.ImportSettings{Report: r})
+	if len(s.Entries) != 1 || len(r.Dropped) != 1 || r.Dropped[0].Source != "line 3" {
+		t.Errorf("imported %v, report %+v", s.Ids(nil), r)
+	}
+}
+
+func TestParseMapping(t *testing.T) {
+	m, err := ParseMapping("id=title, name=username,password=password,meta.url=url")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Mapping{{"id", "title"}, {"name", "username"}, {"password", "password"}, {"meta.url", "url"}}
+	if !reflect.DeepEqual(m, expected) {
+		t.Errorf("parsed %v, expected %v", m, expected)
+	}
+	for _, s := range []string{"", "id", "title=name", "id=", "meta.=url", "id=a,id=b"} {
+		if _, err := ParseMapping(s); err == nil {
+			t.Errorf("parsed %q", s)
+		}
+	}
+	if _, err := NewMapping("dashlane", ""); err == nil {
+		t.Errorf("unknown preset accepted")
+	}
+}
+
+func TestDetect(t *testing.T) {
+	for preset := range Presets {
+		header, err := ioutil.ReadFile("testdata/" + preset + ".csv")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if detected := Detect(header); detected != preset {
+			t.Errorf("detected %q, expected %q", detected, preset)
+		}
+	}
+	for _, header := range []string{"", "name,password\n", "url,username\n", "\"unterminated"} {
+		if detected := Detect([]byte(header)); detected != "" {
+			t.Errorf("%q: detected %q", header, detected)
+		}
+	}
+}
+
+func TestImportMissingColumn(t *testing.T) {
+	m, _ := ParseMapping("id=title")
+	_, err := Import(strings.NewReader("name,password\n"), &util.ImportSettings{}, m)
+	if err == nil {
+		t.Errorf("imported file without title column")
+	}
+}
+
+// The default export is imported without loss, and exports with presets can
+// be imported with the same preset.
+func TestExportRoundTrip(t *testing.T) {
+	s := store.NewStore()
+	mtime := time.Date(2021, 3, 14, 15, 9, 26, 0, time.UTC)
+	for _, id := range []string{"github", "work/mail"} {
+		e := store.NewEntry()
+		e.Name = "user, " + id
+		e.Password = []byte(`"` + id + `"`)
+		e.Ctime, e.Mtime = mtime, mtime.Add(time.Hour)
+		e.Metadata["url"] = "https://" + id
+		e.Metadata["notes"] = "multi\nline"
+		s.Entries[id] = e
+	}
+	s.Entries["work/mail"].Metadata["PIN"] = "1234"
+
+	for _, preset := range []string{"", "bitwarden", "chrome"} {
+		m, err := NewMapping(preset, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		if err := Export(&b, s, s.Ids(nil), m); err != nil {
+			t.Fatal(err)
+		}
+		imported, err := Import(&b, &util.ImportSettings{NameGroups: true}, m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for id, e := range s.Entries {
+			i := imported.Entries[id]
+			if i == nil {
+				t.Errorf("%q: %s not imported (%v)", preset, id, imported.Ids(nil))
+				continue
+			}
+			if preset == "" && !reflect.DeepEqual(i, e) {
+				t.Errorf("imported %+v, expected %+v", i, e)
+			}
+			if preset != "" && (i.Name != e.Name || string(i.Password) != string(e.Password) ||
+				i.Metadata["url"] != e.Metadata["url"] || i.Metadata["notes"] != e.Metadata["notes"]) {
+				t.Errorf("%s: imported %+v, expected %+v", preset, i, e)
+			}
+			if preset == "bitwarden" && i.Metadata["PIN"] != e.Metadata["PIN"] {
+				t.Errorf("custom fields not exported: %+v", i.Metadata)
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package imprt_test
+
+import (
+	"github.com/tvdburgt/passman/import"
+	_ "github.com/tvdburgt/passman/import/bitwarden"
+	_ "github.com/tvdburgt/passman/import/csv"
+	_ "github.com/tvdburgt/passman/import/keepass"
+	_ "github.com/tvdburgt/passman/import/keepass2"
+	_ "github.com/tvdburgt/passman/import/keepassx"
+	_ "github.com/tvdburgt/passman/import/pass"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestDetectFile(t *testing.T) {
+	tests := map[string]string{
+		"testdata/passman.json":                    "passman",
+		"bitwarden/testdata/bitwarden.json":        "bitwarden",
+		"../kdbx/testdata/kdbx4-argon2id-aes.kdbx": "kdbx",
+		"csv/testdata/bitwarden.csv":               "bitwarden-csv",
+		"csv/testdata/chrome.csv":                  "chrome-csv",
+		"csv/testdata/firefox.csv":                 "firefox-csv",
+		"csv/testdata/lastpass.csv":                "lastpass-csv",
+		"csv/testdata/1password.csv":               "1password-csv",
+		"bitwarden/testdata":                       "pass",
+		"../kdbx/testdata/keyfile.keyx":            "",
+	}
+	for name, expected := range tests {
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		i, r, err := imprt.DetectFile(f)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if i == nil && expected != "" || i != nil && i.Name() != expected {
+			t.Errorf("%s: detected %v, expected %q", name, i, expected)
+		}
+
+		// The complete file is read after detection
+		if info, _ := f.Stat(); !info.IsDir() {
+			data, err := ioutil.ReadAll(r)
+			if err != nil || int64(len(data)) != info.Size() {
+				t.Errorf("%s: read %d bytes (%v), expected %d", name, len(data), err, info.Size())
+			}
+		}
+		f.Close()
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := map[string]string{
+		`<?xml version="1.0" encoding="utf-8" standalone="yes"?>` + "\n" +
+			`<KeePassFile><Meta><Generator>KeePass</Generator>`: "keepass2",
+		`<!DOCTYPE KEEPASSX_DATABASE><database><group><title>Internet`: "keepassx",
+		`<?xml version="1.0"?><pwlist><pwentry>`:                       "keepass",
+		`{"encrypted": false, "folders": [{"id": "1", "name": "ite`:    "bitwarden",
+		`{"header": {"version": 0}, "entries": {`:                      "passman",
+		`{"name": "items", "items": 1}`:                                "bitwarden",
+		`{"name": "items"}`:                                            "",
+		`<html><body>`:                                                 "",
+		`name,password`:                                                "",
+		``:                                                             "",
+	}
+	for header, expected := range tests {
+		i := imprt.Detect([]byte(header))
+		if i == nil && expected != "" || i != nil && i.Name() != expected {
+			t.Errorf("%q: detected %v, expected %q", header, i, expected)
+		}
+	}
+}
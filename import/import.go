@@ -1,30 +1,210 @@
+// Package imprt imports the export files of other password managers. Every
+// format is read by an Importer, which its package adds with Register from an
+// init function:
+//
+//	func init() {
+//		imprt.Register(myFormat{})
+//	}
+//
+// Apart from passman's own JSON format, the formats are in the subpackages of
+// this package. A program only has to import such a package (e.g. with a
+// blank import next to the other imports of passman's main package) to
+// support its format.
 package imprt
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
 	"fmt"
-	"github.com/tvdburgt/passman/import/keepass"
-	"github.com/tvdburgt/passman/import/keepass2"
-	"github.com/tvdburgt/passman/import/keepassx"
 	"github.com/tvdburgt/passman/import/util"
 	"github.com/tvdburgt/passman/store"
 	"io"
+	"os"
+	"sort"
+	"sync"
 )
 
-type Settings util.ImportSettings
+// HeaderSize is the maximum number of bytes of an import file that is used to
+// detect its format.
+const HeaderSize = 4096
 
-type importFunc func(r io.Reader, settings *util.ImportSettings) (s *store.Store, err error)
+// An Importer reads the export files of a password manager.
+type Importer interface {
+	// Name returns the name of the format, as given with -format.
+	Name() string
 
-var importers = map[string]importFunc{
-	"keepass":  keepass.Import,
-	"keepass2": keepass2.Import,
-	"keepassx": keepassx.Import,
+	// Detect reports whether header, the first (at most HeaderSize) bytes
+	// of an import file, is in the format of the importer. Formats that
+	// can't be recognized by their contents always return false.
+	Detect(header []byte) bool
+
+	// Import reads the entries of r into a new store. Settings are only
+	// used during the call, so importers can be used concurrently.
+	Import(r io.Reader, settings *util.ImportSettings) (*store.Store, error)
+}
+
+// format is an Importer of the functions of an import package.
+type format struct {
+	name   string
+	detect func(header []byte) bool
+	fn     func(r io.Reader, settings *util.ImportSettings) (*store.Store, error)
+}
+
+// NewFormat returns an Importer of the named format that detects headers with
+// detect (nil if the format can't be detected) and imports files with fn.
+func NewFormat(name string, detect func(header []byte) bool,
+	fn func(r io.Reader, settings *util.ImportSettings) (*store.Store, error)) Importer {
+	return &format{name, detect, fn}
+}
+
+func (f *format) Name() string { return f.name }
+
+func (f *format) Detect(header []byte) bool {
+	return f.detect != nil && f.detect(header)
+}
+
+func (f *format) Import(r io.Reader, settings *util.ImportSettings) (*store.Store, error) {
+	return f.fn(r, settings)
+}
+
+var (
+	mu        sync.RWMutex
+	importers []Importer // In order of registration
+)
+
+func init() {
+	Register(NewFormat("passman", JSONKeyDetector("header"), importPassman))
+}
+
+// Register makes an importer available under its name. It panics if the name
+// is already taken.
+func Register(i Importer) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, registered := range importers {
+		if registered.Name() == i.Name() {
+			panic(fmt.Sprintf("imprt: format %q registered twice", i.Name()))
+		}
+	}
+	importers = append(importers, i)
+}
+
+// Lookup returns the importer of the named format, or nil if there is none.
+func Lookup(name string) Importer {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, i := range importers {
+		if i.Name() == name {
+			return i
+		}
+	}
+	return nil
+}
+
+// Formats returns the sorted names of the registered formats.
+func Formats() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, len(importers))
+	for i, imp := range importers {
+		names[i] = imp.Name()
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Detect returns the first registered importer that detects the format of
+// header, or nil if the format is unknown.
+func Detect(header []byte) Importer {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, i := range importers {
+		if i.Detect(header) {
+			return i
+		}
+	}
+	return nil
+}
+
+// DetectFile detects the format of the import file f. Directories are pass
+// stores (if that format is registered). Otherwise, the header of f is read
+// without losing it: the returned reader reads the complete file.
+func DetectFile(f *os.File) (Importer, io.Reader, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.IsDir() {
+		return Lookup("pass"), f, nil
+	}
+	br := bufio.NewReaderSize(f, HeaderSize)
+	header, err := br.Peek(HeaderSize)
+	if err != nil && err != io.EOF {
+		return nil, nil, err
+	}
+	return Detect(header), br, nil
+}
+
+// XMLRootDetector returns a function that detects XML documents with the given
+// root element.
+func XMLRootDetector(root string) func(header []byte) bool {
+	return func(header []byte) bool {
+		dec := xml.NewDecoder(bytes.NewReader(header))
+		for {
+			t, err := dec.Token()
+			if err != nil {
+				return false
+			}
+			if start, ok := t.(xml.StartElement); ok {
+				return start.Name.Local == root
+			}
+		}
+	}
+}
+
+// JSONKeyDetector returns a function that detects JSON objects with any of
+// the given keys. Only the keys before the end of the header are seen.
+func JSONKeyDetector(keys ...string) func(header []byte) bool {
+	return func(header []byte) bool {
+		dec := json.NewDecoder(bytes.NewReader(header))
+		if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+			return false
+		}
+		for {
+			t, err := dec.Token()
+			key, ok := t.(string)
+			if err != nil || !ok {
+				return false
+			}
+			for _, k := range keys {
+				if key == k {
+					return true
+				}
+			}
+			if err := skipValue(dec); err != nil {
+				return false
+			}
+		}
+	}
 }
 
-func ImportStore(r io.Reader, format string, settings *Settings) (s *store.Store, err error) {
-	if fn, ok := importers[format]; ok {
-		utilSettings := util.ImportSettings(*settings)
-		return fn(r, &utilSettings)
-	} else {
-		return nil, fmt.Errorf("no importer available for format '%s'", format)
+// skipValue reads the next JSON value from dec.
+func skipValue(dec *json.Decoder) error {
+	for depth := 0; ; {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch t {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
 	}
 }
@@ -0,0 +1,57 @@
+package imprt
+
+import (
+	"github.com/tvdburgt/passman/import/util"
+	"github.com/tvdburgt/passman/store"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// upper imports lines of "ID PASSWORD".
+type upper struct{}
+
+func (upper) Name() string { return "test-upper" }
+
+func (upper) Detect(header []byte) bool {
+	return strings.HasPrefix(string(header), "UPPER\n")
+}
+
+func (upper) Import(r io.Reader, settings *util.ImportSettings) (*store.Store, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	s := store.NewStore()
+	for _, line := range strings.Split(string(data), "\n")[1:] {
+		if fields := strings.Fields(line); len(fields) == 2 {
+			e := store.NewEntry()
+			e.Password = []byte(fields[1])
+			s.Entries[fields[0]] = e
+		}
+	}
+	return s, nil
+}
+
+func TestRegister(t *testing.T) {
+	Register(upper{})
+	if Lookup("test-upper") == nil {
+		t.Fatalf("registered importer not found in %v", Formats())
+	}
+	i := Detect([]byte("UPPER\ngithub hunter2\n"))
+	if i == nil || i.Name() != "test-upper" {
+		t.Fatalf("detected %v", i)
+	}
+	s, err := i.Import(strings.NewReader("UPPER\ngithub hunter2\n"), &util.ImportSettings{})
+	if err != nil || string(s.Entries["github"].Password) != "hunter2" {
+		t.Errorf("imported %v (%v)", s, err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("format registered twice")
+		}
+	}()
+	Register(upper{})
+}
@@ -2,6 +2,7 @@ package keepass
 
 import (
 	"encoding/xml"
+	"github.com/tvdburgt/passman/import"
 	"github.com/tvdburgt/passman/import/util"
 	"github.com/tvdburgt/passman/store"
 	"io"
@@ -10,11 +11,12 @@ import (
 
 const timeLayout = "2006-01-02T15:04:05"
 
-var importSettings *util.ImportSettings
+func init() {
+	imprt.Register(imprt.NewFormat("keepass", imprt.XMLRootDetector("pwlist"), Import))
+}
 
 func Import(r io.Reader, settings *util.ImportSettings) (s *store.Store, err error) {
 	var db list
-	importSettings = settings
 	s = store.NewStore()
 	dec := xml.NewDecoder(r)
 
@@ -22,7 +24,7 @@ func Import(r io.Reader, settings *util.ImportSettings) (s *store.Store, err err
 		return
 	}
 	for _, e := range db.Entries {
-		e.sync(s)
+		e.sync(s, settings)
 	}
 	return
 }
@@ -48,14 +50,16 @@ type group struct {
 	Name string `xml:",chardata"`
 }
 
-func (e *entry) sync(s *store.Store) {
+func (e *entry) sync(s *store.Store, settings *util.ImportSettings) {
 	// Skip entries from trash
 	if e.Group.Name == "Recycle Bin" {
+		settings.Report.Drop(e.source(), "in the recycle bin")
 		return
 	}
 
 	// Resolve id
-	id := e.id(s)
+	requested := e.id(settings)
+	id := util.ResolveIdCollisions(s, requested)
 
 	// Build entry
 	ee := &store.Entry{
@@ -72,14 +76,27 @@ func (e *entry) sync(s *store.Store) {
 		ee.Metadata["notes"] = e.Notes
 	}
 	s.Entries[id] = ee
+	settings.Report.Add(e.source(), requested, id, ee)
+}
+
+// source returns the path of e in the import file.
+func (e *entry) source() string {
+	var tree []string
+	if len(e.Group.Tree) > 0 {
+		tree = strings.Split(e.Group.Tree, "\\")
+	}
+	if len(e.Group.Name) > 0 {
+		tree = append(tree, e.Group.Name)
+	}
+	return strings.Join(append(tree, e.Title), "/")
 }
 
-func (e *entry) id(s *store.Store) (id string) {
+func (e *entry) id(settings *util.ImportSettings) (id string) {
 	id = e.Title
 	if len(id) == 0 {
 		id = util.DefaultId
 	}
-	if importSettings.NameGroups && len(e.Group.Name) > 0 {
+	if settings.NameGroups && len(e.Group.Name) > 0 {
 		var tree []string
 		if len(e.Group.Tree) > 0 {
 			tree = strings.Split(e.Group.Tree, "\\")
@@ -88,9 +105,8 @@ func (e *entry) id(s *store.Store) (id string) {
 		tree = append(tree, id)
 		id = strings.Join(tree, "/")
 	}
-	if importSettings.NormalizeEntries {
+	if settings.NormalizeEntries {
 		id = util.Normalize(id)
 	}
-	id = util.ResolveIdCollisions(s, id)
 	return
 }
@@ -0,0 +1,188 @@
+package keepass2
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"github.com/tvdburgt/passman/store"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Generator written by Export
+const exportGenerator = "passman"
+
+// Export writes the entries of s with the given ids as a KeePass 2 XML
+// document. Slash-separated ids are mapped to a tree of groups below a root
+// group with the given name, metadata to custom string fields. With kdbx,
+// the document is meant for a KDBX 4 database: passwords are marked to be
+// protected and times are in the binary format of KDBX 4.
+func Export(w io.Writer, s *store.Store, ids []string, name string, kdbx bool) error {
+	root := &xmlGroup{UUID: newUUID(), Name: name}
+	for _, id := range ids {
+		e := s.Entries[id]
+		if e == nil {
+			return fmt.Errorf("entry '%s' does not exist", id)
+		}
+		path := splitId(id)
+		g := root
+		for _, name := range path[:len(path)-1] {
+			g = g.child(name)
+		}
+		g.Entries = append(g.Entries, newXmlEntry(path[len(path)-1], e, kdbx))
+	}
+
+	doc := xmlFile{Generator: exportGenerator, DatabaseName: name, Root: root}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// splitId splits id into its non-empty, slash-separated components.
+func splitId(id string) []string {
+	var path []string
+	for _, s := range strings.Split(id, "/") {
+		if s != "" {
+			path = append(path, s)
+		}
+	}
+	if len(path) == 0 {
+		path = []string{id}
+	}
+	return path
+}
+
+type xmlFile struct {
+	XMLName      xml.Name  `xml:"KeePassFile"`
+	Generator    string    `xml:"Meta>Generator"`
+	DatabaseName string    `xml:"Meta>DatabaseName"`
+	Root         *xmlGroup `xml:"Root>Group"`
+}
+
+type xmlGroup struct {
+	UUID    string
+	Name    string
+	Entries []*xmlEntry `xml:"Entry"`
+	Groups  []*xmlGroup `xml:"Group"`
+}
+
+// child returns the subgroup of g with the given name, which is added if it
+// doesn't exist.
+func (g *xmlGroup) child(name string) *xmlGroup {
+	for _, c := range g.Groups {
+		if c.Name == name {
+			return c
+		}
+	}
+	c := &xmlGroup{UUID: newUUID(), Name: name}
+	g.Groups = append(g.Groups, c)
+	return c
+}
+
+type xmlEntry struct {
+	UUID    string
+	Times   xmlTimes
+	Strings []xmlString `xml:"String"`
+}
+
+type xmlTimes struct {
+	CreationTime         string
+	LastModificationTime string
+	ExpiryTime           string `xml:",omitempty"`
+	Expires              string
+}
+
+type xmlString struct {
+	Key   string
+	Value struct {
+		Protected       string `xml:",attr,omitempty"` // KDBX
+		ProtectInMemory string `xml:",attr,omitempty"` // XML export
+		Value           string `xml:",chardata"`
+	}
+}
+
+// Standard fields of KeePass entries that metadata keys are mapped to
+var standardFields = []string{"URL", "Notes"}
+
+func newXmlEntry(title string, e *store.Entry, kdbx bool) *xmlEntry {
+	x := &xmlEntry{UUID: newUUID()}
+	x.Times.CreationTime = formatTime(e.Ctime, kdbx)
+	x.Times.LastModificationTime = formatTime(e.Mtime, kdbx)
+	x.Times.Expires = "False"
+	if t, ok := e.ExpiresAt(); ok {
+		x.Times.ExpiryTime = formatTime(t, kdbx)
+		x.Times.Expires = "True"
+	}
+
+	used := make(map[string]bool)
+	add := func(key, value string, protect bool) {
+		// Field names are unique in KeePass
+		base := key
+		for i := 2; used[key]; i++ {
+			key = fmt.Sprintf("%s (%d)", base, i)
+		}
+		used[key] = true
+		s := xmlString{Key: key}
+		s.Value.Value = value
+		if protect && kdbx {
+			s.Value.Protected = "True"
+		} else if protect {
+			s.Value.ProtectInMemory = "True"
+		}
+		x.Strings = append(x.Strings, s)
+	}
+	add("Title", title, false)
+	add("UserName", e.Name, false)
+	add("Password", string(e.Password), true)
+	if e.OTP != nil {
+		add("otp", e.OTP.URI(), true) // KeePassXC
+	}
+
+	keys := make([]string, 0, len(e.Metadata))
+	for key := range e.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		field := key
+		for _, f := range standardFields {
+			if strings.EqualFold(key, f) && !used[f] {
+				field = f
+			}
+		}
+		add(field, e.Metadata[key], false)
+	}
+	return x
+}
+
+// formatTime formats t in the format of XML exports or KDBX 4 databases.
+func formatTime(t time.Time, kdbx bool) string {
+	if !kdbx {
+		return t.UTC().Format(time.RFC3339)
+	}
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], uint64(t.Unix()+unixOffset))
+	return base64.StdEncoding.EncodeToString(b[:])
+}
+
+// newUUID returns a random UUID in base64, as used in KeePass files.
+func newUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = b[6]&0x0f | 0x40 // Version 4
+	b[8] = b[8]&0x3f | 0x80 // Variant 10
+	return base64.StdEncoding.EncodeToString(b[:])
+}
@@ -0,0 +1,185 @@
+package keepass2
+
+import (
+	"bytes"
+	"github.com/tvdburgt/passman/import/util"
+	"github.com/tvdburgt/passman/kdbx"
+	"github.com/tvdburgt/passman/otp"
+	"github.com/tvdburgt/passman/store"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testStore() *store.Store {
+	s := store.NewStore()
+	mtime := time.Date(2021, 3, 14, 15, 9, 26, 0, time.UTC)
+	for _, id := range []string{"github", "work/mail", "work/vpn/office", "work/vpn/home"} {
+		e := store.NewEntry()
+		e.Name = "user-" + id
+		e.Password = []byte("<" + id + "> & wörd")
+		e.Ctime, e.Mtime = mtime, mtime
+		s.Entries[id] = e
+	}
+	s.Entries["github"].Metadata["url"] = "https://github.com"
+	s.Entries["github"].Metadata["Title"] = "Code hosting"
+	s.Entries["github"].OTP = &otp.Key{Type: otp.TOTP, Secret: []byte("12345678901234567890"),
+		Algorithm: otp.DefaultAlgorithm, Digits: otp.DefaultDigits, Period: otp.DefaultPeriod}
+	s.Entries["work/mail"].Expires = &store.Expiry{Interval: 90 * 24 * time.Hour}
+	return s
+}
+
+// Exported entries are imported with the same ids and contents.
+func TestExportRoundTrip(t *testing.T) {
+	s := testStore()
+	for _, isKdbx := range []bool{false, true} {
+		var b bytes.Buffer
+		if err := Export(&b, s, s.Ids(nil), "passman", isKdbx); err != nil {
+			t.Fatal(err)
+		}
+		doc := b.Bytes()
+		if isKdbx {
+			// Decrypting unprotects the passwords
+			if !bytes.Contains(doc, []byte(`Protected="True"`)) {
+				t.Errorf("passwords aren't protected:\n%s", doc)
+			}
+			doc = bytes.Replace(doc, []byte(` Protected="True"`), nil, -1)
+		}
+
+		imported, err := Import(bytes.NewReader(doc), &util.ImportSettings{NameGroups: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(imported.Entries) != len(s.Entries) {
+			t.Errorf("imported %d entries, expected %d:\n%s", len(imported.Entries), len(s.Entries), doc)
+		}
+		for id, e := range s.Entries {
+			i := imported.Entries[id]
+			if i == nil {
+				t.Errorf("%s not imported", id)
+				continue
+			}
+			if i.Name != e.Name || string(i.Password) != string(e.Password) ||
+				!i.Ctime.Equal(e.Ctime) || !i.Mtime.Equal(e.Mtime) {
+				t.Errorf("%s imported as %+v, expected %+v", id, i, e)
+			}
+		}
+		github := imported.Entries["github"]
+		if github.Metadata["URL"] != "https://github.com" ||
+			github.Metadata["Title (2)"] != "Code hosting" ||
+			github.OTP == nil || github.OTP.URI() != s.Entries["github"].OTP.URI() {
+			t.Errorf("github imported as %+v", github)
+		}
+		if !strings.Contains(string(doc), "<Expires>True</Expires>") {
+			t.Errorf("expiry not exported:\n%s", doc)
+		}
+	}
+}
+
+// Exported KDBX documents can be encrypted and decrypted.
+func TestExportKdbx(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping key derivation with default parameters")
+	}
+	s := testStore()
+	var doc, db bytes.Buffer
+	if err := Export(&doc, s, s.Ids(nil), "passman", true); err != nil {
+		t.Fatal(err)
+	}
+	key, err := kdbx.NewKey([]byte("passman"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := kdbx.Encrypt(&db, doc.Bytes(), key); err != nil {
+		t.Fatal(err)
+	}
+	plain, err := kdbx.Decrypt(&db, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, err := Import(bytes.NewReader(plain), &util.ImportSettings{NameGroups: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := imported.Entries["work/vpn/home"]; e == nil || string(e.Password) != "<work/vpn/home> & wörd" {
+		t.Errorf("imported %+v", imported.Entries)
+	}
+}
+
+// Environment variable that makes TestExportKeePassXC fail instead of skip if
+// keepassxc-cli isn't installed. CI jobs have to set it.
+const keepassxcEnvKey = "PASSMAN_TEST_KEEPASSXC"
+
+// Exported KDBX databases are opened by KeePassXC.
+func TestExportKeePassXC(t *testing.T) {
+	if _, err := exec.LookPath("keepassxc-cli"); err != nil {
+		if os.Getenv(keepassxcEnvKey) != "" {
+			t.Fatalf("$%s is set: %s", keepassxcEnvKey, err)
+		}
+		t.Skipf("keepassxc-cli not available (set $%s to require it)", keepassxcEnvKey)
+	}
+	s := testStore()
+	var doc, db bytes.Buffer
+	if err := Export(&doc, s, s.Ids(nil), "passman", true); err != nil {
+		t.Fatal(err)
+	}
+	key, err := kdbx.NewKey([]byte("passman"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := kdbx.Encrypt(&db, doc.Bytes(), key); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "keepass2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "export.kdbx")
+	if err := ioutil.WriteFile(file, db.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// keepassxc runs a command of keepassxc-cli on the exported database
+	keepassxc := func(command string, options []string, args ...string) string {
+		args = append(append(append([]string{command, "-q"}, options...), file), args...)
+		cmd := exec.Command("keepassxc-cli", args...)
+		cmd.Stdin = strings.NewReader("passman\n")
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("keepassxc-cli %s: %s\n%s", strings.Join(args, " "), err, stderr.Bytes())
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	listed := strings.Fields(keepassxc("ls", []string{"-R", "-f"}))
+	for id := range s.Entries {
+		found := false
+		for _, path := range listed {
+			found = found || path == id
+		}
+		if !found {
+			t.Errorf("%s not in %q", id, listed)
+		}
+	}
+	attributes := map[string]string{
+		"github:UserName":        "user-github",
+		"github:URL":             "https://github.com",
+		"work/vpn/home:Password": "<work/vpn/home> & wörd",
+	}
+	for attribute, expected := range attributes {
+		i := strings.Index(attribute, ":")
+		if value := keepassxc("show", []string{"-s", "-a", attribute[i+1:]}, attribute[:i]); value != expected {
+			t.Errorf("%s is %q, expected %q", attribute, value, expected)
+		}
+	}
+	if uri := keepassxc("show", []string{"-s", "-a", "otp"}, "github"); uri != s.Entries["github"].OTP.URI() {
+		t.Errorf("otp is %q", uri)
+	}
+}
@@ -0,0 +1,40 @@
+package keepass2
+
+import (
+	"bytes"
+	"github.com/tvdburgt/passman/crypto"
+	"github.com/tvdburgt/passman/import/util"
+	"github.com/tvdburgt/passman/kdbx"
+	"github.com/tvdburgt/passman/store"
+	"io"
+	"io/ioutil"
+)
+
+// importKdbx decrypts a KeePass 2 database and imports it like a KeePass 2
+// XML export.
+func importKdbx(r io.Reader, settings *util.ImportSettings) (*store.Store, error) {
+	var keyFile, password []byte
+	var err error
+	if settings.KeyFile != "" {
+		if keyFile, err = ioutil.ReadFile(settings.KeyFile); err != nil {
+			return nil, err
+		}
+	}
+	if settings.Password != nil {
+		if password, err = settings.Password(); err != nil {
+			return nil, err
+		}
+		defer crypto.Clear(password)
+	}
+
+	key, err := kdbx.NewKey(password, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := kdbx.Decrypt(r, key)
+	if err != nil {
+		return nil, err
+	}
+	defer crypto.Clear(doc)
+	return Import(bytes.NewReader(doc), settings)
+}
@@ -0,0 +1,52 @@
+package keepass2
+
+import (
+	"github.com/tvdburgt/passman/import/util"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestKdbxImport(t *testing.T) {
+	tests := []struct {
+		file, password, keyFile string
+	}{
+		{"kdbx3-aes.kdbx", "passman", ""},
+		{"kdbx4-argon2d-chacha20.kdbx", "passman", "keyfile.keyx"},
+		{"kdbx4-argon2id-aes.kdbx", "", "keyfile.key"},
+	}
+	mtime := time.Date(2021, 3, 14, 15, 9, 26, 0, time.UTC)
+	for _, test := range tests {
+		f, err := os.Open("../../kdbx/testdata/" + test.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		settings := &util.ImportSettings{NameGroups: true}
+		if test.keyFile != "" {
+			settings.KeyFile = "../../kdbx/testdata/" + test.keyFile
+		}
+		if test.password != "" {
+			password := test.password
+			settings.Password = func() ([]byte, error) { return []byte(password), nil }
+		}
+		s, err := importKdbx(f, settings)
+		f.Close()
+		if err != nil {
+			t.Errorf("%s: %s", test.file, err)
+			continue
+		}
+
+		github, mail := s.Entries["GitHub"], s.Entries["Email/Mail & Calendar"]
+		if len(s.Entries) != 2 || github == nil || mail == nil {
+			t.Errorf("%s: imported %d entries", test.file, len(s.Entries))
+			continue
+		}
+		if string(github.Password) != "correct horse battery staple" || github.Name != "octocat" ||
+			github.Metadata["URL"] != "https://github.com" || !github.Mtime.Equal(mtime) {
+			t.Errorf("%s: imported GitHub as %+v", test.file, github)
+		}
+		if string(mail.Password) != "<p@ss> wörd" {
+			t.Errorf("%s: imported password %q", test.file, mail.Password)
+		}
+	}
+}
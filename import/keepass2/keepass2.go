@@ -1,9 +1,14 @@
 package keepass2
 
 import (
+	"encoding/base64"
+	"encoding/binary"
 	"encoding/xml"
 	"fmt"
+	"github.com/tvdburgt/passman/import"
 	"github.com/tvdburgt/passman/import/util"
+	"github.com/tvdburgt/passman/kdbx"
+	"github.com/tvdburgt/passman/otp"
 	"github.com/tvdburgt/passman/store"
 	"io"
 	"strings"
@@ -12,11 +17,13 @@ import (
 
 const fileGenerator = "KeePass"
 
-var importSettings *util.ImportSettings
+func init() {
+	imprt.Register(imprt.NewFormat("keepass2", imprt.XMLRootDetector("KeePassFile"), Import))
+	imprt.Register(imprt.NewFormat("kdbx", kdbx.Detect, importKdbx))
+}
 
 func Import(r io.Reader, settings *util.ImportSettings) (s *store.Store, err error) {
 	var db database
-	importSettings = settings
 	s = store.NewStore()
 
 	dec := xml.NewDecoder(r)
@@ -25,15 +32,15 @@ func Import(r io.Reader, settings *util.ImportSettings) (s *store.Store, err err
 		return
 	}
 
-	// Sanity check on <Generator> value
-	if db.Generator != fileGenerator {
+	// Sanity check on <Generator> value (KeePassXC writes "KeePassXC")
+	if !strings.HasPrefix(db.Generator, fileGenerator) && db.Generator != exportGenerator {
 		return nil, fmt.Errorf("invalid format: <Generator> contains %q (expecting %q)",
 			db.Generator, fileGenerator)
 	}
 
 	for _, g := range db.Groups {
 		var tree []string
-		g.sync(s, tree)
+		g.sync(s, tree, settings)
 	}
 	return
 }
@@ -54,24 +61,63 @@ type entry struct {
 	Data []struct {
 		Key, Value string
 	} `xml:"String"`
-	Ctime time.Time `xml:"Times>CreationTime"`
-	Mtime time.Time `xml:"Times>LastModificationTime"`
+	Ctime timestamp `xml:"Times>CreationTime"`
+	Mtime timestamp `xml:"Times>LastModificationTime"`
+}
+
+// timestamp is a time in RFC 3339 format or, in KDBX 4 databases, the
+// base64-encoded number of seconds since 0001-01-01 (little endian).
+type timestamp struct {
+	time.Time
 }
 
-func (g *group) sync(s *store.Store, tree []string) {
+// Seconds between 0001-01-01 and the Unix epoch
+const unixOffset = 62135596800
+
+func (t *timestamp) UnmarshalText(text []byte) error {
+	if b, err := base64.StdEncoding.DecodeString(string(text)); err == nil && len(b) == 8 {
+		secs := int64(binary.LittleEndian.Uint64(b))
+		t.Time = time.Unix(secs-unixOffset, 0).UTC()
+		return nil
+	}
+	return t.Time.UnmarshalText(text)
+}
+
+func (g *group) sync(s *store.Store, tree []string, settings *util.ImportSettings) {
+	tree = append(tree, g.Name)
 	if g.Name == "Recycle Bin" {
+		g.drop(tree, "in the recycle bin", settings.Report)
 		return
 	}
-	tree = append(tree, g.Name)
 	for _, child := range g.Groups {
-		child.sync(s, tree)
+		child.sync(s, tree, settings)
+	}
+	for _, e := range g.Entries {
+		e.sync(s, tree, settings)
+	}
+}
+
+// drop reports the entries of g and its subgroups as dropped.
+func (g *group) drop(tree []string, reason string, r *util.Report) {
+	for _, child := range g.Groups {
+		child.drop(append(tree, child.Name), reason, r)
 	}
 	for _, e := range g.Entries {
-		e.sync(s, tree)
+		r.Drop(strings.Join(append(tree, e.title()), "/"), reason)
 	}
 }
 
-func (e *entry) sync(s *store.Store, tree []string) {
+// title returns the value of the Title field of e.
+func (e *entry) title() string {
+	for _, field := range e.Data {
+		if field.Key == "Title" {
+			return field.Value
+		}
+	}
+	return ""
+}
+
+func (e *entry) sync(s *store.Store, tree []string, settings *util.ImportSettings) {
 	var id string
 	ee := store.NewEntry()
 
@@ -84,10 +130,16 @@ func (e *entry) sync(s *store.Store, tree []string) {
 			ee.Password = []byte(field.Value)
 		case "UserName":
 			ee.Name = field.Value
+		case "otp": // KeePassXC one-time password (otpauth URI)
+			if key, err := otp.ParseURI(field.Value); err == nil {
+				ee.OTP = key
+				break
+			}
+			fallthrough
 		default: // Arbitrary metadata fields
 			if len(field.Value) > 0 {
 				key := field.Key
-				if importSettings.NormalizeEntries {
+				if settings.NormalizeEntries {
 					key = util.Normalize(key)
 				}
 				ee.Metadata[key] = field.Value
@@ -95,20 +147,24 @@ func (e *entry) sync(s *store.Store, tree []string) {
 		}
 	}
 
+	source := strings.Join(append(tree, id), "/")
+
 	// Make sure id is non-empty
 	if len(id) == 0 {
 		id = util.DefaultId
 	}
-	if importSettings.NameGroups {
+	if settings.NameGroups {
 		tree = append(tree, id)
 		id = strings.Join(tree[1:], "/") // Discard root (database) group
 	}
-	if importSettings.NormalizeEntries {
+	if settings.NormalizeEntries {
 		id = util.Normalize(id)
 	}
 
+	requested := id
 	id = util.ResolveIdCollisions(s, id)
-	ee.Ctime = e.Ctime
-	ee.Mtime = e.Mtime
+	ee.Ctime = e.Ctime.Time
+	ee.Mtime = e.Mtime.Time
 	s.Entries[id] = ee
+	settings.Report.Add(source, requested, id, ee)
 }
@@ -2,6 +2,7 @@ package keepassx
 
 import (
 	"encoding/xml"
+	"github.com/tvdburgt/passman/import"
 	"github.com/tvdburgt/passman/import/util"
 	"github.com/tvdburgt/passman/store"
 	"io"
@@ -10,11 +11,12 @@ import (
 
 const timeLayout = "2006-01-02T15:04:05"
 
-var importSettings *util.ImportSettings
+func init() {
+	imprt.Register(imprt.NewFormat("keepassx", imprt.XMLRootDetector("database"), Import))
+}
 
 func Import(r io.Reader, settings *util.ImportSettings) (s *store.Store, err error) {
 	var db database
-	importSettings = settings
 	s = store.NewStore()
 	dec := xml.NewDecoder(r)
 
@@ -23,7 +25,7 @@ func Import(r io.Reader, settings *util.ImportSettings) (s *store.Store, err err
 	}
 	for _, g := range db.Groups {
 		var tree []string
-		g.sync(s, tree)
+		g.sync(s, tree, settings)
 	}
 	return
 }
@@ -49,35 +51,45 @@ type entry struct {
 	Mtime    string `xml:"lastmod"`
 }
 
-func (e *entry) id(s *store.Store, tree []string) (id string) {
+func (e *entry) id(tree []string, settings *util.ImportSettings) (id string) {
 	id = e.Title
 	if len(id) == 0 {
 		id = util.DefaultId
 	}
-	if importSettings.NameGroups {
+	if settings.NameGroups {
 		id = strings.Join(tree, "/")
 	}
-	if importSettings.NormalizeEntries {
+	if settings.NormalizeEntries {
 		id = util.Normalize(id)
 	}
-	id = util.ResolveIdCollisions(s, id)
 	return
 }
 
-func (g *group) sync(s *store.Store, tree []string) {
+func (g *group) sync(s *store.Store, tree []string, settings *util.ImportSettings) {
+	tree = append(tree, g.Title)
 	if g.Title == "Backup" || g.Title == "Recycle Bin" {
+		g.drop(tree, "in the "+strings.ToLower(g.Title)+" group", settings.Report)
 		return
 	}
-	tree = append(tree, g.Title)
 	for _, child := range g.Groups {
-		child.sync(s, tree)
+		child.sync(s, tree, settings)
+	}
+	for _, e := range g.Entries {
+		e.sync(s, append(tree, e.Title), settings)
+	}
+}
+
+// drop reports the entries of g and its subgroups as dropped.
+func (g *group) drop(tree []string, reason string, r *util.Report) {
+	for _, child := range g.Groups {
+		child.drop(append(tree, child.Title), reason, r)
 	}
 	for _, e := range g.Entries {
-		e.sync(s, append(tree, e.Title))
+		r.Drop(strings.Join(append(tree, e.Title), "/"), reason)
 	}
 }
 
-func (e *entry) sync(s *store.Store, tree []string) {
+func (e *entry) sync(s *store.Store, tree []string, settings *util.ImportSettings) {
 	// Build entry
 	ee := &store.Entry{
 		Name:     e.Username,
@@ -93,6 +105,8 @@ func (e *entry) sync(s *store.Store, tree []string) {
 		ee.Metadata["comment"] = e.Comment
 	}
 
-	id := e.id(s, tree)
+	requested := e.id(tree, settings)
+	id := util.ResolveIdCollisions(s, requested)
 	s.Entries[id] = ee
+	settings.Report.Add(strings.Join(tree, "/"), requested, id, ee)
 }
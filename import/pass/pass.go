@@ -0,0 +1,206 @@
+// Package pass imports the directory tree of pass, the standard unix password
+// manager (https://www.passwordstore.org/).
+package pass
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/tvdburgt/passman/crypto"
+	"github.com/tvdburgt/passman/import"
+	"github.com/tvdburgt/passman/import/util"
+	"github.com/tvdburgt/passman/otp"
+	"github.com/tvdburgt/passman/store"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultDecryptCommand decrypts a file with the gpg binary; passphrases of
+// secret keys are asked for by gpg-agent.
+const DefaultDecryptCommand = "gpg --quiet --batch --yes --decrypt"
+
+// Metadata keys that hold the user name by convention
+var nameKeys = map[string]bool{
+	"login":    true,
+	"username": true,
+	"user":     true,
+}
+
+func init() {
+	imprt.Register(imprt.NewFormat("pass", nil, importDir))
+}
+
+// Import reads the pass store in directory dir. Every .gpg file is decrypted
+// with settings.DecryptCommand, and its path relative to dir (without the
+// extension) becomes the id of the entry, regardless of settings.NameGroups.
+//
+// Symbolic links are followed, also if dir is one. Broken links and links to
+// a directory that contains them (which would be imported endlessly) are
+// skipped and reported as dropped.
+func Import(dir string, settings *util.ImportSettings) (*store.Store, error) {
+	command := settings.DecryptCommand
+	if command == "" {
+		command = DefaultDecryptCommand
+	}
+
+	s := store.NewStore()
+	if err := walk(s, dir, "", nil, command, settings); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// walk imports the files of dir into s, with ids relative to prefix. Parents
+// are the directories of the links that lead to dir.
+func walk(s *store.Store, dir, prefix string, parents []string, command string, settings *util.ImportSettings) error {
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		source := filepath.ToSlash(filepath.Join(prefix, rel))
+
+		// Skip .git, .extensions and the like
+		hidden := path != root && strings.HasPrefix(info.Name(), ".")
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Stat(path)
+			if err != nil {
+				settings.Report.Drop(source, "broken symbolic link")
+				return nil
+			}
+			if target.IsDir() {
+				if hidden {
+					return nil
+				}
+				resolved, err := filepath.EvalSymlinks(path)
+				if err != nil {
+					return err
+				}
+				parents := append(append([]string(nil), parents...), filepath.Dir(path))
+				for _, parent := range parents {
+					if parent == resolved || strings.HasPrefix(parent, resolved+string(filepath.Separator)) {
+						settings.Report.Drop(source, "symbolic link to a parent directory")
+						return nil
+					}
+				}
+				return walk(s, resolved, source, parents, command, settings)
+			}
+			info = target
+		}
+		if info.IsDir() && hidden {
+			return filepath.SkipDir
+		}
+		if info.IsDir() || filepath.Ext(path) != ".gpg" {
+			return nil
+		}
+
+		source = strings.TrimSuffix(source, ".gpg")
+		plain, err := decrypt(command, path)
+		if err != nil {
+			return fmt.Errorf("%s: %s", source, err)
+		}
+		e := parse(plain, settings)
+		crypto.Clear(plain)
+		e.Ctime, e.Mtime = info.ModTime(), info.ModTime()
+
+		id := source
+		if settings.NormalizeEntries {
+			id = util.Normalize(id)
+		}
+		requested := id
+		id = util.ResolveIdCollisions(s, id)
+		s.Entries[id] = e
+		settings.Report.Add(source, requested, id, e)
+		return nil
+	})
+}
+
+// decrypt runs command with the file at path as its stdin and returns the
+// output. Errors of the command are written to stderr.
+func decrypt(command, path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cmd := exec.Command("/bin/sh", "-c", command)
+	cmd.Stdin = f
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		crypto.Clear(out)
+		return nil, fmt.Errorf("%q: %s", command, err)
+	}
+	return out, nil
+}
+
+// parse converts the contents of a pass file to an entry. The first line is
+// the password; "key: value" lines become metadata, except for the user name
+// and otpauth:// URIs (pass-otp). Other lines are kept as notes.
+func parse(plain []byte, settings *util.ImportSettings) *store.Entry {
+	e := store.NewEntry()
+	var notes []string
+
+	setMetadata := func(key, value string) {
+		if settings.NormalizeEntries {
+			key = util.Normalize(key)
+		}
+		base := key
+		for i := 2; e.Metadata[key] != ""; i++ {
+			key = fmt.Sprintf("%s-%d", base, i)
+		}
+		e.Metadata[key] = value
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(plain))
+	for first := true; scanner.Scan(); first = false {
+		line := scanner.Text()
+		if first {
+			e.Password = []byte(line)
+			continue
+		}
+		if strings.HasPrefix(line, "otpauth://") && e.OTP == nil {
+			if key, err := otp.Parse(line); err == nil {
+				e.OTP = key
+				continue
+			}
+		}
+		i := strings.Index(line, ":")
+		if i <= 0 || strings.ContainsAny(line[:i], " \t") || strings.HasPrefix(line[i:], "://") {
+			if strings.TrimSpace(line) != "" || len(notes) > 0 {
+				notes = append(notes, line)
+			}
+			continue
+		}
+		key, value := line[:i], strings.TrimSpace(line[i+1:])
+		if value == "" {
+			continue
+		}
+		if nameKeys[strings.ToLower(key)] && e.Name == "" {
+			e.Name = value
+		} else {
+			setMetadata(key, value)
+		}
+	}
+	if text := strings.TrimSpace(strings.Join(notes, "\n")); text != "" {
+		setMetadata("notes", text)
+	}
+	return e
+}
+
+// importDir imports the pass store in the directory settings.Path; the reader
+// is not used.
+func importDir(r io.Reader, settings *util.ImportSettings) (*store.Store, error) {
+	return Import(settings.Path, settings)
+}
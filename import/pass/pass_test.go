@@ -0,0 +1,187 @@
+package pass
+
+import (
+	"bytes"
+	"github.com/tvdburgt/passman/import/util"
+	"github.com/tvdburgt/passman/store"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var files = map[string]string{
+	"work/github.gpg": "hunter2\nlogin: octocat\nurl: https://github.com\n" +
+		"otpauth://totp/GitHub:octocat?secret=JBSWY3DPEHPK3PXP\n\nRecovery codes:\nabc-def\n",
+	"mail.gpg":         "secret\nurl: https://mail.example.com\nurl: https://smtp.example.com\n",
+	".git/config.gpg":  "not an entry\n",
+	"work/.gpg-id":     "passman@example.com\n",
+	"work/README.txt":  "not an entry\n",
+	"Social/Forum.gpg": "pw",
+}
+
+// newStore creates a pass store encrypted for a key in a throwaway GnuPG home,
+// which is used by the tests through $GNUPGHOME.
+func newStore(t *testing.T) string {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg not available")
+	}
+	home, err := ioutil.TempDir("", "gnupg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		exec.Command("gpgconf", "--kill", "gpg-agent").Run()
+		os.RemoveAll(home)
+	})
+	t.Setenv("GNUPGHOME", home)
+
+	gpg := func(stdin []byte, args ...string) {
+		cmd := exec.Command("gpg", append([]string{"--batch", "--quiet"}, args...)...)
+		cmd.Stdin = bytes.NewReader(stdin)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("gpg %v: %s\n%s", args, err, out)
+		}
+	}
+	gpg(nil, "--passphrase", "", "--quick-gen-key", "passman@example.com", "default", "default", "never")
+
+	dir := filepath.Join(home, "password-store")
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if filepath.Ext(name) != ".gpg" {
+			if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		gpg([]byte(content), "--trust-model", "always", "--recipient", "passman@example.com",
+			"--output", path, "--encrypt")
+	}
+	return dir
+}
+
+func TestImport(t *testing.T) {
+	dir := newStore(t)
+	r := &util.Report{}
+	s, err := Import(dir, &util.ImportSettings{Report: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := s.Ids(nil); !reflect.DeepEqual(ids, []string{"Social/Forum", "mail", "work/github"}) {
+		t.Fatalf("imported %v", ids)
+	}
+
+	github := s.Entries["work/github"]
+	if github.Name != "octocat" || string(github.Password) != "hunter2" ||
+		github.OTP == nil || github.OTP.Issuer != "GitHub" {
+		t.Errorf("imported %+v", github)
+	}
+	expected := store.Metadata{
+		"url":   "https://github.com",
+		"notes": "Recovery codes:\nabc-def",
+	}
+	if !reflect.DeepEqual(github.Metadata, expected) {
+		t.Errorf("metadata %q, expected %q", github.Metadata, expected)
+	}
+	if m := s.Entries["mail"].Metadata; m["url-2"] != "https://smtp.example.com" {
+		t.Errorf("metadata %q", m)
+	}
+	if p := s.Entries["Social/Forum"].Password; string(p) != "pw" {
+		t.Errorf("password %q", p)
+	}
+	if len(r.Entries) != 3 {
+		t.Errorf("report %+v", r)
+	}
+
+	s, err = Import(dir, &util.ImportSettings{NormalizeEntries: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Entries["social/forum"] == nil {
+		t.Errorf("imported %v", s.Ids(nil))
+	}
+}
+
+func TestImportDecryptCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "plain.gpg"), []byte("pw\nuser: me\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := Import(dir, &util.ImportSettings{DecryptCommand: "cat"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := s.Entries["plain"]; e == nil || string(e.Password) != "pw" || e.Name != "me" {
+		t.Errorf("imported %+v", s.Entries)
+	}
+
+	if _, err := Import(dir, &util.ImportSettings{DecryptCommand: "false"}); err == nil {
+		t.Errorf("failing decrypt command accepted")
+	}
+}
+
+// Symbolic links to files and directories are followed, except for links to
+// parent directories; those and broken links are reported.
+func TestImportSymlinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, content := range map[string]string{
+		"store/a.gpg":       "a",
+		"store/sub/b.gpg":   "b",
+		"outside/c.gpg":     "c",
+		"outside/dir/d.gpg": "d",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, target := range map[string]string{
+		"link":             "store",
+		"store/sub/up":     "..",
+		"store/broken.gpg": "missing.gpg",
+		"store/linked.gpg": "../outside/c.gpg",
+		"store/shared":     "../outside/dir",
+		"outside/dir/back": "../../store",
+	} {
+		if err := os.Symlink(target, filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := &util.Report{}
+	s, err := Import(filepath.Join(dir, "link"), &util.ImportSettings{DecryptCommand: "cat", Report: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := s.Ids(nil); !reflect.DeepEqual(ids, []string{"a", "linked", "shared/d", "sub/b"}) {
+		t.Errorf("imported %v", ids)
+	}
+	if p := s.Entries["linked"].Password; string(p) != "c" {
+		t.Errorf("password %q", p)
+	}
+	expected := []util.ReportDrop{
+		{"broken.gpg", "broken symbolic link"},
+		{"shared/back", "symbolic link to a parent directory"},
+		{"sub/up", "symbolic link to a parent directory"},
+	}
+	if !reflect.DeepEqual(r.Dropped, expected) {
+		t.Errorf("dropped %+v, expected %+v", r.Dropped, expected)
+	}
+}
@@ -3,23 +3,60 @@ package imprt
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/tvdburgt/passman/import/util"
 	"github.com/tvdburgt/passman/store"
 	"io"
+	"sort"
 )
 
-type PassmanFormat store.Store
+// ExportStore writes s in the JSON format read by the passman importer. The
+// format contains the entries and the header parameters, but not the salt.
+func ExportStore(w io.Writer, s *store.Store) error {
+	content, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", content)
+	return err
+}
 
-func (pf *PassmanFormat) Import(r io.Reader) (s *store.Store, err error) {
-	// TODO: will this work?
-	// s = store.NewStore()
-	s = &store.Store{}
-	dec := json.NewDecoder(r)
-	if err = dec.Decode(s); err != nil {
-		return
+// importPassman reads a store written by ExportStore. Entries are kept as
+// is, unless normalization is requested.
+func importPassman(r io.Reader, settings *util.ImportSettings) (*store.Store, error) {
+	// Start from a new store, so the signature (which isn't exported) is set
+	src := store.NewStore()
+	if err := json.NewDecoder(r).Decode(src); err != nil {
+		return nil, err
 	}
-	if s.Version != store.Version {
+	if src.Version != store.Version {
 		return nil, fmt.Errorf("incorrect store version %d (expected %d)",
-			s.Version, store.Version)
+			src.Version, store.Version)
+	}
+	// The params protect the store the entries are imported into
+	if err := src.Params.Validate(); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(src.Entries))
+	for id := range src.Entries {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	s := store.NewStore()
+	s.Header = src.Header
+	for _, id := range ids {
+		e := src.Entries[id]
+		if e.Metadata == nil {
+			e.Metadata = make(store.Metadata)
+		}
+		requested := id
+		if settings.NormalizeEntries {
+			requested = util.Normalize(id)
+		}
+		newId := util.ResolveIdCollisions(s, requested)
+		s.Entries[newId] = e
+		settings.Report.Add(id, requested, newId, e)
 	}
-	return
+	return s, nil
 }
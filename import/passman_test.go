@@ -0,0 +1,58 @@
+package imprt
+
+import (
+	"bytes"
+	"github.com/tvdburgt/passman/import/util"
+	"github.com/tvdburgt/passman/store"
+	"io/ioutil"
+	"testing"
+)
+
+// Exporting an imported store reproduces the export file byte for byte.
+func TestPassmanRoundTrip(t *testing.T) {
+	golden, err := ioutil.ReadFile("testdata/passman.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := importPassman(bytes.NewReader(golden), &util.ImportSettings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	if err := ExportStore(&b, s); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b.Bytes(), golden) {
+		t.Errorf("export differs from testdata/passman.json:\n%s", b.Bytes())
+	}
+
+	// The imported header must be usable for writing the store
+	if s.Params != (store.ScryptParams{LogN: 16, R: 8, P: 2}) {
+		t.Errorf("params %+v, expected those of the export", s.Params)
+	}
+	var header bytes.Buffer
+	if err := s.Header.Marshal(&header); err != nil {
+		t.Fatal(err)
+	}
+	if err := new(store.Header).Unmarshal(&header); err != nil {
+		t.Errorf("imported header: %s", err)
+	}
+}
+
+func TestPassmanImportErrors(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"[]",
+		`{"header": {"version": 1}, "entries": {}}`,
+		`{"header": {"version": 0, "params": {"log_n": 0, "r": 8, "p": 1}}, "entries": {}}`,
+		`{"header": {"version": 0, "params": {"log_n": 1, "r": 8, "p": 1}}, "entries": {}}`,
+		`{"header": {"version": 0, "params": {"log_n": 14, "r": 0, "p": 1}}, "entries": {}}`,
+		`{"header": {"version": 0, "params": {"log_n": 14, "r": 1073741824, "p": 1}}, "entries": {}}`,
+	} {
+		_, err := importPassman(bytes.NewReader([]byte(input)), &util.ImportSettings{})
+		if err == nil {
+			t.Errorf("imported %q", input)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package util
+
+import (
+	"fmt"
+	"github.com/tvdburgt/passman/store"
+	"reflect"
+	"sort"
+)
+
+// Strategies for entries that exist in both stores of Merge
+const (
+	MergeSkip      = "skip"       // Keep the existing entry
+	MergeOverwrite = "overwrite"  // Replace the existing entry
+	MergeRename    = "rename"     // Add the entry under a new id
+	MergeNewerWins = "newer-wins" // Keep the most recently modified entry
+)
+
+// MergeStrategies lists the valid strategies of Merge.
+var MergeStrategies = []string{MergeSkip, MergeOverwrite, MergeRename, MergeNewerWins}
+
+// ValidStrategy reports whether strategy is one of MergeStrategies.
+func ValidStrategy(strategy string) bool {
+	for _, s := range MergeStrategies {
+		if s == strategy {
+			return true
+		}
+	}
+	return false
+}
+
+// MergeResult lists the ids of the entries merged into a store.
+type MergeResult struct {
+	Added   []string
+	Updated []string
+	Skipped []string
+
+	// Renamed maps the ids of entries added under a new id (by the
+	// rename strategy) to their new id.
+	Renamed map[string]string
+}
+
+// Merge adds the entries of src to dst, resolving conflicting ids with the
+// given strategy. Entries that are identical in both stores are always
+// skipped.
+func Merge(dst, src *store.Store, strategy string) (*MergeResult, error) {
+	if !ValidStrategy(strategy) {
+		return nil, fmt.Errorf("unknown merge strategy %q", strategy)
+	}
+
+	ids := make([]string, 0, len(src.Entries))
+	for id := range src.Entries {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	r := &MergeResult{Renamed: make(map[string]string)}
+	for _, id := range ids {
+		e := src.Entries[id]
+		old, ok := dst.Entries[id]
+		switch {
+		case !ok:
+			dst.Entries[id] = e
+			r.Added = append(r.Added, id)
+		case reflect.DeepEqual(old, e):
+			r.Skipped = append(r.Skipped, id)
+		case strategy == MergeOverwrite,
+			strategy == MergeNewerWins && e.Mtime.After(old.Mtime):
+			dst.Entries[id] = e
+			r.Updated = append(r.Updated, id)
+		case strategy == MergeRename:
+			// The new id must not be taken by another entry of src
+			newId := id
+			for i := 2; dst.Entries[newId] != nil || newId != id && src.Entries[newId] != nil; i++ {
+				newId = fmt.Sprintf("%s-%d", id, i)
+			}
+			dst.Entries[newId] = e
+			r.Added = append(r.Added, newId)
+			r.Renamed[id] = newId
+		default:
+			r.Skipped = append(r.Skipped, id)
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,106 @@
+package util
+
+import (
+	"github.com/tvdburgt/passman/store"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newEntry(password string, mtime time.Time) *store.Entry {
+	e := store.NewEntry()
+	e.Password = []byte(password)
+	e.Ctime, e.Mtime = mtime, mtime
+	return e
+}
+
+func TestMerge(t *testing.T) {
+	old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	recent := old.AddDate(1, 0, 0)
+
+	tests := []struct {
+		strategy  string
+		passwords map[string]string
+		result    MergeResult
+	}{
+		{MergeSkip,
+			map[string]string{"a": "a", "b": "b", "same": "same", "new": "new"},
+			MergeResult{Added: []string{"new"}, Skipped: []string{"a", "b", "same"}},
+		},
+		{MergeOverwrite,
+			map[string]string{"a": "a2", "b": "b2", "same": "same", "new": "new"},
+			MergeResult{Added: []string{"new"}, Updated: []string{"a", "b"}, Skipped: []string{"same"}},
+		},
+		{MergeRename,
+			map[string]string{"a": "a", "a-2": "a2", "b": "b", "b-2": "b2", "same": "same", "new": "new"},
+			MergeResult{Added: []string{"a-2", "b-2", "new"}, Skipped: []string{"same"},
+				Renamed: map[string]string{"a": "a-2", "b": "b-2"}},
+		},
+		{MergeNewerWins,
+			map[string]string{"a": "a2", "b": "b", "same": "same", "new": "new"},
+			MergeResult{Added: []string{"new"}, Updated: []string{"a"}, Skipped: []string{"b", "same"}},
+		},
+	}
+	for _, test := range tests {
+		dst := store.NewStore()
+		dst.Entries["a"] = newEntry("a", old)
+		dst.Entries["b"] = newEntry("b", recent)
+		dst.Entries["same"] = newEntry("same", old)
+		src := store.NewStore()
+		src.Entries["a"] = newEntry("a2", recent) // Newer
+		src.Entries["b"] = newEntry("b2", old)    // Older
+		src.Entries["same"] = newEntry("same", old)
+		src.Entries["new"] = newEntry("new", old)
+
+		r, err := Merge(dst, src, test.strategy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.result.Renamed == nil {
+			test.result.Renamed = make(map[string]string)
+		}
+		if !reflect.DeepEqual(*r, test.result) {
+			t.Errorf("%s: result %+v, expected %+v", test.strategy, *r, test.result)
+		}
+		passwords := make(map[string]string)
+		for id, e := range dst.Entries {
+			passwords[id] = string(e.Password)
+		}
+		if !reflect.DeepEqual(passwords, test.passwords) {
+			t.Errorf("%s: merged passwords %v, expected %v", test.strategy, passwords, test.passwords)
+		}
+	}
+
+	if _, err := Merge(store.NewStore(), store.NewStore(), "theirs"); err == nil {
+		t.Error("Merge succeeded with an unknown strategy")
+	}
+}
+
+// Renamed entries don't take the ids of other imported entries.
+func TestMergeRenameReserved(t *testing.T) {
+	mtime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	dst := store.NewStore()
+	dst.Entries["github"] = newEntry("old", mtime)
+	src := store.NewStore()
+	src.Entries["github"] = newEntry("new", mtime)
+	src.Entries["github-2"] = newEntry("second", mtime)
+
+	r, err := Merge(dst, src, MergeRename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := MergeResult{
+		Added:   []string{"github-3", "github-2"},
+		Renamed: map[string]string{"github": "github-3"},
+	}
+	if !reflect.DeepEqual(*r, expected) {
+		t.Errorf("result %+v, expected %+v", *r, expected)
+	}
+	passwords := make(map[string]string)
+	for id, e := range dst.Entries {
+		passwords[id] = string(e.Password)
+	}
+	if p := map[string]string{"github": "old", "github-2": "second", "github-3": "new"}; !reflect.DeepEqual(passwords, p) {
+		t.Errorf("merged passwords %v, expected %v", passwords, p)
+	}
+}
@@ -0,0 +1,82 @@
+package util
+
+import (
+	"github.com/tvdburgt/passman/store"
+	"sort"
+)
+
+// Report describes how the entries of an import file are converted, without
+// revealing passwords.
+type Report struct {
+	Entries []ReportEntry `json:"entries"`
+	Dropped []ReportDrop  `json:"dropped"`
+}
+
+// ReportEntry describes an imported entry.
+type ReportEntry struct {
+	Id       string   `json:"id"`
+	Source   string   `json:"source"`             // Title or path in the import file
+	Renamed  bool     `json:"renamed"`            // Id changed to resolve a collision
+	Metadata []string `json:"metadata,omitempty"` // Fields mapped to metadata
+	Merge    string   `json:"merge,omitempty"`    // Outcome of a merge, see Merged
+}
+
+// Outcomes of merging a reported entry into a store
+const (
+	MergeAdded   = "added"
+	MergeUpdated = "updated"
+	MergeSkipped = "skipped"
+)
+
+// ReportDrop describes an entry of the import file that is not imported.
+type ReportDrop struct {
+	Source string `json:"source"`
+	Reason string `json:"reason"`
+}
+
+// Add records that the entry at source is imported as e with the given id.
+// Requested is the id before collisions were resolved. Add does nothing if r
+// is nil.
+func (r *Report) Add(source, requested, id string, e *store.Entry) {
+	if r == nil {
+		return
+	}
+	var keys []string
+	for key := range e.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	r.Entries = append(r.Entries, ReportEntry{id, source, id != requested, keys, ""})
+}
+
+// Merged records the outcome of merging the reported entries into a store.
+// Entries that are added under a new id get that id and are marked as
+// renamed.
+func (r *Report) Merged(m *MergeResult) {
+	outcomes := make(map[string]string)
+	for _, id := range m.Added {
+		outcomes[id] = MergeAdded
+	}
+	for _, id := range m.Updated {
+		outcomes[id] = MergeUpdated
+	}
+	for _, id := range m.Skipped {
+		outcomes[id] = MergeSkipped
+	}
+	for i := range r.Entries {
+		e := &r.Entries[i]
+		if id, ok := m.Renamed[e.Id]; ok {
+			e.Id, e.Renamed = id, true
+		}
+		e.Merge = outcomes[e.Id]
+	}
+}
+
+// Drop records that the entry at source is not imported. Drop does nothing
+// if r is nil.
+func (r *Report) Drop(source, reason string) {
+	if r == nil {
+		return
+	}
+	r.Dropped = append(r.Dropped, ReportDrop{source, reason})
+}
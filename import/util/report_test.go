@@ -0,0 +1,55 @@
+package util
+
+import (
+	"github.com/tvdburgt/passman/store"
+	"reflect"
+	"testing"
+)
+
+func TestReport(t *testing.T) {
+	e := store.NewEntry()
+	e.Metadata["url"] = "https://example.com"
+	e.Metadata["notes"] = "..."
+
+	var r *Report
+	r.Add("Root/a", "a", "a", e) // No-op
+	r.Drop("Root/b", "in the recycle bin")
+
+	r = &Report{}
+	r.Add("Root/a", "a", "a", e)
+	r.Add("Other/a", "a", "a-2", store.NewEntry())
+	r.Drop("Root/b", "in the recycle bin")
+	expected := &Report{
+		Entries: []ReportEntry{
+			{"a", "Root/a", false, []string{"notes", "url"}, ""},
+			{"a-2", "Other/a", true, nil, ""},
+		},
+		Dropped: []ReportDrop{{"Root/b", "in the recycle bin"}},
+	}
+	if !reflect.DeepEqual(r, expected) {
+		t.Errorf("report %+v, expected %+v", r, expected)
+	}
+}
+
+// The outcome of a merge is reported for the ids in the merged store.
+func TestReportMerged(t *testing.T) {
+	r := &Report{}
+	for _, id := range []string{"github", "mail", "new", "same"} {
+		r.Add(id, id, id, store.NewEntry())
+	}
+	r.Merged(&MergeResult{
+		Added:   []string{"github-2", "new"},
+		Updated: []string{"mail"},
+		Skipped: []string{"same"},
+		Renamed: map[string]string{"github": "github-2"},
+	})
+	expected := []ReportEntry{
+		{"github-2", "github", true, nil, MergeAdded},
+		{"mail", "mail", false, nil, MergeUpdated},
+		{"new", "new", false, nil, MergeAdded},
+		{"same", "same", false, nil, MergeSkipped},
+	}
+	if !reflect.DeepEqual(r.Entries, expected) {
+		t.Errorf("entries %+v, expected %+v", r.Entries, expected)
+	}
+}
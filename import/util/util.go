@@ -19,6 +19,29 @@ type ImportSettings struct {
 	// metadata keys, etc.) are 'normalized'.  A normalized value does not
 	// contain whitespace and uppercase characters.
 	NormalizeEntries bool
+
+	// Report, if non-nil, receives a description of every imported and
+	// dropped entry.
+	Report *Report
+
+	// KeyFile is the key file of an encrypted import file (kdbx), if any.
+	KeyFile string
+
+	// Password, if non-nil, is called to obtain the password of an
+	// encrypted import file (kdbx, Bitwarden).
+	Password func() ([]byte, error)
+
+	// Map is the column mapping of CSV files (see csv.ParseMapping). It
+	// overrides the mapping of the format's preset.
+	Map string
+
+	// Path is the name of the import file or directory. Importers of
+	// directory trees (pass) read from it instead of the reader.
+	Path string
+
+	// DecryptCommand is the shell command that decrypts the files of a
+	// pass store, read from its stdin.
+	DecryptCommand string
 }
 
 // Resolves id collisions by appending a unique number
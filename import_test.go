@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/tvdburgt/passman/import"
+	"github.com/tvdburgt/passman/import/util"
+	"github.com/tvdburgt/passman/store"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// A dry run with -merge reports the collisions with the entries of the store,
+// which is left as is.
+func TestImportDryRunMerge(t *testing.T) {
+	s := testStoreEntries()
+	dir := tempStore(t, s)
+	before, err := ioutil.ReadFile(storeFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := store.NewStore()
+	src.Entries["a"] = s.Entries["a"]
+	src.Entries["b"] = store.NewEntry()
+	src.Entries["b"].Password = []byte("changed")
+	src.Entries["new"] = store.NewEntry()
+	f, err := os.Create(filepath.Join(dir, "export.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = imprt.ExportStore(f, src)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(dryRun, merge bool, conflict, report string) {
+		importDryRun, importMerge, importConflict, importReport = dryRun, merge, conflict, report
+	}(importDryRun, importMerge, importConflict, importReport)
+	importDryRun, importMerge, importConflict, importReport = true, true, util.MergeRename, "json"
+
+	out, err := os.Create(filepath.Join(dir, "report.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	stdout := os.Stdout
+	os.Stdout = out
+	err = runImport(cmdImport, []string{f.Name()})
+	os.Stdout = stdout
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var report util.Report
+	out.Seek(0, 0)
+	if err := json.NewDecoder(out).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	expected := []util.ReportEntry{
+		{"a", "a", false, nil, util.MergeSkipped},
+		{"b-2", "b", true, nil, util.MergeAdded},
+		{"new", "new", false, nil, util.MergeAdded},
+	}
+	if !reflect.DeepEqual(report.Entries, expected) {
+		t.Errorf("entries %+v, expected %+v", report.Entries, expected)
+	}
+	if after, err := ioutil.ReadFile(storeFile); err != nil || string(after) != string(before) {
+		t.Errorf("store modified (%v)", err)
+	}
+}
@@ -9,7 +9,7 @@ import (
 
 var cmdInit = &Command{
 	Run:       runInit,
-	UsageLine: "init [-f <file>]",
+	UsageLine: "init [-f <file>] [-min-score n]",
 	Short:     "create empty passman store file",
 	Long: `
 JSON-formatted, defaults to stdout.
@@ -17,21 +17,33 @@ JSON-formatted, defaults to stdout.
   -f, -file <store-file>
 	override default store file (default file location is $HOME/.pass_store
 	or $PASS_STORE, if set)
+
+  -min-score n
+	minimum estimated strength of the passphrase, from 0 (too guessable)
+	to 4 (very unguessable); the default is 3, which corresponds to about
+	10^8 guesses
 	`,
 }
 
 func init() {
 	addFileFlag(cmdInit)
+	addMinScoreFlag(cmdInit)
 }
 
-func runInit(cmd *Command, args []string) {
+func runInit(cmd *Command, args []string) error {
 	// Read file and make sure it doesn't exist
 	if _, err := os.Stat(storeFile); err == nil {
-		fatalf("passman init: '%s' already exists", storeFile)
+		return fmt.Errorf("passman init: '%s' already exists", storeFile)
+	}
+	passphrase, err := newStorePassphrase()
+	if err != nil {
+		return fmt.Errorf("passman init: %s", err)
 	}
-	passphrase := readVerifiedPassphrase()
 	defer crypto.Clear(passphrase)
 	s := store.NewStore()
-	writeStore(s, passphrase)
+	if err := saveStore(s, passphrase); err != nil {
+		return err
+	}
 	fmt.Printf("Initialized empty passman store at '%s'.\n", storeFile)
+	return nil
 }
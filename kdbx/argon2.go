@@ -0,0 +1,233 @@
+package kdbx
+
+import (
+	"encoding/binary"
+	"golang.org/x/crypto/blake2b"
+	"math/bits"
+)
+
+// Argon2 (RFC 9106, version 0x13). golang.org/x/crypto/argon2 only provides
+// Argon2i and Argon2id, while KeePass uses Argon2d by default.
+
+// Argon2 variants
+const (
+	argon2d  = 0
+	argon2id = 2
+)
+
+const (
+	argon2Version = 0x13
+	blockLength   = 128 // 64-bit words per 1 KiB block
+	syncPoints    = 4   // Slices per pass
+)
+
+type block [blockLength]uint64
+
+// argon2Key derives a key of keyLen bytes. Memory is given in KiB.
+func argon2Key(mode int, password, salt, secret, data []byte, time, memory, threads, keyLen uint32) []byte {
+	h0 := argon2Hash(mode, password, salt, secret, data, time, memory, threads, keyLen)
+
+	// Round memory down to a multiple of 4 blocks per lane
+	memory = memory / (syncPoints * threads) * (syncPoints * threads)
+	if memory < 2*syncPoints*threads {
+		memory = 2 * syncPoints * threads
+	}
+	lanes := memory / threads // Blocks per lane
+	segments := lanes / syncPoints
+	B := make([]block, memory)
+
+	// First two blocks of each lane
+	var buf [1024]byte
+	for lane := uint32(0); lane < threads; lane++ {
+		for i := uint32(0); i < 2; i++ {
+			binary.LittleEndian.PutUint32(h0[64:], i)
+			binary.LittleEndian.PutUint32(h0[68:], lane)
+			blake2bLong(buf[:], h0[:])
+			for j := range B[lane*lanes+i] {
+				B[lane*lanes+i][j] = binary.LittleEndian.Uint64(buf[j*8:])
+			}
+		}
+	}
+
+	for n := uint32(0); n < time; n++ {
+		for slice := uint32(0); slice < syncPoints; slice++ {
+			for lane := uint32(0); lane < threads; lane++ {
+				fillSegment(B, mode, n, slice, lane, time, memory, lanes, segments, threads)
+			}
+		}
+	}
+
+	// XOR the last blocks of all lanes
+	final := B[lanes-1]
+	for lane := uint32(1); lane < threads; lane++ {
+		for i, v := range B[lane*lanes+lanes-1] {
+			final[i] ^= v
+		}
+	}
+	for i, v := range final {
+		binary.LittleEndian.PutUint64(buf[i*8:], v)
+	}
+	key := make([]byte, keyLen)
+	blake2bLong(key, buf[:])
+	return key
+}
+
+// argon2Hash returns the initial hash H0, with room for the block and lane
+// numbers that follow it in the computation of the first blocks.
+func argon2Hash(mode int, password, salt, secret, data []byte, time, memory, threads, keyLen uint32) []byte {
+	h, _ := blake2b.New512(nil)
+	var params [24]byte
+	for i, v := range []uint32{threads, keyLen, memory, time, argon2Version, uint32(mode)} {
+		binary.LittleEndian.PutUint32(params[i*4:], v)
+	}
+	h.Write(params[:])
+	for _, b := range [][]byte{password, salt, secret, data} {
+		var n [4]byte
+		binary.LittleEndian.PutUint32(n[:], uint32(len(b)))
+		h.Write(n[:])
+		h.Write(b)
+	}
+	return h.Sum(make([]byte, 0, 72))[:72]
+}
+
+func fillSegment(B []block, mode int, n, slice, lane, time, memory, lanes, segments, threads uint32) {
+	var addresses, in, zero block
+	independent := mode == argon2id && n == 0 && slice < syncPoints/2
+	if independent {
+		in[0], in[1], in[2] = uint64(n), uint64(lane), uint64(slice)
+		in[3], in[4], in[5] = uint64(memory), uint64(time), uint64(mode)
+	}
+
+	index := uint32(0)
+	if n == 0 && slice == 0 {
+		index = 2 // The first two blocks are already computed
+		if independent {
+			in[6]++
+			compress(&addresses, &in, &zero, false)
+			compress(&addresses, &addresses, &zero, false)
+		}
+	}
+
+	offset := lane*lanes + slice*segments + index
+	for ; index < segments; index, offset = index+1, offset+1 {
+		prev := offset - 1
+		if index == 0 && slice == 0 {
+			prev += lanes // Last block of the lane
+		}
+		var random uint64
+		if independent {
+			if index%blockLength == 0 {
+				in[6]++
+				compress(&addresses, &in, &zero, false)
+				compress(&addresses, &addresses, &zero, false)
+			}
+			random = addresses[index%blockLength]
+		} else {
+			random = B[prev][0]
+		}
+		ref := refIndex(random, lanes, segments, threads, n, slice, lane, index)
+		compress(&B[offset], &B[prev], &B[ref], true)
+	}
+}
+
+// refIndex returns the index of the reference block for the given
+// pseudo-random value.
+func refIndex(random uint64, lanes, segments, threads, n, slice, lane, index uint32) uint32 {
+	refLane := uint32(random>>32) % threads
+	if n == 0 && slice == 0 {
+		refLane = lane
+	}
+	m, s := 3*segments, ((slice+1)%syncPoints)*segments
+	if lane == refLane {
+		m += index
+	}
+	if n == 0 {
+		m, s = slice*segments, 0
+		if slice == 0 || lane == refLane {
+			m += index
+		}
+	}
+	if index == 0 || lane == refLane {
+		m--
+	}
+	x := random & 0xffffffff
+	x = x * x >> 32
+	x = x * uint64(m) >> 32
+	return refLane*lanes + uint32((uint64(s)+uint64(m)-(x+1))%uint64(lanes))
+}
+
+// compress computes the compression function G of in1 and in2 and stores
+// it in out, or XORs it with out.
+func compress(out, in1, in2 *block, xor bool) {
+	var t block
+	for i := range t {
+		t[i] = in1[i] ^ in2[i]
+	}
+	for i := 0; i < blockLength; i += 16 {
+		blamka(&t[i], &t[i+1], &t[i+2], &t[i+3], &t[i+4], &t[i+5], &t[i+6], &t[i+7],
+			&t[i+8], &t[i+9], &t[i+10], &t[i+11], &t[i+12], &t[i+13], &t[i+14], &t[i+15])
+	}
+	for i := 0; i < blockLength/8; i += 2 {
+		blamka(&t[i], &t[i+1], &t[16+i], &t[16+i+1], &t[32+i], &t[32+i+1], &t[48+i], &t[48+i+1],
+			&t[64+i], &t[64+i+1], &t[80+i], &t[80+i+1], &t[96+i], &t[96+i+1], &t[112+i], &t[112+i+1])
+	}
+	for i := range t {
+		if xor {
+			out[i] ^= in1[i] ^ in2[i] ^ t[i]
+		} else {
+			out[i] = in1[i] ^ in2[i] ^ t[i]
+		}
+	}
+}
+
+// blamka is the BLAKE2b round function with the multiplications of Argon2.
+func blamka(v0, v1, v2, v3, v4, v5, v6, v7, v8, v9, v10, v11, v12, v13, v14, v15 *uint64) {
+	g(v0, v4, v8, v12)
+	g(v1, v5, v9, v13)
+	g(v2, v6, v10, v14)
+	g(v3, v7, v11, v15)
+	g(v0, v5, v10, v15)
+	g(v1, v6, v11, v12)
+	g(v2, v7, v8, v13)
+	g(v3, v4, v9, v14)
+}
+
+func g(a, b, c, d *uint64) {
+	*a += *b + 2*uint64(uint32(*a))*uint64(uint32(*b))
+	*d = bits.RotateLeft64(*d^*a, -32)
+	*c += *d + 2*uint64(uint32(*c))*uint64(uint32(*d))
+	*b = bits.RotateLeft64(*b^*c, -24)
+	*a += *b + 2*uint64(uint32(*a))*uint64(uint32(*b))
+	*d = bits.RotateLeft64(*d^*a, -16)
+	*c += *d + 2*uint64(uint32(*c))*uint64(uint32(*d))
+	*b = bits.RotateLeft64(*b^*c, -63)
+}
+
+// blake2bLong is the variable length hash function H' of Argon2.
+func blake2bLong(out, in []byte) {
+	var n [4]byte
+	binary.LittleEndian.PutUint32(n[:], uint32(len(out)))
+	if len(out) <= blake2b.Size {
+		h, _ := blake2b.New(len(out), nil)
+		h.Write(n[:])
+		h.Write(in)
+		h.Sum(out[:0])
+		return
+	}
+
+	h, _ := blake2b.New512(nil)
+	h.Write(n[:])
+	h.Write(in)
+	v := h.Sum(nil)
+	for len(out) > blake2b.Size {
+		copy(out, v[:32])
+		out = out[32:]
+		if len(out) > blake2b.Size {
+			v2 := blake2b.Sum512(v)
+			v = v2[:]
+		}
+	}
+	h, _ = blake2b.New(len(out), nil)
+	h.Write(v)
+	h.Sum(out[:0])
+}
@@ -0,0 +1,42 @@
+package kdbx
+
+import (
+	"bytes"
+	"encoding/hex"
+	"golang.org/x/crypto/argon2"
+	"testing"
+)
+
+// Test vectors of RFC 9106, section 5.
+func TestArgon2(t *testing.T) {
+	password := bytes.Repeat([]byte{0x01}, 32)
+	salt := bytes.Repeat([]byte{0x02}, 16)
+	secret := bytes.Repeat([]byte{0x03}, 8)
+	data := bytes.Repeat([]byte{0x04}, 12)
+
+	tests := []struct {
+		mode int
+		tag  string
+	}{
+		{argon2d, "512b391b6f1162975371d30919734294f868e3be3984f3c1a13a4db9fabe4acb"},
+		{argon2id, "0d640df58d78766c08c037a34a8b53c9d01ef0452d75b65eb52520e96b01e659"},
+	}
+	for _, test := range tests {
+		tag := argon2Key(test.mode, password, salt, secret, data, 3, 32, 4, 32)
+		if hex.EncodeToString(tag) != test.tag {
+			t.Errorf("mode %d: tag %x, expected %s", test.mode, tag, test.tag)
+		}
+	}
+}
+
+// Argon2id agrees with golang.org/x/crypto/argon2, also for multiple passes
+// over larger memory and keys longer than one BLAKE2b hash.
+func TestArgon2id(t *testing.T) {
+	for _, keyLen := range []uint32{16, 32, 100} {
+		key := argon2Key(argon2id, []byte("password"), []byte("somesalt"), nil, nil, 2, 1024, 2, keyLen)
+		expected := argon2.IDKey([]byte("password"), []byte("somesalt"), 2, 1024, 2, keyLen)
+		if !bytes.Equal(key, expected) {
+			t.Errorf("key length %d: %x, expected %x", keyLen, key, expected)
+		}
+	}
+}
@@ -0,0 +1,453 @@
+// Package kdbx decrypts KeePass 2 databases (.kdbx files) of format versions
+// 3.1 and 4.x.
+//
+// Supported are the AES-KDF and Argon2 (d and id) key derivation functions,
+// the AES-256 and ChaCha20 ciphers, gzip compression, the Salsa20 and
+// ChaCha20 inner random streams that protect values in the XML, and key
+// files. The result of Decrypt is the XML document of the database, in the
+// format of KeePass' XML export.
+package kdbx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/chacha20"
+	"io"
+	"io/ioutil"
+)
+
+// File signatures
+const (
+	signature1 = 0x9aa2d903
+	signature2 = 0xb54bfb67
+)
+
+// Errors returned by Decrypt
+var (
+	ErrFormat = errors.New("kdbx: not a KeePass 2 database")
+	ErrKey    = errors.New("kdbx: wrong password or key file")
+)
+
+// Outer header fields
+const (
+	hdrEnd = iota
+	hdrComment
+	hdrCipherID
+	hdrCompression
+	hdrMasterSeed
+	hdrTransformSeed   // KDBX 3
+	hdrTransformRounds // KDBX 3
+	hdrEncryptionIV
+	hdrStreamKey        // KDBX 3
+	hdrStreamStartBytes // KDBX 3
+	hdrStreamID         // KDBX 3
+	hdrKdfParameters    // KDBX 4
+	hdrPublicCustomData // KDBX 4
+)
+
+// Inner header fields (KDBX 4)
+const (
+	innerEnd = iota
+	innerStreamID
+	innerStreamKey
+	innerBinary
+)
+
+// UUIDs of ciphers and key derivation functions
+var (
+	cipherAES      = uuid("31c1f2e6bf714350be5805216afc5aff")
+	cipherChaCha20 = uuid("d6038a2b8b6f4cb5a524339a31dbb59a")
+	kdfAES         = uuid("c9d9f39a628a4460bf740d08c18a4fea")
+	kdfArgon2d     = uuid("ef636ddf8c29444b91f7a9a403e30a0c")
+	kdfArgon2id    = uuid("9e298b1956db4773b23dfc3ec6f0a1e6")
+)
+
+// header holds the fields of the outer header.
+type header struct {
+	major, minor uint16
+	cipher       []byte
+	compressed   bool
+	masterSeed   []byte
+	iv           []byte
+	kdf          map[string]interface{} // KDF parameters (AES-KDF for KDBX 3)
+
+	// KDBX 3 inner random stream
+	streamKey        []byte
+	streamStartBytes []byte
+	streamID         uint32
+}
+
+// Decrypt reads a database from r and returns its XML document, in which
+// protected values are replaced by their plain text.
+func Decrypt(r io.Reader, key *Key) ([]byte, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	h, n, err := readHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	transformed, err := h.transformKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload []byte
+	var stream cipher.Stream
+	if h.major == 3 {
+		payload, stream, err = h.decrypt3(data[n:], transformed)
+	} else {
+		payload, stream, err = h.decrypt4(data[:n], data[n:], transformed)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return unprotect(payload, stream)
+}
+
+// Detect reports whether data starts with the signature of a KeePass 2
+// database.
+func Detect(data []byte) bool {
+	return len(data) >= 8 &&
+		binary.LittleEndian.Uint32(data) == signature1 &&
+		binary.LittleEndian.Uint32(data[4:]) == signature2
+}
+
+// readHeader parses the outer header and returns it with its length.
+func readHeader(data []byte) (*header, int, error) {
+	if len(data) < 12 || !Detect(data) {
+		return nil, 0, ErrFormat
+	}
+	h := &header{
+		minor: binary.LittleEndian.Uint16(data[8:]),
+		major: binary.LittleEndian.Uint16(data[10:]),
+	}
+	if h.major != 3 && h.major != 4 || h.major == 3 && h.minor < 1 {
+		return nil, 0, fmt.Errorf("kdbx: unsupported version %d.%d", h.major, h.minor)
+	}
+
+	kdf3 := map[string]interface{}{"$UUID": kdfAES}
+	n := 12
+	for {
+		// Field id and length (16 bits in KDBX 3, 32 bits in KDBX 4)
+		lenSize := 2
+		if h.major == 4 {
+			lenSize = 4
+		}
+		if len(data) < n+1+lenSize {
+			return nil, 0, ErrFormat
+		}
+		id := data[n]
+		size := int(binary.LittleEndian.Uint16(data[n+1:]))
+		if h.major == 4 {
+			size = int(binary.LittleEndian.Uint32(data[n+1:]))
+		}
+		n += 1 + lenSize
+		if size < 0 || len(data) < n+size {
+			return nil, 0, ErrFormat
+		}
+		value := data[n : n+size]
+		n += size
+
+		switch id {
+		case hdrEnd:
+			if h.cipher == nil || h.masterSeed == nil || h.iv == nil {
+				return nil, 0, errors.New("kdbx: incomplete header")
+			}
+			if h.major == 3 {
+				h.kdf = kdf3
+			} else if h.kdf == nil {
+				return nil, 0, errors.New("kdbx: missing key derivation parameters")
+			}
+			return h, n, nil
+		case hdrCipherID:
+			h.cipher = value
+		case hdrCompression:
+			if len(value) != 4 {
+				return nil, 0, ErrFormat
+			}
+			h.compressed = binary.LittleEndian.Uint32(value) == 1
+		case hdrMasterSeed:
+			h.masterSeed = value
+		case hdrTransformSeed:
+			kdf3["S"] = value
+		case hdrTransformRounds:
+			if len(value) != 8 {
+				return nil, 0, ErrFormat
+			}
+			kdf3["R"] = binary.LittleEndian.Uint64(value)
+		case hdrEncryptionIV:
+			h.iv = value
+		case hdrStreamKey:
+			h.streamKey = value
+		case hdrStreamStartBytes:
+			h.streamStartBytes = value
+		case hdrStreamID:
+			if len(value) != 4 {
+				return nil, 0, ErrFormat
+			}
+			h.streamID = binary.LittleEndian.Uint32(value)
+		case hdrKdfParameters:
+			var err error
+			if h.kdf, err = readVariantMap(value); err != nil {
+				return nil, 0, err
+			}
+		}
+	}
+}
+
+// transformKey derives the transformed key from the composite key.
+func (h *header) transformKey(key *Key) ([]byte, error) {
+	id, _ := h.kdf["$UUID"].([]byte)
+	salt, _ := h.kdf["S"].([]byte)
+	switch {
+	case bytes.Equal(id, kdfAES):
+		rounds, ok := h.kdf["R"].(uint64)
+		if !ok || len(salt) != 32 {
+			return nil, errors.New("kdbx: invalid AES-KDF parameters")
+		}
+		return aesKdf(key.hash[:], salt, rounds)
+	case bytes.Equal(id, kdfArgon2d), bytes.Equal(id, kdfArgon2id):
+		mode := argon2d
+		if bytes.Equal(id, kdfArgon2id) {
+			mode = argon2id
+		}
+		iterations, ok1 := h.kdf["I"].(uint64)
+		memory, ok2 := h.kdf["M"].(uint64)
+		parallelism, ok3 := h.kdf["P"].(uint32)
+		version, ok4 := h.kdf["V"].(uint32)
+		if !ok1 || !ok2 || !ok3 || !ok4 || len(salt) == 0 {
+			return nil, errors.New("kdbx: invalid Argon2 parameters")
+		}
+		if version != argon2Version {
+			return nil, fmt.Errorf("kdbx: unsupported Argon2 version %#x", version)
+		}
+		if iterations == 0 || iterations > 1<<32-1 || parallelism == 0 ||
+			memory/1024 < 8*uint64(parallelism) || memory/1024 > 1<<32-1 {
+			return nil, errors.New("kdbx: invalid Argon2 parameters")
+		}
+		secret, _ := h.kdf["K"].([]byte)
+		data, _ := h.kdf["A"].([]byte)
+		return argon2Key(mode, key.hash[:], salt, secret, data,
+			uint32(iterations), uint32(memory/1024), parallelism, 32), nil
+	}
+	return nil, errors.New("kdbx: unsupported key derivation function")
+}
+
+// aesKdf transforms key by encrypting it the given number of rounds with
+// AES-256 in ECB mode.
+func aesKdf(key, seed []byte, rounds uint64) ([]byte, error) {
+	block, err := aes.NewCipher(seed)
+	if err != nil {
+		return nil, err
+	}
+	k := append([]byte(nil), key...)
+	for i := uint64(0); i < rounds; i++ {
+		block.Encrypt(k[:16], k[:16])
+		block.Encrypt(k[16:], k[16:])
+	}
+	sum := sha256.Sum256(k)
+	return sum[:], nil
+}
+
+// cipherKey returns the key of the payload cipher.
+func (h *header) cipherKey(transformed []byte) []byte {
+	sum := sha256.Sum256(append(append([]byte(nil), h.masterSeed...), transformed...))
+	return sum[:]
+}
+
+// decryptPayload decrypts data with the cipher of the header.
+func (h *header) decryptPayload(key, data []byte) ([]byte, error) {
+	switch {
+	case bytes.Equal(h.cipher, cipherAES):
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		if len(h.iv) != aes.BlockSize || len(data) == 0 || len(data)%aes.BlockSize != 0 {
+			return nil, ErrFormat
+		}
+		plain := make([]byte, len(data))
+		cipher.NewCBCDecrypter(block, h.iv).CryptBlocks(plain, data)
+
+		// PKCS #7 padding; invalid padding indicates a wrong key
+		pad := int(plain[len(plain)-1])
+		if pad == 0 || pad > aes.BlockSize {
+			return nil, ErrKey
+		}
+		for _, b := range plain[len(plain)-pad:] {
+			if int(b) != pad {
+				return nil, ErrKey
+			}
+		}
+		return plain[:len(plain)-pad], nil
+	case bytes.Equal(h.cipher, cipherChaCha20):
+		c, err := chacha20.NewUnauthenticatedCipher(key, h.iv)
+		if err != nil {
+			return nil, ErrFormat
+		}
+		plain := make([]byte, len(data))
+		c.XORKeyStream(plain, data)
+		return plain, nil
+	}
+	return nil, errors.New("kdbx: unsupported cipher")
+}
+
+// decrypt3 decrypts the payload of a KDBX 3.1 database.
+func (h *header) decrypt3(data, transformed []byte) ([]byte, cipher.Stream, error) {
+	plain, err := h.decryptPayload(h.cipherKey(transformed), data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(h.streamStartBytes) == 0 || !bytes.HasPrefix(plain, h.streamStartBytes) {
+		return nil, nil, ErrKey
+	}
+	plain = plain[len(h.streamStartBytes):]
+
+	// Hashed blocks: index, SHA-256 hash, size and data
+	var payload []byte
+	for {
+		if len(plain) < 40 {
+			return nil, nil, errors.New("kdbx: truncated block")
+		}
+		hash := plain[4:36]
+		size := int(binary.LittleEndian.Uint32(plain[36:]))
+		plain = plain[40:]
+		if size == 0 {
+			break
+		}
+		if size < 0 || size > len(plain) {
+			return nil, nil, errors.New("kdbx: truncated block")
+		}
+		if sum := sha256.Sum256(plain[:size]); !bytes.Equal(sum[:], hash) {
+			return nil, nil, errors.New("kdbx: corrupted block")
+		}
+		payload = append(payload, plain[:size]...)
+		plain = plain[size:]
+	}
+
+	if h.compressed {
+		if payload, err = gunzip(payload); err != nil {
+			return nil, nil, err
+		}
+	}
+	stream, err := newInnerStream(h.streamID, h.streamKey)
+	return payload, stream, err
+}
+
+// decrypt4 verifies and decrypts the payload of a KDBX 4 database.
+func (h *header) decrypt4(hdr, data, transformed []byte) ([]byte, cipher.Stream, error) {
+	if len(data) < 64 {
+		return nil, nil, ErrFormat
+	}
+	if sum := sha256.Sum256(hdr); !bytes.Equal(sum[:], data[:32]) {
+		return nil, nil, errors.New("kdbx: corrupted header")
+	}
+	hmacKey := sha512.Sum512(append(append(append([]byte(nil), h.masterSeed...), transformed...), 1))
+	if !hmac.Equal(blockHMAC(hmacKey[:], 1<<64-1, hdr), data[32:64]) {
+		return nil, nil, ErrKey
+	}
+	data = data[64:]
+
+	// HMAC blocks: HMAC, size and data
+	var encrypted []byte
+	for i := uint64(0); ; i++ {
+		if len(data) < 36 {
+			return nil, nil, errors.New("kdbx: truncated block")
+		}
+		mac := data[:32]
+		size := int(binary.LittleEndian.Uint32(data[32:]))
+		if size < 0 || size > len(data)-36 {
+			return nil, nil, errors.New("kdbx: truncated block")
+		}
+		block := data[32 : 36+size]
+		if !hmac.Equal(blockHMAC(hmacKey[:], i, block), mac) {
+			return nil, nil, errors.New("kdbx: corrupted block")
+		}
+		data = data[36+size:]
+		if size == 0 {
+			break
+		}
+		encrypted = append(encrypted, block[4:]...)
+	}
+
+	payload, err := h.decryptPayload(h.cipherKey(transformed), encrypted)
+	if err != nil {
+		return nil, nil, err
+	}
+	if h.compressed {
+		if payload, err = gunzip(payload); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Inner header
+	var streamID uint32
+	var streamKey []byte
+	for {
+		if len(payload) < 5 {
+			return nil, nil, errors.New("kdbx: truncated inner header")
+		}
+		id := payload[0]
+		size := int(binary.LittleEndian.Uint32(payload[1:]))
+		if size < 0 || size > len(payload)-5 {
+			return nil, nil, errors.New("kdbx: truncated inner header")
+		}
+		value := payload[5 : 5+size]
+		payload = payload[5+size:]
+		if id == innerEnd {
+			break
+		}
+		switch id {
+		case innerStreamID:
+			if len(value) != 4 {
+				return nil, nil, ErrFormat
+			}
+			streamID = binary.LittleEndian.Uint32(value)
+		case innerStreamKey:
+			streamKey = value
+		}
+	}
+	stream, err := newInnerStream(streamID, streamKey)
+	return payload, stream, err
+}
+
+// blockHMAC returns the HMAC of a KDBX 4 block with the given index.
+func blockHMAC(baseKey []byte, index uint64, data []byte) []byte {
+	var i [8]byte
+	binary.LittleEndian.PutUint64(i[:], index)
+	key := sha512.Sum512(append(i[:], baseKey...))
+	mac := hmac.New(sha256.New, key[:])
+	mac.Write(i[:])
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func gunzip(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("kdbx: %s", err)
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// uuid parses a UUID in hexadecimal notation.
+func uuid(s string) []byte {
+	var b []byte
+	for i := 0; i < len(s); i += 2 {
+		var v byte
+		fmt.Sscanf(s[i:i+2], "%02x", &v)
+		b = append(b, v)
+	}
+	return b
+}
@@ -0,0 +1,308 @@
+package kdbx
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+const password = "passman"
+
+// The fixtures in testdata were written once by encrypt from fixtureDoc, in
+// the formats of their names: KDBX 3.1 with AES-KDF; KDBX 4.0 with Argon2d,
+// ChaCha20 and a password and key file; KDBX 4.1 with Argon2id, AES, no
+// compression and only a key file. They are not KeePass or KeePassXC output.
+// Databases written by KeePassXC are tested by TestKeePassXC.
+var fixtures = []struct {
+	file     string
+	password string
+	keyFile  string
+}{
+	{"kdbx3-aes.kdbx", password, ""},
+	{"kdbx4-argon2d-chacha20.kdbx", password, "keyfile.keyx"},
+	{"kdbx4-argon2id-aes.kdbx", "", "keyfile.key"},
+}
+
+// fixtureTime is the modification time of the fixture entries.
+var fixtureTime = time.Date(2021, 3, 14, 15, 9, 26, 0, time.UTC)
+
+// fixtureDoc returns the XML document of the fixtures, with times in the
+// format of the given version.
+func fixtureDoc(major uint16) []byte {
+	t := fixtureTime.Format(time.RFC3339)
+	if major == 4 {
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], uint64(fixtureTime.Unix()+62135596800))
+		t = base64.StdEncoding.EncodeToString(b[:])
+	}
+	return []byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<KeePassFile>
+	<Meta>
+		<Generator>passman</Generator>
+		<DatabaseName>Passwords</DatabaseName>
+	</Meta>
+	<Root>
+		<Group>
+			<Name>Passwords</Name>
+			<Entry>
+				<Times>
+					<CreationTime>%[1]s</CreationTime>
+					<LastModificationTime>%[1]s</LastModificationTime>
+				</Times>
+				<String>
+					<Key>Title</Key>
+					<Value>GitHub</Value>
+				</String>
+				<String>
+					<Key>UserName</Key>
+					<Value>octocat</Value>
+				</String>
+				<String>
+					<Key>Password</Key>
+					<Value Protected="True">correct horse battery staple</Value>
+				</String>
+				<String>
+					<Key>URL</Key>
+					<Value>https://github.com</Value>
+				</String>
+				<String>
+					<Key>Recovery</Key>
+					<Value Protected="True"></Value>
+				</String>
+			</Entry>
+			<Group>
+				<Name>Email</Name>
+				<Entry>
+					<Times>
+						<CreationTime>%[1]s</CreationTime>
+						<LastModificationTime>%[1]s</LastModificationTime>
+					</Times>
+					<String>
+						<Key>Title</Key>
+						<Value>Mail &amp; Calendar</Value>
+					</String>
+					<String>
+						<Key>Password</Key>
+						<Value Protected="True">&lt;p@ss&gt; wörd</Value>
+					</String>
+				</Entry>
+			</Group>
+		</Group>
+	</Root>
+</KeePassFile>
+`, t))
+}
+
+func fixtureKey(t *testing.T, password, keyFile string) *Key {
+	var data []byte
+	if keyFile != "" {
+		var err error
+		if data, err = ioutil.ReadFile("testdata/" + keyFile); err != nil {
+			t.Fatal(err)
+		}
+	}
+	key, err := NewKey([]byte(password), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+type document struct {
+	Generator string  `xml:"Meta>Generator"`
+	Groups    []group `xml:"Root>Group"`
+}
+
+type group struct {
+	Name    string
+	Groups  []group `xml:"Group"`
+	Entries []struct {
+		Mtime   string `xml:"Times>LastModificationTime"`
+		Strings []struct {
+			Key   string
+			Value struct {
+				Value     string `xml:",chardata"`
+				Protected string `xml:",attr"`
+			}
+		} `xml:"String"`
+	} `xml:"Entry"`
+}
+
+func TestDecrypt(t *testing.T) {
+	for _, f := range fixtures {
+		file, err := os.Open("testdata/" + f.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc, err := Decrypt(file, fixtureKey(t, f.password, f.keyFile))
+		file.Close()
+		if err != nil {
+			t.Errorf("%s: %s", f.file, err)
+			continue
+		}
+
+		var d document
+		if err := xml.Unmarshal(doc, &d); err != nil {
+			t.Errorf("%s: %s", f.file, err)
+			continue
+		}
+		if d.Generator != "passman" || len(d.Groups) != 1 || len(d.Groups[0].Groups) != 1 {
+			t.Errorf("%s: unexpected document:\n%s", f.file, doc)
+			continue
+		}
+		values := make(map[string]string)
+		for _, g := range []group{d.Groups[0], d.Groups[0].Groups[0]} {
+			for _, e := range g.Entries {
+				for _, s := range e.Strings {
+					if s.Value.Protected != "" {
+						t.Errorf("%s: %s is still protected", f.file, s.Key)
+					}
+					values[s.Key] += s.Value.Value + ";"
+				}
+			}
+		}
+		expected := map[string]string{
+			"Title":    "GitHub;Mail & Calendar;",
+			"UserName": "octocat;",
+			"Password": "correct horse battery staple;<p@ss> wörd;",
+			"URL":      "https://github.com;",
+			"Recovery": ";",
+		}
+		for key, value := range expected {
+			if values[key] != value {
+				t.Errorf("%s: %s is %q, expected %q", f.file, key, values[key], value)
+			}
+		}
+	}
+}
+
+func TestDecryptWrongKey(t *testing.T) {
+	for _, f := range fixtures {
+		data, err := ioutil.ReadFile("testdata/" + f.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys := []*Key{fixtureKey(t, "wrong", f.keyFile)}
+		if f.keyFile != "" {
+			keys = append(keys, fixtureKey(t, f.password+"x", ""))
+		}
+		for _, key := range keys {
+			if _, err := Decrypt(bytes.NewReader(data), key); err != ErrKey {
+				t.Errorf("%s: error %v, expected %v", f.file, err, ErrKey)
+			}
+		}
+	}
+}
+
+func TestDecryptInvalid(t *testing.T) {
+	key := fixtureKey(t, password, "")
+	data, err := ioutil.ReadFile("testdata/kdbx3-aes.kdbx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2 := append([]byte(nil), data...)
+	binary.LittleEndian.PutUint16(v2[10:], 2)
+
+	tests := []struct {
+		data []byte
+		err  string
+	}{
+		{nil, ErrFormat.Error()},
+		{[]byte("<KeePassFile/>"), ErrFormat.Error()},
+		{v2, "kdbx: unsupported version 2.1"},
+		{data[:100], ErrFormat.Error()},
+	}
+	for _, test := range tests {
+		_, err := Decrypt(bytes.NewReader(test.data), key)
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%.20q: error %v, expected %s", test.data, err, test.err)
+		}
+	}
+}
+
+func TestKeyFile(t *testing.T) {
+	raw := []byte("0123456789abcdef0123456789abcdef")
+	hexKey := []byte("3031323334353637383961626364656630313233343536373839616263646566")
+	v1 := []byte(`<?xml version="1.0" encoding="utf-8"?>
+<KeyFile><Meta><Version>1.00</Version></Meta>
+<Key><Data>MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=</Data></Key></KeyFile>`)
+	v2, err := ioutil.ReadFile("testdata/keyfile.keyx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		data []byte
+		key  []byte
+	}{
+		{raw, raw},
+		{hexKey, raw},
+		{v1, raw},
+		{v2, []byte("@ABCDEFGHIJKLMNOPQRSTUVWXYZ[\\]^_")},
+		{[]byte("any file"), sha256Sum([]byte("any file"))},
+	}
+	for _, test := range tests {
+		key, err := keyFileKey(test.data)
+		if err != nil || !bytes.Equal(key, test.key) {
+			t.Errorf("%.20q: key %q (%v), expected %q", test.data, key, err, test.key)
+		}
+	}
+
+	corrupted := bytes.Replace(v2, []byte("40414243"), []byte("40414244"), 1)
+	if _, err := keyFileKey(corrupted); err == nil {
+		t.Errorf("accepted key file with incorrect hash")
+	}
+}
+
+func TestReadVariantMap(t *testing.T) {
+	m := map[string]interface{}{
+		"$UUID": kdfArgon2d,
+		"I":     uint64(2),
+		"P":     uint32(4),
+	}
+	parsed, err := readVariantMap(marshalVariantMap(m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed) != 3 || !bytes.Equal(parsed["$UUID"].([]byte), kdfArgon2d) ||
+		parsed["I"] != uint64(2) || parsed["P"] != uint32(4) {
+		t.Errorf("parsed %v, expected %v", parsed, m)
+	}
+	for _, data := range [][]byte{nil, {0x00, 0x02, 0x00}, {0x00, 0x01, 0x04, 0x01}} {
+		if _, err := readVariantMap(data); err == nil {
+			t.Errorf("parsed %x", data)
+		}
+	}
+}
+
+func sha256Sum(b []byte) []byte {
+	sum := sha256.Sum256(b)
+	return sum[:]
+}
+
+func TestEncrypt(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping key derivation with default parameters")
+	}
+	key := fixtureKey(t, password, "")
+	var b bytes.Buffer
+	start := time.Now()
+	if err := Encrypt(&b, fixtureDoc(4), key); err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("encrypted in %s", time.Since(start))
+	doc, err := Decrypt(&b, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(doc, []byte("<Value>correct horse battery staple</Value>")) {
+		t.Errorf("decrypted document lacks password:\n%s", doc)
+	}
+}
@@ -0,0 +1,130 @@
+package kdbx
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Environment variable that makes tests fail instead of skip if keepassxc-cli
+// isn't installed. CI jobs have to set it.
+const keepassxcEnvKey = "PASSMAN_TEST_KEEPASSXC"
+
+// requireKeePassXC skips the test if keepassxc-cli isn't installed, unless
+// the environment requires it.
+func requireKeePassXC(t *testing.T) {
+	if _, err := exec.LookPath("keepassxc-cli"); err == nil {
+		return
+	} else if os.Getenv(keepassxcEnvKey) != "" {
+		t.Fatalf("$%s is set: %s", keepassxcEnvKey, err)
+	}
+	t.Skipf("keepassxc-cli not available (set $%s to require it)", keepassxcEnvKey)
+}
+
+// keepassxc runs keepassxc-cli with the given input (passwords) and returns
+// its standard output.
+func keepassxc(t *testing.T, input string, args ...string) string {
+	cmd := exec.Command("keepassxc-cli", args...)
+	cmd.Stdin = strings.NewReader(input)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("keepassxc-cli %s: %s\n%s", strings.Join(args, " "), err, stderr.Bytes())
+	}
+	return string(out)
+}
+
+// TestKeePassXC decrypts databases written by KeePassXC: KDBX 4 with the
+// default settings of keepassxc-cli, with a password or a key file, and with
+// an attachment in the inner header.
+func TestKeePassXC(t *testing.T) {
+	requireKeePassXC(t)
+	dir, err := ioutil.TempDir("", "kdbx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	attachment := filepath.Join(dir, "recovery.txt")
+	if err := ioutil.WriteFile(attachment, []byte("abc-def"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "db.keyx")
+
+	tests := []struct {
+		name     string
+		create   []string // db-create options
+		unlock   []string // Options of other commands
+		input    string   // Password input of every command
+		password string
+		keyFile  string
+	}{
+		{"password.kdbx", []string{"--set-password"}, nil, password + "\n", password, ""},
+		{"keyfile.kdbx", []string{"--set-key-file", keyFile},
+			[]string{"--key-file", keyFile, "--no-password"}, "", "", keyFile},
+	}
+	for _, test := range tests {
+		db := filepath.Join(dir, test.name)
+		keepassxc(t, strings.Repeat(test.input, 2), append(append([]string{"db-create"}, test.create...), db)...)
+		run := func(command string, args ...string) string {
+			args = append(append(append([]string{command, "-q"}, test.unlock...), db), args...)
+			return keepassxc(t, test.input, args...)
+		}
+		run("mkdir", "Work")
+		run("add", "-u", "octocat", "--url", "https://github.com", "-g", "-L", "24", "Work/GitHub")
+		run("attachment-import", "Work/GitHub", "recovery.txt", attachment)
+		generated := strings.TrimSpace(run("show", "-s", "-a", "Password", "Work/GitHub"))
+
+		var keyData []byte
+		if test.keyFile != "" {
+			if keyData, err = ioutil.ReadFile(test.keyFile); err != nil {
+				t.Fatal(err)
+			}
+		}
+		key, err := NewKey([]byte(test.password), keyData)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.Open(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc, err := Decrypt(f, key)
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+
+		var d document
+		if err := xml.Unmarshal(doc, &d); err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		if !strings.HasPrefix(d.Generator, "KeePassXC") || len(d.Groups) != 1 {
+			t.Fatalf("%s: unexpected document:\n%s", test.name, doc)
+		}
+		values := make(map[string]string)
+		for _, g := range d.Groups[0].Groups {
+			for _, e := range g.Entries {
+				for _, s := range e.Strings {
+					values[g.Name+"/"+s.Key] = s.Value.Value
+				}
+			}
+		}
+		expected := map[string]string{
+			"Work/Title":    "GitHub",
+			"Work/UserName": "octocat",
+			"Work/URL":      "https://github.com",
+			"Work/Password": generated,
+		}
+		for key, value := range expected {
+			if values[key] != value {
+				t.Errorf("%s: %s is %q, expected %q", test.name, key, values[key], value)
+			}
+		}
+	}
+}
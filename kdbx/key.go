@@ -0,0 +1,83 @@
+package kdbx
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"strings"
+)
+
+// Key is the composite key of a database: a password, a key file or both.
+type Key struct {
+	hash [32]byte
+}
+
+// NewKey returns the composite key of password and the contents of a key
+// file. Either may be empty, but not both.
+func NewKey(password, keyFile []byte) (*Key, error) {
+	if len(password) == 0 && len(keyFile) == 0 {
+		return nil, errors.New("kdbx: empty key")
+	}
+	h := sha256.New()
+	if len(password) > 0 {
+		sum := sha256.Sum256(password)
+		h.Write(sum[:])
+	}
+	if len(keyFile) > 0 {
+		k, err := keyFileKey(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		h.Write(k)
+	}
+	key := new(Key)
+	h.Sum(key.hash[:0])
+	return key, nil
+}
+
+// keyFileKey returns the 32-byte key of a key file. Key files are XML files
+// (version 1.0 or 2.0), 32 bytes of binary data, 64 hexadecimal digits, or
+// any other file, of which the SHA-256 hash is the key.
+func keyFileKey(data []byte) ([]byte, error) {
+	var kf struct {
+		XMLName xml.Name `xml:"KeyFile"`
+		Version string   `xml:"Meta>Version"`
+		Data    struct {
+			Hash  string `xml:",attr"`
+			Value string `xml:",chardata"`
+		} `xml:"Key>Data"`
+	}
+	if bytes.Contains(data, []byte("<KeyFile>")) && xml.Unmarshal(data, &kf) == nil {
+		value := strings.Join(strings.Fields(kf.Data.Value), "")
+		switch {
+		case strings.HasPrefix(kf.Version, "1."):
+			return base64.StdEncoding.DecodeString(value)
+		case strings.HasPrefix(kf.Version, "2."):
+			key, err := hex.DecodeString(value)
+			if err != nil {
+				return nil, err
+			}
+			if kf.Data.Hash != "" {
+				sum := sha256.Sum256(key)
+				if !strings.EqualFold(hex.EncodeToString(sum[:4]), kf.Data.Hash) {
+					return nil, errors.New("kdbx: corrupted key file")
+				}
+			}
+			return key, nil
+		}
+		return nil, errors.New("kdbx: unsupported key file version " + kf.Version)
+	}
+	if len(data) == 32 {
+		return data, nil
+	}
+	if len(data) == 64 {
+		if key, err := hex.DecodeString(string(data)); err == nil {
+			return key, nil
+		}
+	}
+	sum := sha256.Sum256(data)
+	return sum[:], nil
+}
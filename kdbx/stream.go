@@ -0,0 +1,121 @@
+package kdbx
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/salsa20/salsa"
+	"io"
+)
+
+// Inner random stream algorithms
+const (
+	streamNone     = 0
+	streamSalsa20  = 2
+	streamChaCha20 = 3
+)
+
+var salsa20Nonce = []byte{0xe8, 0x30, 0x09, 0x4b, 0x97, 0x20, 0x5d, 0x2a}
+
+// newInnerStream returns the key stream that protects values in the XML
+// document, or nil if values aren't protected.
+func newInnerStream(id uint32, key []byte) (cipher.Stream, error) {
+	switch id {
+	case streamNone:
+		return nil, nil
+	case streamSalsa20:
+		s := &salsa20Stream{key: sha256.Sum256(key)}
+		copy(s.counter[:], salsa20Nonce)
+		return s, nil
+	case streamChaCha20:
+		sum := sha512.Sum512(key)
+		c, err := chacha20.NewUnauthenticatedCipher(sum[:32], sum[32:44])
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+	return nil, fmt.Errorf("kdbx: unsupported inner random stream %d", id)
+}
+
+// salsa20Stream is a Salsa20 cipher.Stream, which golang.org/x/crypto
+// only provides as a function over a whole message.
+type salsa20Stream struct {
+	key     [32]byte
+	counter [16]byte // Nonce and block counter
+	block   [64]byte
+	used    int // Number of bytes of block that are used
+}
+
+func (s *salsa20Stream) XORKeyStream(dst, src []byte) {
+	for i := range src {
+		if s.used == 0 || s.used == len(s.block) {
+			var zero [64]byte
+			salsa.XORKeyStream(s.block[:], zero[:], &s.counter, &s.key)
+			binary.LittleEndian.PutUint64(s.counter[8:],
+				binary.LittleEndian.Uint64(s.counter[8:])+1)
+			s.used = 0
+		}
+		dst[i] = src[i] ^ s.block[s.used]
+		s.used++
+	}
+}
+
+// unprotect replaces the protected values in an XML document by their plain
+// text. Protected values are XOR'ed with the inner random stream, in the
+// order in which they appear in the document, and encoded in base64.
+func unprotect(doc []byte, stream cipher.Stream) ([]byte, error) {
+	var buf bytes.Buffer
+	dec := xml.NewDecoder(bytes.NewReader(doc))
+	enc := xml.NewEncoder(&buf)
+	protected := false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("kdbx: %s", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			protected = false
+			attrs := t.Attr[:0:0]
+			for _, a := range t.Attr {
+				if a.Name.Local == "Protected" && a.Value == "True" {
+					protected = true
+				} else {
+					attrs = append(attrs, a)
+				}
+			}
+			t.Attr = attrs
+			tok = t
+		case xml.EndElement:
+			protected = false
+		case xml.CharData:
+			if protected {
+				value, err := base64.StdEncoding.DecodeString(string(t))
+				if err != nil {
+					return nil, fmt.Errorf("kdbx: invalid protected value: %s", err)
+				}
+				if stream == nil {
+					return nil, fmt.Errorf("kdbx: protected value without inner random stream")
+				}
+				stream.XORKeyStream(value, value)
+				tok = xml.CharData(value)
+			}
+		}
+		if err := enc.EncodeToken(tok); err != nil {
+			return nil, fmt.Errorf("kdbx: %s", err)
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
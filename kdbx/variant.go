@@ -0,0 +1,72 @@
+package kdbx
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Value types of a variant map
+const (
+	variantEnd    = 0x00
+	variantUint32 = 0x04
+	variantUint64 = 0x05
+	variantBool   = 0x08
+	variantInt32  = 0x0c
+	variantInt64  = 0x0d
+	variantString = 0x18
+	variantBytes  = 0x42
+)
+
+var errVariant = errors.New("kdbx: invalid variant map")
+
+// readVariantMap parses a variant map (the KDF parameters of KDBX 4).
+func readVariantMap(data []byte) (map[string]interface{}, error) {
+	if len(data) < 2 || data[1] != 0x01 { // Major version 1
+		return nil, errVariant
+	}
+	data = data[2:]
+	m := make(map[string]interface{})
+	for {
+		if len(data) < 1 {
+			return nil, errVariant
+		}
+		typ := data[0]
+		if typ == variantEnd {
+			return m, nil
+		}
+		if len(data) < 5 {
+			return nil, errVariant
+		}
+		n := int(binary.LittleEndian.Uint32(data[1:]))
+		if n < 0 || len(data) < 5+n+4 {
+			return nil, errVariant
+		}
+		name := string(data[5 : 5+n])
+		data = data[5+n:]
+		n = int(binary.LittleEndian.Uint32(data))
+		if n < 0 || len(data) < 4+n {
+			return nil, errVariant
+		}
+		value := data[4 : 4+n]
+		data = data[4+n:]
+
+		switch {
+		case typ == variantUint32 && n == 4:
+			m[name] = binary.LittleEndian.Uint32(value)
+		case typ == variantUint64 && n == 8:
+			m[name] = binary.LittleEndian.Uint64(value)
+		case typ == variantBool && n == 1:
+			m[name] = value[0] != 0
+		case typ == variantInt32 && n == 4:
+			m[name] = int32(binary.LittleEndian.Uint32(value))
+		case typ == variantInt64 && n == 8:
+			m[name] = int64(binary.LittleEndian.Uint64(value))
+		case typ == variantString:
+			m[name] = string(value)
+		case typ == variantBytes:
+			m[name] = append([]byte(nil), value...)
+		default:
+			return nil, errVariant
+		}
+	}
+}
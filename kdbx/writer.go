@@ -0,0 +1,312 @@
+package kdbx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"golang.org/x/crypto/chacha20"
+	"io"
+)
+
+// Argon2d parameters of Encrypt, the defaults of KeePassXC
+const (
+	DefaultIterations  = 10
+	DefaultMemory      = 64 * 1024 * 1024 // Bytes
+	DefaultParallelism = 2
+)
+
+// params describes how a database is encrypted.
+type params struct {
+	major, minor uint16
+	cipher       []byte
+	kdf          map[string]interface{} // Without the salt
+	compressed   bool
+	streamID     uint32
+}
+
+// Encrypt writes the XML document doc as a KDBX 4 database, encrypted with
+// AES-256 and key, with Argon2d as key derivation function. Elements with a
+// Protected="True" attribute contain plain text, which Encrypt protects with
+// the inner random stream.
+func Encrypt(w io.Writer, doc []byte, key *Key) error {
+	return encrypt(w, doc, key, params{
+		major:      4,
+		cipher:     cipherAES,
+		compressed: true,
+		streamID:   streamChaCha20,
+		kdf: map[string]interface{}{
+			"$UUID": kdfArgon2d,
+			"I":     uint64(DefaultIterations),
+			"M":     uint64(DefaultMemory),
+			"P":     uint32(DefaultParallelism),
+			"V":     uint32(argon2Version),
+		},
+	})
+}
+
+func encrypt(w io.Writer, doc []byte, key *Key, p params) error {
+	h := &header{
+		major:      p.major,
+		minor:      p.minor,
+		cipher:     p.cipher,
+		compressed: p.compressed,
+		masterSeed: random(32),
+		kdf:        map[string]interface{}{"S": random(32)},
+		streamID:   p.streamID,
+		streamKey:  random(64),
+	}
+	for name, value := range p.kdf {
+		h.kdf[name] = value
+	}
+	if bytes.Equal(h.cipher, cipherChaCha20) {
+		h.iv = random(12)
+	} else {
+		h.iv = random(16)
+	}
+	if h.major == 3 {
+		h.streamKey = random(32)
+		h.streamStartBytes = random(32)
+	}
+
+	stream, err := newInnerStream(h.streamID, h.streamKey)
+	if err != nil {
+		return err
+	}
+	if doc, err = protect(doc, stream); err != nil {
+		return err
+	}
+	transformed, err := h.transformKey(key)
+	if err != nil {
+		return err
+	}
+	hdr := h.marshal()
+
+	var out bytes.Buffer
+	out.Write(hdr)
+	if h.major == 3 {
+		payload := gzipData(doc, h.compressed)
+		plain := append(append([]byte(nil), h.streamStartBytes...), hashedBlocks(payload)...)
+		out.Write(encryptPayload(h, h.cipherKey(transformed), plain))
+	} else {
+		var inner bytes.Buffer
+		writeField(&inner, innerStreamID, le32(h.streamID), 4)
+		writeField(&inner, innerStreamKey, h.streamKey, 4)
+		writeField(&inner, innerEnd, nil, 4)
+		inner.Write(doc)
+		encrypted := encryptPayload(h, h.cipherKey(transformed),
+			gzipData(inner.Bytes(), h.compressed))
+
+		sum := sha256.Sum256(hdr)
+		out.Write(sum[:])
+		hmacKey := sha512Sum(append(append(append([]byte(nil), h.masterSeed...), transformed...), 1))
+		out.Write(blockHMAC(hmacKey, 1<<64-1, hdr))
+		out.Write(hmacBlocks(hmacKey, encrypted))
+	}
+	_, err = w.Write(out.Bytes())
+	return err
+}
+
+// marshal returns the outer header of h.
+func (h *header) marshal() []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, []uint32{signature1, signature2})
+	binary.Write(&b, binary.LittleEndian, []uint16{h.minor, h.major})
+	size := 2
+	if h.major == 4 {
+		size = 4
+	}
+	compression := uint32(0)
+	if h.compressed {
+		compression = 1
+	}
+	writeField(&b, hdrCipherID, h.cipher, size)
+	writeField(&b, hdrCompression, le32(compression), size)
+	writeField(&b, hdrMasterSeed, h.masterSeed, size)
+	if h.major == 3 {
+		var rounds [8]byte
+		binary.LittleEndian.PutUint64(rounds[:], h.kdf["R"].(uint64))
+		writeField(&b, hdrTransformSeed, h.kdf["S"].([]byte), size)
+		writeField(&b, hdrTransformRounds, rounds[:], size)
+		writeField(&b, hdrEncryptionIV, h.iv, size)
+		writeField(&b, hdrStreamKey, h.streamKey, size)
+		writeField(&b, hdrStreamStartBytes, h.streamStartBytes, size)
+		writeField(&b, hdrStreamID, le32(h.streamID), size)
+	} else {
+		writeField(&b, hdrEncryptionIV, h.iv, size)
+		writeField(&b, hdrKdfParameters, marshalVariantMap(h.kdf), size)
+	}
+	writeField(&b, hdrEnd, []byte("\r\n\r\n"), size)
+	return b.Bytes()
+}
+
+// marshalVariantMap returns the variant map of m, with its keys in a fixed
+// order.
+func marshalVariantMap(m map[string]interface{}) []byte {
+	b := bytes.NewBuffer([]byte{0x00, 0x01})
+	for _, name := range []string{"$UUID", "R", "S", "P", "M", "I", "V", "K", "A"} {
+		var typ byte
+		var value []byte
+		switch v := m[name].(type) {
+		case nil:
+			continue
+		case uint32:
+			typ, value = variantUint32, le32(v)
+		case uint64:
+			typ, value = variantUint64, make([]byte, 8)
+			binary.LittleEndian.PutUint64(value, v)
+		case []byte:
+			typ, value = variantBytes, v
+		}
+		b.WriteByte(typ)
+		b.Write(le32(uint32(len(name))))
+		b.WriteString(name)
+		b.Write(le32(uint32(len(value))))
+		b.Write(value)
+	}
+	b.WriteByte(variantEnd)
+	return b.Bytes()
+}
+
+func writeField(b *bytes.Buffer, id byte, value []byte, size int) {
+	b.WriteByte(id)
+	if size == 2 {
+		binary.Write(b, binary.LittleEndian, uint16(len(value)))
+	} else {
+		b.Write(le32(uint32(len(value))))
+	}
+	b.Write(value)
+}
+
+func encryptPayload(h *header, key, plain []byte) []byte {
+	if bytes.Equal(h.cipher, cipherChaCha20) {
+		c, _ := chacha20.NewUnauthenticatedCipher(key, h.iv)
+		out := make([]byte, len(plain))
+		c.XORKeyStream(out, plain)
+		return out
+	}
+	pad := aes.BlockSize - len(plain)%aes.BlockSize
+	plain = append(plain, bytes.Repeat([]byte{byte(pad)}, pad)...)
+	block, _ := aes.NewCipher(key)
+	out := make([]byte, len(plain))
+	cipher.NewCBCEncrypter(block, h.iv).CryptBlocks(out, plain)
+	return out
+}
+
+// hashedBlocks returns the hashed block stream of KDBX 3 for data, in
+// blocks of at most 1 KiB.
+func hashedBlocks(data []byte) []byte {
+	var b bytes.Buffer
+	for i := uint32(0); ; i++ {
+		n := len(data)
+		if n > 1024 {
+			n = 1024
+		}
+		b.Write(le32(i))
+		if n == 0 {
+			b.Write(make([]byte, 32))
+		} else {
+			sum := sha256.Sum256(data[:n])
+			b.Write(sum[:])
+		}
+		b.Write(le32(uint32(n)))
+		b.Write(data[:n])
+		data = data[n:]
+		if n == 0 {
+			return b.Bytes()
+		}
+	}
+}
+
+// hmacBlocks returns the HMAC block stream of KDBX 4 for data, in blocks of
+// at most 1 KiB.
+func hmacBlocks(hmacKey, data []byte) []byte {
+	var b bytes.Buffer
+	for i := uint64(0); ; i++ {
+		n := len(data)
+		if n > 1024 {
+			n = 1024
+		}
+		block := append(le32(uint32(n)), data[:n]...)
+		b.Write(blockHMAC(hmacKey, i, block))
+		b.Write(block)
+		data = data[n:]
+		if n == 0 {
+			return b.Bytes()
+		}
+	}
+}
+
+// protect is the inverse of unprotect.
+func protect(doc []byte, stream cipher.Stream) ([]byte, error) {
+	var buf bytes.Buffer
+	dec := xml.NewDecoder(bytes.NewReader(doc))
+	enc := xml.NewEncoder(&buf)
+	protected := false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			protected = false
+			for _, a := range t.Attr {
+				protected = protected || a.Name.Local == "Protected" && a.Value == "True"
+			}
+		case xml.EndElement:
+			protected = false
+		case xml.CharData:
+			if protected {
+				value := append([]byte(nil), t...)
+				stream.XORKeyStream(value, value)
+				tok = xml.CharData(base64.StdEncoding.EncodeToString(value))
+			}
+		}
+		if err := enc.EncodeToken(tok); err != nil {
+			return nil, err
+		}
+	}
+	err := enc.Flush()
+	return buf.Bytes(), err
+}
+
+func gzipData(data []byte, compressed bool) []byte {
+	if !compressed {
+		return data
+	}
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	w.Write(data)
+	w.Close()
+	return b.Bytes()
+}
+
+// random returns n random bytes. crypto/rand doesn't fail on supported
+// platforms.
+func random(n int) []byte {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func le32(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, v)
+	return b
+}
+
+func sha512Sum(b []byte) []byte {
+	sum := sha512.Sum512(b)
+	return sum[:]
+}
@@ -1,35 +1,86 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"github.com/tvdburgt/passman/store"
+	"io"
 	"os"
 	"regexp"
+	"time"
 )
 
 var cmdList = &Command{
-	UsageLine: "list [search pattern]",
+	UsageLine: "list [-expired] [-expiring interval] [search pattern]",
 	Short:     "list store entries",
 	Long: `
 regex is posix?
 displays all entries in store, optionally filtered by a regex pattern
+
+	-expired		only list entries whose password has expired
+	-expiring <interval>	only list entries whose password expires within
+				the interval (e.g., 14d), including expired ones
 	`,
 }
 
+// listOptions holds the filters of 'passman list'.
+type listOptions struct {
+	expired  bool
+	expiring string
+}
+
+var listOpts = &listOptions{}
+
 func init() {
 	cmdList.Run = runList
+	addListFlags(&cmdList.Flag, listOpts)
 	addFileFlag(cmdList)
 }
 
-func runList(cmd *Command, args []string) {
-	s := openStore()
+// addListFlags defines the flags of 'passman list' on fs, storing their
+// values in o.
+func addListFlags(fs *flag.FlagSet, o *listOptions) {
+	fs.BoolVar(&o.expired, "expired", false, "")
+	fs.StringVar(&o.expiring, "expiring", "", "")
+}
+
+func runList(cmd *Command, args []string) error {
+	s, err := openStore()
+	if err != nil {
+		return err
+	}
+	return listEntries(os.Stdout, s, args, listOpts)
+}
 
+// listEntries lists the entries in s, optionally filtered by the pattern in
+// args and the expiry filters in o.
+func listEntries(w io.Writer, s *store.Store, args []string, o *listOptions) error {
 	// TODO: posix or not?
 	var pattern *regexp.Regexp
 	var err error
 	if len(args) > 0 {
 		pattern, err = regexp.Compile(args[0])
 		if err != nil {
-			fatalf("invalid pattern: %s", err)
+			return fmt.Errorf("invalid pattern: %s", err)
+		}
+	}
+	if !o.expired && o.expiring == "" {
+		s.List(w, pattern)
+		return nil
+	}
+
+	var within time.Duration
+	if o.expiring != "" {
+		if within, err = store.ParseInterval(o.expiring); err != nil {
+			return err
+		}
+	}
+	var ids []string
+	for _, id := range s.Ids(pattern) {
+		if s.Entries[id].ExpiresWithin(within) {
+			ids = append(ids, id)
 		}
 	}
-	s.List(os.Stdout, pattern)
+	s.ListIds(w, ids)
+	return nil
 }
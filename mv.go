@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/tvdburgt/passman/crypto"
+	"github.com/tvdburgt/passman/store"
+)
+
+var cmdMove = &Command{
+	UsageLine: "mv [-f file] old_id new_id",
+	Short:     "rename an entry",
+	Long: `
+mv changes the id of an existing entry. The entry itself, including its
+modification time, is left untouched. The new id must not be in use.
+	`,
+}
+
+func init() {
+	cmdMove.Run = runMove
+	addFileFlag(cmdMove)
+}
+
+func runMove(cmd *Command, args []string) error {
+	if len(args) < 2 {
+		return errUsage
+	}
+	from, to := args[0], args[1]
+
+	s, passphrase, err := openRwStore()
+	if err != nil {
+		return err
+	}
+	defer crypto.Clear(passphrase)
+
+	if err := moveEntry(s, from, to); err != nil {
+		return fmt.Errorf("passman mv: %s", err)
+	}
+
+	if err := saveStore(s, passphrase); err != nil {
+		return err
+	}
+	fmt.Printf("Renamed entry %q to %q\n", from, to)
+	return nil
+}
+
+func moveEntry(s *store.Store, from, to string) error {
+	if to == "" {
+		return errors.New("new id is empty")
+	}
+	e, ok := s.Entries[from]
+	if !ok {
+		return fmt.Errorf("no such entry %q", from)
+	}
+	if _, ok := s.Entries[to]; ok {
+		return fmt.Errorf("entry with id %q already exists", to)
+	}
+	s.Entries[to] = e
+	delete(s.Entries, from)
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/tvdburgt/passman/crypto"
+	"github.com/tvdburgt/passman/otp"
+	"github.com/tvdburgt/passman/store"
+	"time"
+)
+
+var cmdOtp = &Command{
+	UsageLine: "otp [-f file] entry_id",
+	Short:     "show the current one-time password of an entry",
+	Long: `
+otp prints the current one-time password (two-factor authentication code) of
+an entry. For time-based (TOTP) keys, the number of seconds the code remains
+valid is shown as well. For counter-based (HOTP) keys, the counter stored in
+the entry is incremented, so every code is only shown once.
+
+One-time password keys are added with 'passman set -otp', either as an
+otpauth:// URI (as encoded in the QR codes for authenticator apps) or as a
+base32 secret for the common TOTP settings. Use 'passman clip -fields otp' to
+copy the code to the clipboard instead.
+	`,
+}
+
+func init() {
+	cmdOtp.Run = runOtp
+	addFileFlag(cmdOtp)
+}
+
+func runOtp(cmd *Command, args []string) error {
+	if len(args) < 1 {
+		return errUsage
+	}
+	id := args[0]
+
+	s, passphrase, err := openRwStore()
+	if err != nil {
+		return err
+	}
+	defer crypto.Clear(passphrase)
+	e, err := getEntry(s, id)
+	if err != nil {
+		return err
+	}
+
+	code, modified, err := otpCode(e)
+	if err != nil {
+		return fmt.Errorf("passman otp: %s", err)
+	}
+	if modified {
+		if err := saveStore(s, passphrase); err != nil {
+			return err
+		}
+	}
+	fmt.Println(formatCode(e.OTP, code))
+	return nil
+}
+
+// otpCode returns the current one-time password of e. It reports whether e
+// was modified by advancing its HOTP counter.
+func otpCode(e *store.Entry) (string, bool, error) {
+	if e.OTP == nil {
+		return "", false, errors.New("entry has no one-time password key")
+	}
+	code, err := e.OTP.Next()
+	return code, err == nil && e.OTP.Type == otp.HOTP, err
+}
+
+// formatCode returns code with the time it remains valid, if k is a TOTP key.
+func formatCode(k *otp.Key, code string) string {
+	if k.Type != otp.TOTP {
+		return code
+	}
+	r := k.Remaining(time.Now())
+	return fmt.Sprintf("%s (%ds remaining)", code, (r+time.Second-1)/time.Second)
+}
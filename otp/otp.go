@@ -0,0 +1,257 @@
+// Package otp generates one-time passwords: HOTP (RFC 4226) and TOTP
+// (RFC 6238), as used for two-factor authentication.
+//
+// Keys are usually exchanged as otpauth URIs (the format encoded in the QR
+// codes of authenticator apps):
+//
+//	otpauth://totp/Example:alice@example.com?secret=JBSWY3DPEHPK3PXP&issuer=Example
+package otp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Key types
+const (
+	TOTP = "totp"
+	HOTP = "hotp"
+)
+
+// Defaults of optional key parameters
+const (
+	DefaultAlgorithm = "SHA1"
+	DefaultDigits    = 6
+	DefaultPeriod    = 30
+)
+
+// Key holds the shared secret and parameters of a one-time password
+// generator.
+type Key struct {
+	Type      string `json:"type"`
+	Secret    []byte `json:"secret"`
+	Algorithm string `json:"algorithm"`         // SHA1, SHA256 or SHA512
+	Digits    int    `json:"digits"`            // Length of codes
+	Period    int    `json:"period,omitempty"`  // TOTP time step in seconds
+	Counter   uint64 `json:"counter,omitempty"` // HOTP counter of the next code
+	Issuer    string `json:"issuer,omitempty"`
+	Account   string `json:"account,omitempty"`
+}
+
+// ParseURI parses an otpauth URI.
+func ParseURI(s string) (*Key, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "otpauth" {
+		return nil, fmt.Errorf("otp: invalid scheme %q (expected otpauth)", u.Scheme)
+	}
+	k := &Key{
+		Type:      strings.ToLower(u.Host),
+		Algorithm: DefaultAlgorithm,
+		Digits:    DefaultDigits,
+	}
+	if k.Type != TOTP && k.Type != HOTP {
+		return nil, fmt.Errorf("otp: invalid type %q", u.Host)
+	}
+
+	// The label is "issuer:account" or just "account"
+	label := strings.TrimPrefix(u.Path, "/")
+	if i := strings.Index(label, ":"); i >= 0 {
+		k.Issuer, label = label[:i], strings.TrimLeft(label[i+1:], " ")
+	}
+	k.Account = label
+
+	q := u.Query()
+	if k.Secret, err = DecodeSecret(q.Get("secret")); err != nil {
+		return nil, err
+	}
+	if issuer := q.Get("issuer"); issuer != "" {
+		k.Issuer = issuer
+	}
+	if alg := q.Get("algorithm"); alg != "" {
+		k.Algorithm = strings.ToUpper(alg)
+	}
+	if digits := q.Get("digits"); digits != "" {
+		if k.Digits, err = strconv.Atoi(digits); err != nil {
+			return nil, fmt.Errorf("otp: invalid digits %q", digits)
+		}
+	}
+	switch k.Type {
+	case TOTP:
+		k.Period = DefaultPeriod
+		if period := q.Get("period"); period != "" {
+			if k.Period, err = strconv.Atoi(period); err != nil || k.Period <= 0 {
+				return nil, fmt.Errorf("otp: invalid period %q", period)
+			}
+		}
+	case HOTP:
+		counter := q.Get("counter")
+		if counter == "" {
+			return nil, errors.New("otp: missing counter")
+		}
+		if k.Counter, err = strconv.ParseUint(counter, 10, 64); err != nil {
+			return nil, fmt.Errorf("otp: invalid counter %q", counter)
+		}
+	}
+	return k, k.check()
+}
+
+// DecodeSecret decodes a base32 secret. Padding, spaces and lowercase letters
+// are accepted.
+func DecodeSecret(s string) ([]byte, error) {
+	s = strings.ToUpper(strings.Replace(s, " ", "", -1))
+	s = strings.TrimRight(s, "=")
+	if n := len(s) % 8; n != 0 {
+		s += strings.Repeat("=", 8-n)
+	}
+	secret, err := base32.StdEncoding.DecodeString(s)
+	if err != nil || len(secret) == 0 {
+		return nil, errors.New("otp: invalid secret")
+	}
+	return secret, nil
+}
+
+// Parse parses an otpauth URI or a base32 TOTP secret, for which the default
+// parameters are used.
+func Parse(s string) (*Key, error) {
+	if strings.HasPrefix(s, "otpauth:") {
+		return ParseURI(s)
+	}
+	secret, err := DecodeSecret(s)
+	if err != nil {
+		return nil, errors.New("otp: invalid key (expected an otpauth:// URI or a base32 secret)")
+	}
+	return &Key{
+		Type:      TOTP,
+		Secret:    secret,
+		Algorithm: DefaultAlgorithm,
+		Digits:    DefaultDigits,
+		Period:    DefaultPeriod,
+	}, nil
+}
+
+// check validates the parameters of k.
+func (k *Key) check() error {
+	if _, err := k.hash(); err != nil {
+		return err
+	}
+	if k.Digits < 6 || k.Digits > 10 {
+		return fmt.Errorf("otp: invalid number of digits %d", k.Digits)
+	}
+	return nil
+}
+
+func (k *Key) hash() (func() hash.Hash, error) {
+	switch k.Algorithm {
+	case "SHA1":
+		return sha1.New, nil
+	case "SHA256":
+		return sha256.New, nil
+	case "SHA512":
+		return sha512.New, nil
+	}
+	return nil, fmt.Errorf("otp: unsupported algorithm %q", k.Algorithm)
+}
+
+// URI returns k as an otpauth URI.
+func (k *Key) URI() string {
+	q := url.Values{}
+	q.Set("secret", strings.TrimRight(base32.StdEncoding.EncodeToString(k.Secret), "="))
+	if k.Issuer != "" {
+		q.Set("issuer", k.Issuer)
+	}
+	q.Set("algorithm", k.Algorithm)
+	q.Set("digits", strconv.Itoa(k.Digits))
+	if k.Type == HOTP {
+		q.Set("counter", strconv.FormatUint(k.Counter, 10))
+	} else {
+		q.Set("period", strconv.Itoa(k.Period))
+	}
+	label := k.Account
+	if k.Issuer != "" {
+		label = k.Issuer + ":" + label
+	}
+	u := url.URL{Scheme: "otpauth", Host: k.Type, Path: "/" + label, RawQuery: q.Encode()}
+	return u.String()
+}
+
+// String describes k without revealing the secret.
+func (k *Key) String() string {
+	if k.Type == HOTP {
+		return fmt.Sprintf("HOTP, %s, %d digits, counter %d", k.Algorithm, k.Digits, k.Counter)
+	}
+	return fmt.Sprintf("TOTP, %s, %d digits, every %ds", k.Algorithm, k.Digits, k.Period)
+}
+
+// Code returns the code of k at time t. For HOTP keys, t is ignored and the
+// code for the current counter is returned; the caller has to increment the
+// counter (see Next).
+func (k *Key) Code(t time.Time) (string, error) {
+	if k.Type == HOTP {
+		return k.code(k.Counter)
+	}
+	if k.Period <= 0 {
+		return "", fmt.Errorf("otp: invalid period %d", k.Period)
+	}
+	return k.code(uint64(t.Unix()) / uint64(k.Period))
+}
+
+// Next returns the code for the current counter of an HOTP key and
+// increments the counter. For TOTP keys, it returns the code at the current
+// time.
+func (k *Key) Next() (string, error) {
+	code, err := k.Code(time.Now())
+	if err == nil && k.Type == HOTP {
+		k.Counter++
+	}
+	return code, err
+}
+
+// Remaining returns the time that the TOTP code at t remains valid.
+func (k *Key) Remaining(t time.Time) time.Duration {
+	period := int64(k.Period) * int64(time.Second)
+	if period <= 0 {
+		return 0
+	}
+	return time.Duration(period - t.UnixNano()%period)
+}
+
+func (k *Key) code(counter uint64) (string, error) {
+	if err := k.check(); err != nil {
+		return "", err
+	}
+	h, _ := k.hash()
+	return Generate(h, k.Secret, counter, k.Digits), nil
+}
+
+// Generate computes an HOTP value (RFC 4226, section 5.3) for the given
+// counter with the given number of digits.
+func Generate(h func() hash.Hash, secret []byte, counter uint64, digits int) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(h, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation
+	off := sum[len(sum)-1] & 0xf
+	bin := uint64(binary.BigEndian.Uint32(sum[off:]) & 0x7fffffff)
+	mod := uint64(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, bin%mod)
+}
@@ -0,0 +1,146 @@
+package otp
+
+import (
+	"crypto/sha1"
+	"testing"
+	"time"
+)
+
+// Test values from RFC 4226, appendix D
+func TestHOTP(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	expected := []string{
+		"755224", "287082", "359152", "969429", "338314",
+		"254676", "287922", "162583", "399871", "520489",
+	}
+	for i, code := range expected {
+		if c := Generate(sha1.New, secret, uint64(i), 6); c != code {
+			t.Errorf("counter %d: %s, expected %s", i, c, code)
+		}
+	}
+
+	k := &Key{Type: HOTP, Secret: secret, Algorithm: "SHA1", Digits: 6, Counter: 3}
+	for _, code := range expected[3:5] {
+		if c, err := k.Next(); err != nil || c != code {
+			t.Errorf("Next() = %s, %v, expected %s", c, err, code)
+		}
+	}
+	if k.Counter != 5 {
+		t.Errorf("counter is %d after two codes, expected 5", k.Counter)
+	}
+}
+
+// Test values from RFC 6238, appendix B
+func TestTOTP(t *testing.T) {
+	secrets := map[string]string{
+		"SHA1":   "12345678901234567890",
+		"SHA256": "12345678901234567890123456789012",
+		"SHA512": "1234567890123456789012345678901234567890123456789012345678901234",
+	}
+	tests := []struct {
+		time      int64
+		algorithm string
+		code      string
+	}{
+		{59, "SHA1", "94287082"},
+		{59, "SHA256", "46119246"},
+		{59, "SHA512", "90693936"},
+		{1111111109, "SHA1", "07081804"},
+		{1111111109, "SHA256", "68084774"},
+		{1111111109, "SHA512", "25091201"},
+		{1111111111, "SHA1", "14050471"},
+		{1111111111, "SHA256", "67062674"},
+		{1111111111, "SHA512", "99943326"},
+		{1234567890, "SHA1", "89005924"},
+		{1234567890, "SHA256", "91819424"},
+		{1234567890, "SHA512", "93441116"},
+		{2000000000, "SHA1", "69279037"},
+		{2000000000, "SHA256", "90698825"},
+		{2000000000, "SHA512", "38618901"},
+		{20000000000, "SHA1", "65353130"},
+		{20000000000, "SHA256", "77737706"},
+		{20000000000, "SHA512", "47863826"},
+	}
+	for _, test := range tests {
+		k := &Key{
+			Type:      TOTP,
+			Secret:    []byte(secrets[test.algorithm]),
+			Algorithm: test.algorithm,
+			Digits:    8,
+			Period:    30,
+		}
+		code, err := k.Code(time.Unix(test.time, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != test.code {
+			t.Errorf("%s at %d: %s, expected %s", test.algorithm, test.time, code, test.code)
+		}
+	}
+
+	k := &Key{Period: 30}
+	if r := k.Remaining(time.Unix(59, 0)); r != time.Second {
+		t.Errorf("Remaining() = %s, expected 1s", r)
+	}
+}
+
+func TestParseURI(t *testing.T) {
+	k, err := ParseURI("otpauth://totp/ACME%20Co:john.doe@email.com?secret=HXDMVJECJJWSRB3HWIZR4IFUGFTMXBOZ&issuer=ACME%20Co&algorithm=SHA256&digits=8&period=60")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.Type != TOTP || k.Issuer != "ACME Co" || k.Account != "john.doe@email.com" ||
+		k.Algorithm != "SHA256" || k.Digits != 8 || k.Period != 60 || len(k.Secret) != 20 {
+		t.Errorf("unexpected key %+v", k)
+	}
+
+	// Round trip
+	k2, err := ParseURI(k.URI())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k2.URI() != k.URI() {
+		t.Errorf("URI() = %s after round trip, expected %s", k2.URI(), k.URI())
+	}
+
+	k, err = ParseURI("otpauth://hotp/alice?secret=jbswy3dpehpk3pxp&counter=42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.Type != HOTP || k.Counter != 42 || k.Algorithm != DefaultAlgorithm ||
+		k.Digits != DefaultDigits || string(k.Secret) != "Hello!\xde\xad\xbe\xef" {
+		t.Errorf("unexpected key %+v", k)
+	}
+
+	invalid := []string{
+		"https://totp/alice?secret=JBSWY3DPEHPK3PXP",
+		"otpauth://motp/alice?secret=JBSWY3DPEHPK3PXP",
+		"otpauth://totp/alice",
+		"otpauth://totp/alice?secret=1234",
+		"otpauth://totp/alice?secret=JBSWY3DPEHPK3PXP&algorithm=MD5",
+		"otpauth://totp/alice?secret=JBSWY3DPEHPK3PXP&digits=4",
+		"otpauth://totp/alice?secret=JBSWY3DPEHPK3PXP&period=0",
+		"otpauth://hotp/alice?secret=JBSWY3DPEHPK3PXP",
+	}
+	for _, s := range invalid {
+		if _, err := ParseURI(s); err == nil {
+			t.Errorf("ParseURI(%q) succeeded", s)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	k, err := Parse("jbsw y3dp ehpk 3pxp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.Type != TOTP || k.Period != DefaultPeriod || string(k.Secret) != "Hello!\xde\xad\xbe\xef" {
+		t.Errorf("unexpected key %+v", k)
+	}
+	if k, err = Parse("otpauth://hotp/alice?secret=JBSWY3DPEHPK3PXP&counter=1"); err != nil || k.Type != HOTP {
+		t.Errorf("unexpected key %+v (%v)", k, err)
+	}
+	if _, err := Parse("not base32!"); err == nil {
+		t.Errorf("Parse succeeded on an invalid secret")
+	}
+}
@@ -5,16 +5,21 @@ import (
 	"github.com/tvdburgt/passman/cache"
 	"net"
 	"net/rpc"
+	"os"
 )
 
 // -timeout=10m (update after each passman call)
 func main() {
 	c := make(cache.Cache)
 	rpc.Register(c)
-	l, err := net.Listen(cache.Network, cache.Address)
+	l, err := net.Listen(cache.Network, cache.Address())
 	if err != nil {
 		panic(err)
 	}
+	// Only the user may connect to the socket
+	if err := os.Chmod(cache.Address(), 0600); err != nil {
+		panic(err)
+	}
 
 	for {
 		conn, err := l.Accept()
@@ -2,11 +2,13 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"flag"
 	"fmt"
+	"github.com/tvdburgt/passman/cache"
 	"github.com/tvdburgt/passman/crypto"
 	"github.com/tvdburgt/passman/store"
-	"github.com/tvdburgt/passman/term"
+	"io"
 	"log"
 	"os"
 	"os/user"
@@ -33,6 +35,8 @@ var commands = []*Command{
 	cmdGet,
 	cmdSet,
 	cmdClip,
+	cmdOtp,
+	cmdQr,
 	cmdInit,
 	cmdImport,
 	cmdExport,
@@ -40,6 +44,13 @@ var commands = []*Command{
 	cmdStat,
 	cmdGen,
 	cmdDelete,
+	cmdMove,
+	cmdShell,
+	cmdBatch,
+	cmdAudit,
+	cmdBreachCheck,
+	cmdDue,
+	cmdCompletion,
 }
 
 // Set default store file
@@ -70,7 +81,7 @@ func init() {
 type Command struct {
 	// Run runs the command.
 	// The args are the arguments after the command name.
-	Run func(cmd *Command, args []string)
+	Run func(cmd *Command, args []string) error
 
 	// UsageLine is the one-line usage message.
 	// The first word in the line is taken to be the command name.
@@ -86,40 +97,70 @@ type Command struct {
 	Flag flag.FlagSet
 }
 
-func readVerifiedPassphrase() []byte {
+// readVerifiedPassphrase prompts for a new passphrase twice. The estimated
+// strength of the passphrase is shown, and passphrases that score below
+// minScore (0-4) are rejected.
+func readVerifiedPassphrase(minScore int) ([]byte, error) {
+	p, err := prompter()
+	if err != nil {
+		return nil, err
+	}
 	for {
-		p1 := term.ReadPassphrase("Enter passphrase: ")
-		p2 := term.ReadPassphrase("Verify passphrase: ")
+		p1, err := p.ReadPassphrase("Enter passphrase: ")
+		if err != nil {
+			return nil, err
+		}
+		if err := checkStrength(p1, minScore); err != nil {
+			crypto.Clear(p1)
+			fmt.Fprintln(os.Stderr, err)
+			continue
+		}
+		p2, err := p.ReadPassphrase("Verify passphrase: ")
+		if err != nil {
+			crypto.Clear(p1)
+			return nil, err
+		}
 		if bytes.Equal(p1, p2) {
-			if len(p1) == 0 {
-				// fatalf("Invalid passphrase, aborting.")
-			}
 			crypto.Clear(p2)
-			return p1
+			return p1, nil
 		}
 		crypto.Clear(p1)
 		crypto.Clear(p2)
-		fmt.Fprintln(os.Stderr, "Passphrases do not match, try again.")
+		if ok, err := p.Confirm("Passphrases do not match. Try again?"); err != nil {
+			return nil, err
+		} else if !ok {
+			return nil, errAborted
+		}
 	}
 }
 
-func writeStore(s *store.Store, passphrase []byte) {
+// saveStore encrypts s with passphrase and writes it to the store file.
+func saveStore(s *store.Store, passphrase []byte) error {
 	file, err := os.OpenFile(storeFile, storeFileCreateFlag, storeFilePerm)
 	if err != nil {
-		fatalf("Unable to write to store: %s", err)
+		return fmt.Errorf("Unable to write to store: %s", err)
 	}
 	defer file.Close()
+	if err := encryptStore(file, s, passphrase); err != nil {
+		return err
+	}
+	cachePassphrase(passphrase)
+	return nil
+}
 
+// encryptStore encrypts s with passphrase and a new salt and writes it to w.
+func encryptStore(w io.Writer, s *store.Store, passphrase []byte) error {
 	// Generate a new random salt
-	err = crypto.ReadRand(s.Header.Salt[:])
+	err := crypto.ReadRand(s.Header.Salt[:])
 	if err != nil {
-		fatalf("Failed to generate salt: %s", err)
+		return fmt.Errorf("Failed to generate salt: %s", err)
 	}
 
-	err = crypto.WriteStore(file, s, passphrase)
+	err = crypto.WriteStore(w, s, passphrase)
 	if err != nil {
-		fatalf("Failed to write to store: %s", err)
+		return fmt.Errorf("Failed to write to store: %s", err)
 	}
+	return nil
 }
 
 func readStore(passphrase []byte) (s *store.Store, err error) {
@@ -138,26 +179,50 @@ func readStore(passphrase []byte) (s *store.Store, err error) {
 }
 
 // Helper function for reading both passphrase and store.
-func openRwStore() (s *store.Store, passphrase []byte) {
+func openRwStore() (*store.Store, []byte, error) {
 	for {
-		passphrase = term.ReadPassphrase("Enter passphrase for %q: ", storeFile)
+		passphrase, err := readStorePassphrase("Enter passphrase for %q: ", storeFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to open store: %s", err)
+		}
 		s, err := readStore(passphrase)
 		if err == nil {
-			return s, passphrase
+			cachePassphrase(passphrase)
+			return s, passphrase, nil
 		}
 		crypto.Clear(passphrase)
-		if err == crypto.ErrWrongPass {
+		if err == crypto.ErrWrongPass && passphraseInteractive() {
 			fmt.Fprintln(os.Stderr, "Incorrect passphrase. Try again.")
 			continue
 		}
-		fatalf("Failed to open store: %s", err)
+		return nil, nil, fmt.Errorf("Failed to open store: %s", err)
 	}
 }
 
-func openStore() *store.Store {
-	s, passphrase := openRwStore()
+// storeSum returns the checksum of the store file, by which the cache agent
+// holds its passphrase.
+func storeSum() (cache.Checksum, error) {
+	file, err := os.Open(storeFile)
+	if err != nil {
+		return cache.Checksum{}, err
+	}
+	defer file.Close()
+	return cache.Sum(file)
+}
+
+// cachePassphrase hands the passphrase of the unlocked (or just written)
+// store to the cache agent, if it is running, so that entry ids can be
+// completed without a prompt.
+func cachePassphrase(passphrase []byte) {
+	if sum, err := storeSum(); err == nil {
+		cache.CacheKey(sum, passphrase)
+	}
+}
+
+func openStore() (*store.Store, error) {
+	s, passphrase, err := openRwStore()
 	crypto.Clear(passphrase)
-	return s
+	return s, err
 }
 
 // Name returns the command's name: the first word in the usage line.
@@ -174,16 +239,23 @@ func (c *Command) Name() string {
 func (c *Command) Usage() {
 	fmt.Fprintf(os.Stderr, "usage: %s\n\n", c.UsageLine)
 	fmt.Fprintf(os.Stderr, "%s\n", strings.TrimSpace(c.Long))
-	os.Exit(1)
 }
 
-func usage() {
-	fmt.Fprintln(os.Stderr, "passman usage")
-	os.Exit(1)
+// errUsage is returned by commands that are run with invalid arguments. The
+// usage message of the command is printed instead of an error.
+var errUsage = errors.New("invalid usage")
+
+// An exitStatus is returned by commands that have reported their results
+// themselves but must still exit with a nonzero status (such as audit with
+// findings). Nothing more is printed.
+type exitStatus int
+
+func (s exitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(s))
 }
 
-func fatalf(format string, args ...interface{}) {
-	log.Printf(format, args...)
+func usage() {
+	fmt.Fprintln(os.Stderr, "passman usage")
 	os.Exit(1)
 }
 
@@ -191,6 +263,7 @@ func fatalf(format string, args ...interface{}) {
 func addFileFlag(cmd *Command) {
 	cmd.Flag.StringVar(&storeFile, "f", storeFile, "")
 	cmd.Flag.StringVar(&storeFile, "file", storeFile, "")
+	addPassphraseFlags(cmd)
 }
 
 // Makes sure the store file path is absolute
@@ -218,10 +291,21 @@ func main() {
 		if cmd.Name() == args[0] && cmd.Run != nil {
 			// cmd.Flag.Usage = func() { cmd.Usage() }
 			cmd.Flag.Usage = cmd.Usage
-			cmd.Flag.Parse(args[1:])
+			if err := cmd.Flag.Parse(args[1:]); err != nil {
+				os.Exit(1)
+			}
 			fixStoreFile()
 			args = cmd.Flag.Args()
-			cmd.Run(cmd, args)
+			err := cmd.Run(cmd, args)
+			if status, ok := err.(exitStatus); ok {
+				os.Exit(int(status))
+			} else if err == errUsage {
+				cmd.Usage()
+				os.Exit(1)
+			} else if err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
 			os.Exit(0)
 		}
 	}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"github.com/tvdburgt/passman/store"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testPassphrase is the passphrase of the stores of tempStore.
+const testPassphrase = "correct horse battery staple"
+
+// tempStore writes s to a store file in a temporary directory, which is
+// removed after the test, and makes the commands use it with its passphrase
+// in a file. It returns the directory, which also holds the socket of the
+// cache agent.
+func tempStore(t *testing.T, s *store.Store) string {
+	dir, err := ioutil.TempDir("", "passman")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("XDG_RUNTIME_DIR", dir)
+	file, pfile := storeFile, passphraseFile
+	t.Cleanup(func() {
+		storeFile, passphraseFile, sourcePassphrase = file, pfile, nil
+		os.RemoveAll(dir)
+	})
+	storeFile = filepath.Join(dir, "store")
+	passphraseFile = filepath.Join(dir, "passphrase")
+	sourcePassphrase = nil
+	if err := ioutil.WriteFile(passphraseFile, []byte(testPassphrase), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveStore(s, []byte(testPassphrase)); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/tvdburgt/passman/crypto"
+	"github.com/tvdburgt/passman/pinentry"
+	"github.com/tvdburgt/passman/strength"
+	"github.com/tvdburgt/passman/term"
+	"io"
+	"os"
+	"os/exec"
+)
+
+// The store passphrase is normally read from the terminal. For use in
+// scripts, cron jobs and pipelines, it can be obtained from one of the
+// following sources instead (in decreasing order of precedence):
+//
+//	-passphrase-fd N		first line read from file descriptor N
+//	-passphrase-file path		first line of the given file
+//	-passphrase-env			value of $PASSMAN_PASSPHRASE
+//	$PASSMAN_PASSPHRASE_COMMAND	output of a shell command
+//
+// $PASSMAN_PASSPHRASE is only used if -passphrase-env is given, since the
+// environment of a process is easily exposed (e.g., through /proc).
+//
+// If none of these is configured and stdin is not a terminal, the passphrase
+// is prompted for with the pinentry program in $PASSMAN_PINENTRY or, in a
+// graphical session ($DISPLAY or $WAYLAND_DISPLAY), with "pinentry".
+// Otherwise, passman fails instead of waiting for a passphrase.
+const (
+	passphraseEnvKey        = "PASSMAN_PASSPHRASE"
+	passphraseCommandEnvKey = "PASSMAN_PASSPHRASE_COMMAND"
+	pinentryEnvKey          = "PASSMAN_PINENTRY"
+)
+
+var (
+	passphraseFd   = -1
+	passphraseFile string
+	passphraseEnv  = false
+)
+
+// errNoPassphrase is returned if the passphrase can't be prompted for and
+// no other source is configured.
+var errNoPassphrase = errors.New("no passphrase source: stdin is not a terminal " +
+	"and no pinentry program can be used (use -passphrase-fd, -passphrase-file, " +
+	"-passphrase-env or $" + passphraseCommandEnvKey + ")")
+
+// Minimum strength score (0-4, see package strength) of new store
+// passphrases. The default requires an estimated 10^8 guesses.
+const defaultMinScore = 3
+
+var minScore = defaultMinScore
+
+// Passphrase obtained from a non-interactive source. Sources are read once,
+// since a file descriptor can't be read twice.
+var sourcePassphrase []byte
+
+// Adds passphrase source flags for store-specific commands.
+func addPassphraseFlags(cmd *Command) {
+	cmd.Flag.IntVar(&passphraseFd, "passphrase-fd", passphraseFd, "")
+	cmd.Flag.StringVar(&passphraseFile, "passphrase-file", passphraseFile, "")
+	cmd.Flag.BoolVar(&passphraseEnv, "passphrase-env", passphraseEnv, "")
+}
+
+// Adds the -min-score flag for commands that create a store.
+func addMinScoreFlag(cmd *Command) {
+	cmd.Flag.IntVar(&minScore, "min-score", minScore, "")
+}
+
+// passphraseSource returns a function that reads the passphrase from the
+// configured non-interactive source, or nil if none is configured.
+func passphraseSource() func() ([]byte, error) {
+	switch {
+	case passphraseFd >= 0:
+		return func() ([]byte, error) {
+			f := os.NewFile(uintptr(passphraseFd), "passphrase-fd")
+			defer f.Close()
+			return readFirstLine(f)
+		}
+	case passphraseFile != "":
+		return func() ([]byte, error) {
+			f, err := os.Open(passphraseFile)
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			return readFirstLine(f)
+		}
+	case passphraseEnv:
+		return func() ([]byte, error) {
+			v := os.Getenv(passphraseEnvKey)
+			if v == "" {
+				return nil, fmt.Errorf("$%s is not set", passphraseEnvKey)
+			}
+			return []byte(v), nil
+		}
+	case os.Getenv(passphraseCommandEnvKey) != "":
+		return func() ([]byte, error) {
+			return runPassphraseCommand(os.Getenv(passphraseCommandEnvKey))
+		}
+	}
+	return nil
+}
+
+// prompter returns the Prompter for interactive input: the terminal if stdin
+// is one, or else a pinentry program. Without a configured program, pinentry
+// is only used in a graphical session, as it might otherwise wait for input
+// on a terminal of its own (e.g., under cron).
+func prompter() (term.Prompter, error) {
+	if term.IsTerminal() {
+		return term.Terminal{}, nil
+	}
+	program := os.Getenv(pinentryEnvKey)
+	if program == "" && os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "" {
+		return nil, errNoPassphrase
+	}
+	p := pinentry.New(program)
+	if !p.Available() {
+		return nil, errNoPassphrase
+	}
+	return p, nil
+}
+
+// passphraseInteractive reports whether the passphrase is prompted for.
+func passphraseInteractive() bool {
+	return passphraseSource() == nil
+}
+
+// readStorePassphrase obtains the store passphrase from the configured
+// source or, if there is none, prompts for it.
+func readStorePassphrase(prompt string, args ...interface{}) ([]byte, error) {
+	if src := passphraseSource(); src != nil {
+		if sourcePassphrase == nil {
+			p, err := src()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read passphrase: %s", err)
+			}
+			sourcePassphrase = p
+		}
+		// Return a copy, as callers clear the passphrase after use
+		return append([]byte(nil), sourcePassphrase...), nil
+	}
+	p, err := prompter()
+	if err != nil {
+		return nil, err
+	}
+	passphrase, err := p.ReadPassphrase(fmt.Sprintf(prompt, args...))
+	if err == nil && len(passphrase) == 0 {
+		// Stores never have an empty passphrase
+		err = errors.New("no passphrase entered")
+	}
+	return passphrase, err
+}
+
+// newStorePassphrase obtains the passphrase for a new store. A prompted
+// passphrase has to be entered twice. The passphrase has to score at least
+// minScore.
+func newStorePassphrase() ([]byte, error) {
+	if !passphraseInteractive() {
+		p, err := readStorePassphrase("")
+		if err != nil {
+			return nil, err
+		}
+		if err := checkStrength(p, minScore); err != nil {
+			crypto.Clear(p)
+			return nil, err
+		}
+		return p, nil
+	}
+	return readVerifiedPassphrase(minScore)
+}
+
+// checkStrength prints the estimated strength of passphrase to stderr. An
+// error is returned if the passphrase is empty or scores below min.
+func checkStrength(passphrase []byte, min int) error {
+	if len(passphrase) == 0 {
+		return errors.New("empty passphrases are not allowed")
+	}
+	r := strength.Estimate(string(passphrase))
+	fmt.Fprintf(os.Stderr, "Estimated strength: %s\n", r)
+	if r.Warning != "" {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", r.Warning)
+	}
+	if r.Score < min {
+		return fmt.Errorf("passphrase is too weak (score %d, minimum is %d)", r.Score, min)
+	}
+	return nil
+}
+
+// readFirstLine returns the first line of r without the line terminator.
+func readFirstLine(r io.Reader) ([]byte, error) {
+	line, err := bufio.NewReader(r).ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		crypto.Clear(line)
+		return nil, err
+	}
+	return bytes.TrimRight(line, "\r\n"), nil
+}
+
+// runPassphraseCommand runs command with the shell and returns the first
+// line of its output. The command inherits stdin and stderr, so it may
+// interact with the user (e.g., to unlock a keyring).
+func runPassphraseCommand(command string) ([]byte, error) {
+	cmd := exec.Command("/bin/sh", "-c", command)
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		crypto.Clear(out)
+		return nil, fmt.Errorf("$%s: %s", passphraseCommandEnvKey, err)
+	}
+	defer crypto.Clear(out)
+	return readFirstLine(bytes.NewReader(out))
+}
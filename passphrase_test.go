@@ -0,0 +1,95 @@
+package main
+
+import (
+	"github.com/tvdburgt/passman/pinentry"
+	"github.com/tvdburgt/passman/store"
+	"github.com/tvdburgt/passman/term"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// Without a terminal, pinentry is only used if it is configured or can show
+// a dialog in a graphical session.
+func TestPrompter(t *testing.T) {
+	if term.IsTerminal() {
+		t.Skip("stdin is a terminal")
+	}
+	tests := []struct {
+		pinentry, display, wayland string
+		ok                         bool
+	}{
+		{"", "", "", false},
+		{"/nonexistent/pinentry", "", "", false},
+		{"true", "", "", true},
+		{"", ":0", "", true},
+		{"", "", "wayland-0", true},
+	}
+	for _, test := range tests {
+		t.Setenv(pinentryEnvKey, test.pinentry)
+		t.Setenv("DISPLAY", test.display)
+		t.Setenv("WAYLAND_DISPLAY", test.wayland)
+		// "pinentry" itself may not be installed
+		if test.pinentry == "" && test.ok && !pinentry.New("").Available() {
+			continue
+		}
+		p, err := prompter()
+		if test.ok && (err != nil || p == nil) || !test.ok && err != errNoPassphrase {
+			t.Errorf("%+v: prompter %v, error %v", test, p, err)
+		}
+	}
+}
+
+// Fake pinentry that greets and then answers GETPIN with $GETPIN_REPLY. It
+// exits after the reply if $GETPIN_EXIT is set.
+const fakePinentry = `#!/bin/sh
+echo "OK Pleased to meet you"
+while read -r cmd rest; do
+	case "$cmd" in
+	GETPIN)
+		echo "$GETPIN_REPLY"
+		[ -n "$GETPIN_EXIT" ] && exit 1
+		;;
+	*) echo "OK" ;;
+	esac
+done
+`
+
+// A store isn't opened if pinentry is cancelled, fails or returns nothing.
+func TestOpenStorePinentry(t *testing.T) {
+	if term.IsTerminal() {
+		t.Skip("stdin is a terminal")
+	}
+	dir := tempStore(t, store.NewStore())
+	passphraseFile = ""
+	program := filepath.Join(dir, "pinentry")
+	if err := ioutil.WriteFile(program, []byte(fakePinentry), 0700); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(pinentryEnvKey, program)
+	t.Setenv(passphraseCommandEnvKey, "")
+
+	tests := []struct {
+		reply    string
+		exit     bool
+		expected string
+	}{
+		{"ERR 83886179 Operation cancelled <Pinentry>", false, "pinentry: operation cancelled"},
+		{"ERR 83886254 No pinentry", false, "pinentry: No pinentry"},
+		{"OK", false, "no passphrase entered"},
+		{"D correct horse", true, "pinentry: EOF"},
+	}
+	for _, test := range tests {
+		t.Setenv("GETPIN_REPLY", test.reply)
+		exit := ""
+		if test.exit {
+			exit = "1"
+		}
+		t.Setenv("GETPIN_EXIT", exit)
+		s, passphrase, err := openRwStore()
+		if err == nil || err.Error() != "Failed to open store: "+test.expected ||
+			s != nil || passphrase != nil {
+			t.Errorf("%s: store %v, passphrase %q, error %v", test.reply, s, passphrase, err)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+// Package pinentry obtains passphrases through a pinentry program (as used
+// by GnuPG), which lets passman prompt for a passphrase without a terminal,
+// e.g. when launched from a window manager key binding.
+//
+// Pinentry programs speak the Assuan protocol on stdin and stdout. Each
+// request starts a new pinentry process, sends a couple of commands and
+// parses the replies:
+//
+//	S: OK Pleased to meet you
+//	C: SETDESC Enter passphrase for %22/home/tman/.pass_store%22
+//	S: OK
+//	C: GETPIN
+//	S: D secret
+//	S: OK
+//	C: BYE
+//
+// See https://www.gnupg.org/documentation/manuals/assuan/ for the protocol
+// and https://www.gnupg.org/related_software/pinentry/ for the commands.
+package pinentry
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/tvdburgt/passman/crypto"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// DefaultProgram is the pinentry program used if none is configured.
+const DefaultProgram = "pinentry"
+
+// ErrCancelled is returned if the user cancels the dialog.
+var ErrCancelled = errors.New("pinentry: operation cancelled")
+
+// Assuan error code for a cancelled operation (GPG_ERR_CANCELED)
+const errCodeCancelled = 99
+
+// Pinentry is a term.Prompter that shows pinentry dialogs.
+type Pinentry struct {
+	Program string // Path or name of the pinentry program
+	Title   string // Window title of the dialogs (optional)
+}
+
+// New returns a Pinentry that runs the given program.
+func New(program string) *Pinentry {
+	if program == "" {
+		program = DefaultProgram
+	}
+	return &Pinentry{Program: program, Title: "passman"}
+}
+
+// Available reports whether the pinentry program can be found.
+func (p *Pinentry) Available() bool {
+	_, err := exec.LookPath(p.Program)
+	return err == nil
+}
+
+// ReadPassphrase shows a dialog with prompt as description and returns the
+// entered passphrase.
+func (p *Pinentry) ReadPassphrase(prompt string) ([]byte, error) {
+	var pin []byte
+	err := p.session(func(c *conn) error {
+		if err := c.command("SETDESC", trimPrompt(prompt)); err != nil {
+			return err
+		}
+		if err := c.command("SETPROMPT", "Passphrase:"); err != nil {
+			return err
+		}
+		data, err := c.request("GETPIN")
+		pin = data
+		return err
+	})
+	return pin, err
+}
+
+// Confirm shows prompt in a dialog with OK and Cancel buttons.
+func (p *Pinentry) Confirm(prompt string) (bool, error) {
+	err := p.session(func(c *conn) error {
+		if err := c.command("SETDESC", trimPrompt(prompt)); err != nil {
+			return err
+		}
+		return c.command("CONFIRM")
+	})
+	if err == ErrCancelled {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// session starts the pinentry program, runs fn and ends the session.
+func (p *Pinentry) session(fn func(c *conn) error) (err error) {
+	cmd := exec.Command(p.Program)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err = cmd.Start(); err != nil {
+		return fmt.Errorf("pinentry: %s", err)
+	}
+	defer func() {
+		stdin.Close()
+		if werr := cmd.Wait(); err == nil && werr != nil {
+			err = fmt.Errorf("pinentry: %s", werr)
+		}
+	}()
+
+	c := &conn{w: stdin, r: bufio.NewReader(stdout)}
+
+	// Wait for the greeting
+	if _, err = c.response(); err != nil {
+		return err
+	}
+	if p.Title != "" {
+		if err = c.command("SETTITLE", p.Title); err != nil {
+			return err
+		}
+	}
+	if err = fn(c); err != nil {
+		return err
+	}
+	return c.command("BYE")
+}
+
+// conn is a client connection to an Assuan server.
+type conn struct {
+	w io.Writer
+	r *bufio.Reader
+}
+
+// command sends a command that doesn't return data.
+func (c *conn) command(name string, args ...string) error {
+	data, err := c.request(name, args...)
+	crypto.Clear(data)
+	return err
+}
+
+// request sends a command and returns the data sent in the response.
+func (c *conn) request(name string, args ...string) ([]byte, error) {
+	line := name
+	for _, arg := range args {
+		line += " " + escape(arg)
+	}
+	if _, err := io.WriteString(c.w, line+"\n"); err != nil {
+		return nil, fmt.Errorf("pinentry: %s", err)
+	}
+	return c.response()
+}
+
+// response reads lines until an OK or ERR line and returns the data lines.
+func (c *conn) response() ([]byte, error) {
+	var data []byte
+	for {
+		line, err := c.r.ReadBytes('\n')
+		if err != nil {
+			crypto.Clear(data)
+			crypto.Clear(line)
+			return nil, fmt.Errorf("pinentry: %s", err)
+		}
+		line = bytes.TrimRight(line, "\r\n")
+
+		switch {
+		case bytes.Equal(line, []byte("OK")) || bytes.HasPrefix(line, []byte("OK ")):
+			return data, nil
+		case bytes.HasPrefix(line, []byte("ERR ")):
+			crypto.Clear(data)
+			return nil, parseError(string(line[4:]))
+		case bytes.HasPrefix(line, []byte("D ")):
+			data = append(data, unescape(line[2:])...)
+		}
+		// Status (S), comment (#) and inquire lines are ignored
+		crypto.Clear(line)
+	}
+}
+
+// parseError converts the text of an ERR line ("<code> <description>") to an
+// error.
+func parseError(s string) error {
+	fields := strings.SplitN(s, " ", 2)
+	if code, err := strconv.Atoi(fields[0]); err == nil && code&0xffff == errCodeCancelled {
+		return ErrCancelled
+	}
+	if len(fields) == 2 {
+		return fmt.Errorf("pinentry: %s", fields[1])
+	}
+	return fmt.Errorf("pinentry: error %s", fields[0])
+}
+
+// escape percent-encodes the characters that can't appear literally in an
+// Assuan command line.
+func escape(s string) string {
+	var b bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '%', '\r', '\n':
+			fmt.Fprintf(&b, "%%%02X", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// unescape decodes percent-encoded bytes in a data line.
+func unescape(line []byte) []byte {
+	data := make([]byte, 0, len(line))
+	for i := 0; i < len(line); i++ {
+		if line[i] == '%' && i+2 < len(line) {
+			if c, err := strconv.ParseUint(string(line[i+1:i+3]), 16, 8); err == nil {
+				data = append(data, byte(c))
+				i += 2
+				continue
+			}
+		}
+		data = append(data, line[i])
+	}
+	return data
+}
+
+// trimPrompt strips the trailing colon of terminal-style prompts, which
+// looks odd as a dialog description.
+func trimPrompt(prompt string) string {
+	return strings.TrimRight(strings.TrimSpace(prompt), ":")
+}
@@ -0,0 +1,144 @@
+package pinentry
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Fake pinentry that logs the commands it receives. GETPIN returns a
+// percent-encoded pin and CONFIRM is answered with $CONFIRM_REPLY.
+const fakePinentry = `#!/bin/sh
+echo "OK Pleased to meet you"
+while read -r cmd rest; do
+	echo "$cmd${rest:+ $rest}" >> "$PINENTRY_LOG"
+	case "$cmd" in
+	GETPIN)
+		echo "# a comment"
+		echo "S PASSWORD_FROM_CACHE"
+		echo "D s3cr%25et%0Aline"
+		echo "OK"
+		;;
+	CONFIRM)
+		echo "$CONFIRM_REPLY"
+		;;
+	BYE)
+		echo "OK closing connection"
+		exit 0
+		;;
+	*)
+		echo "OK"
+		;;
+	esac
+done
+`
+
+func setup(t *testing.T) (p *Pinentry, logFile string) {
+	dir, err := ioutil.TempDir("", "pinentry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	program := filepath.Join(dir, "pinentry-fake")
+	if err := ioutil.WriteFile(program, []byte(fakePinentry), 0700); err != nil {
+		t.Fatal(err)
+	}
+	logFile = filepath.Join(dir, "log")
+	os.Setenv("PINENTRY_LOG", logFile)
+	return New(program), logFile
+}
+
+func readLog(t *testing.T, logFile string) []string {
+	b, err := ioutil.ReadFile(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(b)), "\n")
+}
+
+func TestReadPassphrase(t *testing.T) {
+	p, logFile := setup(t)
+	defer os.RemoveAll(filepath.Dir(logFile))
+
+	pin, err := p.ReadPassphrase("Enter passphrase for \"100%\nstore\": ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(pin) != "s3cr%et\nline" {
+		t.Errorf("ReadPassphrase = %q, expected %q", pin, "s3cr%et\nline")
+	}
+
+	expected := []string{
+		"SETTITLE passman",
+		"SETDESC Enter passphrase for \"100%25%0Astore\"",
+		"SETPROMPT Passphrase:",
+		"GETPIN",
+		"BYE",
+	}
+	log := readLog(t, logFile)
+	if strings.Join(log, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected commands:\n%s\nexpected:\n%s",
+			strings.Join(log, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	p, logFile := setup(t)
+	defer os.RemoveAll(filepath.Dir(logFile))
+
+	replies := map[string]bool{
+		"OK":                                    true,
+		"ERR 83886179 Operation cancelled":      false,
+		"ERR 83886194 Not confirmed <Pinentry>": false,
+	}
+	for reply, expected := range replies {
+		os.Setenv("CONFIRM_REPLY", reply)
+		ok, err := p.Confirm("Passphrases do not match. Try again?")
+		if reply == "ERR 83886194 Not confirmed <Pinentry>" {
+			// Errors other than cancellation are reported
+			if err == nil {
+				t.Errorf("Confirm with reply %q: expected error", reply)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Confirm with reply %q: %s", reply, err)
+		}
+		if ok != expected {
+			t.Errorf("Confirm with reply %q = %t, expected %t", reply, ok, expected)
+		}
+	}
+}
+
+func TestCancel(t *testing.T) {
+	p, logFile := setup(t)
+	defer os.RemoveAll(filepath.Dir(logFile))
+
+	// Replace GETPIN reply with a cancellation
+	p.Program = writeScript(t, filepath.Dir(logFile), strings.Replace(fakePinentry,
+		`echo "D s3cr%25et%0Aline"
+		echo "OK"`, `echo "ERR 83886179 Operation cancelled <Pinentry>"`, 1))
+
+	if _, err := p.ReadPassphrase("Enter passphrase: "); err != ErrCancelled {
+		t.Errorf("ReadPassphrase error = %v, expected %v", err, ErrCancelled)
+	}
+}
+
+func TestUnavailable(t *testing.T) {
+	p := New("/nonexistent/pinentry")
+	if p.Available() {
+		t.Error("Available = true for nonexistent program")
+	}
+	if _, err := p.ReadPassphrase("Enter passphrase: "); err == nil {
+		t.Error("ReadPassphrase succeeded for nonexistent program")
+	}
+}
+
+func writeScript(t *testing.T, dir, script string) string {
+	program := filepath.Join(dir, "pinentry-cancel")
+	if err := ioutil.WriteFile(program, []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+	return program
+}
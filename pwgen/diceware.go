@@ -0,0 +1,119 @@
+package pwgen
+
+//go:generate go run mkwordlists.go
+
+import (
+	"bufio"
+	"crypto/rand"
+	"errors"
+	"io"
+	"math"
+	"math/big"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Wordlist is a list of distinct words for diceware passphrases.
+type Wordlist []string
+
+// Wordlists contains the bundled word lists by name.
+var Wordlists = map[string]Wordlist{
+	"large": EFFLarge,
+	"short": EFFShort,
+}
+
+// ReadWordlist reads a word list with one word per line. Lines may be
+// prefixed with dice rolls, as in the EFF and original diceware lists
+// ("11111 abacus"). Empty lines and duplicate words are skipped.
+func ReadWordlist(r io.Reader) (Wordlist, error) {
+	var words Wordlist
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		w := fields[len(fields)-1]
+		if !seen[w] {
+			seen[w] = true
+			words = append(words, w)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(words) < 2 {
+		return nil, errors.New("word list contains less than two words")
+	}
+	return words, nil
+}
+
+// Diceware generates passphrases of randomly chosen words.
+type Diceware struct {
+	Words      Wordlist // Defaults to EFFLarge
+	Separator  string   // Inserted between words
+	Capitalize bool     // Capitalize the first letter of each word
+	Digits     int      // Number of random digits appended to random words
+}
+
+func (d *Diceware) words() Wordlist {
+	if len(d.Words) == 0 {
+		return EFFLarge
+	}
+	return d.Words
+}
+
+// Generate returns a passphrase of n words.
+func (d *Diceware) Generate(n int) ([]byte, error) {
+	if n <= 0 {
+		return nil, errors.New("number of words must be positive")
+	}
+	if d.Digits < 0 {
+		return nil, errors.New("negative number of digits")
+	}
+	list := d.words()
+	words := make([]string, n)
+	for i := range words {
+		j, err := randInt(len(list))
+		if err != nil {
+			return nil, err
+		}
+		words[i] = list[j]
+		if d.Capitalize {
+			r, size := utf8.DecodeRuneInString(words[i])
+			words[i] = string(unicode.ToUpper(r)) + words[i][size:]
+		}
+	}
+	for i := 0; i < d.Digits; i++ {
+		j, err := randInt(n)
+		if err != nil {
+			return nil, err
+		}
+		digit, err := randInt(10)
+		if err != nil {
+			return nil, err
+		}
+		words[j] += DigitSet[digit : digit+1]
+	}
+	return []byte(strings.Join(words, d.Separator)), nil
+}
+
+// Entropy returns the entropy in bits of a passphrase of n words. Each word
+// adds log2 of the list size; capitalization adds nothing, as it applies to
+// every word. Each digit is counted as log2(10) bits, ignoring the choice of
+// word it is appended to.
+func (d *Diceware) Entropy(n int) float64 {
+	return float64(n)*math.Log2(float64(len(d.words()))) +
+		float64(d.Digits)*math.Log2(10)
+}
+
+// randInt returns a uniform random integer in [0, n).
+func randInt(n int) (int, error) {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(i.Int64()), nil
+}
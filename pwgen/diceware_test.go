@@ -0,0 +1,72 @@
+package pwgen
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+func TestWordlists(t *testing.T) {
+	for name, size := range map[string]int{"large": 7776, "short": 1296} {
+		list := Wordlists[name]
+		if len(list) != size {
+			t.Errorf("%s: %d words, expected %d", name, len(list), size)
+		}
+		seen := make(map[string]bool)
+		for _, w := range list {
+			if seen[w] {
+				t.Errorf("%s: duplicate word %q", name, w)
+			}
+			seen[w] = true
+		}
+	}
+}
+
+func TestReadWordlist(t *testing.T) {
+	r := strings.NewReader("11111\tabacus\n11112 abdomen\n\nzoom\nabacus\n")
+	list, err := ReadWordlist(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (Wordlist{"abacus", "abdomen", "zoom"}); !reflect.DeepEqual(list, expected) {
+		t.Errorf("ReadWordlist = %q, expected %q", list, expected)
+	}
+	if _, err := ReadWordlist(strings.NewReader("one\n")); err == nil {
+		t.Error("ReadWordlist succeeded for a single word")
+	}
+}
+
+func TestDiceware(t *testing.T) {
+	d := &Diceware{Words: Wordlist{"alpha", "beta", "gamma", "delta"},
+		Separator: "-", Capitalize: true, Digits: 2}
+	for i := 0; i < 100; i++ {
+		p, err := d.Generate(5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		words := strings.Split(string(p), "-")
+		if len(words) != 5 {
+			t.Fatalf("%q doesn't contain 5 words", p)
+		}
+		digits := 0
+		for _, w := range words {
+			if !unicode.IsUpper(rune(w[0])) {
+				t.Fatalf("%q is not capitalized", w)
+			}
+			digits += len(w) - len(strings.TrimRightFunc(w, unicode.IsDigit))
+		}
+		if digits != 2 {
+			t.Fatalf("%q doesn't contain 2 digits", p)
+		}
+	}
+
+	if bits, expected := d.Entropy(5), 5*2+2*math.Log2(10); math.Abs(bits-expected) > 1e-9 {
+		t.Errorf("Entropy(5) = %f, expected %f", bits, expected)
+	}
+	d = new(Diceware)
+	if bits, expected := d.Entropy(6), 6*math.Log2(7776); math.Abs(bits-expected) > 1e-9 {
+		t.Errorf("Entropy(6) = %f, expected %f", bits, expected)
+	}
+}
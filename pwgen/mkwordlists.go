@@ -0,0 +1,76 @@
+//go:build ignore
+// +build ignore
+
+// mkwordlists generates wordlists.go from the EFF diceware word lists:
+//
+//	https://www.eff.org/files/2016/07/18/eff_large_wordlist.txt
+//	https://www.eff.org/files/2016/09/08/eff_short_wordlist_2_0.txt
+//
+// Run it with both files in the current directory:
+//
+//	go run mkwordlists.go
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+)
+
+var lists = []struct {
+	name, file, comment string
+	size                int
+}{
+	{"EFFLarge", "eff_large_wordlist.txt", "EFFLarge is the EFF large word list for five dice (12.9 bits per word).", 7776},
+	{"EFFShort", "eff_short_wordlist_2_0.txt", "EFFShort is the EFF short word list (version 2.0) for four dice\n// (10.3 bits per word). Each word has a unique three-character prefix.", 1296},
+}
+
+func main() {
+	var b bytes.Buffer
+	fmt.Fprint(&b, "// Code generated by mkwordlists.go; DO NOT EDIT.\n\npackage pwgen\n")
+	for _, l := range lists {
+		words, err := readList(l.file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(words) != l.size {
+			log.Fatalf("%s: %d words, expected %d", l.file, len(words), l.size)
+		}
+		fmt.Fprintf(&b, "\n// %s\nvar %s = Wordlist{\n", l.comment, l.name)
+		for _, w := range words {
+			fmt.Fprintf(&b, "\t%q,\n", w)
+		}
+		fmt.Fprint(&b, "}\n")
+	}
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile("wordlists.go", src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// readList reads the words of a list with lines of the form "11111\tword".
+func readList(name string) ([]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 {
+			words = append(words, fields[1])
+		}
+	}
+	return words, scanner.Err()
+}
@@ -0,0 +1,150 @@
+package pwgen
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+const (
+	VowelSet     = "aeiou"
+	ConsonantSet = "bcdfghjklmnpqrstvwxyz"
+)
+
+// Placeholders of password patterns and the characters they stand for.
+var placeholders = map[byte]string{
+	'c': ConsonantSet,
+	'C': toUpper(ConsonantSet),
+	'v': VowelSet,
+	'V': toUpper(VowelSet),
+	'l': LowerSet,
+	'L': UpperSet,
+	'a': LowerSet + UpperSet,
+	'9': DigitSet,
+	's': SymbolSet,
+	'x': AsciiSet,
+}
+
+func toUpper(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		b[i] = c - 'a' + 'A'
+	}
+	return string(b)
+}
+
+// Pattern is a template for passwords. Each element is either a set of
+// characters to choose from or a literal character (a set of one).
+type Pattern []string
+
+// ParsePattern parses a password template such as "Cvccvc-99-Cvccvc". The
+// placeholders are:
+//
+//	c	lowercase consonant
+//	C	uppercase consonant
+//	v	lowercase vowel
+//	V	uppercase vowel
+//	l	lowercase letter
+//	L	uppercase letter
+//	a	letter of either case
+//	9	digit
+//	s	symbol
+//	x	printable ASCII character
+//
+// Any other character is copied literally; a backslash makes the next
+// character literal, e.g. "\9".
+func ParsePattern(s string) (Pattern, error) {
+	var p Pattern
+	random := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '\\' {
+			if i++; i == len(s) {
+				return nil, errors.New("pattern ends with a backslash")
+			}
+			p = append(p, s[i:i+1])
+			continue
+		}
+		if set, ok := placeholders[c]; ok {
+			p = append(p, set)
+			random = true
+		} else {
+			p = append(p, s[i:i+1])
+		}
+	}
+	if !random {
+		return nil, fmt.Errorf("pattern %q contains no placeholders", s)
+	}
+	return p, nil
+}
+
+// Generate returns a random password that matches p.
+func (p Pattern) Generate() ([]byte, error) {
+	password := make([]byte, len(p))
+	for i, set := range p {
+		j, err := randInt(len(set))
+		if err != nil {
+			return nil, err
+		}
+		password[i] = set[j]
+	}
+	return password, nil
+}
+
+// Entropy returns the entropy of a password generated from p in bits.
+func (p Pattern) Entropy() float64 {
+	bits := 0.0
+	for _, set := range p {
+		bits += math.Log2(float64(len(set)))
+	}
+	return bits
+}
+
+// Pronounceable returns a lowercase password of n characters that alternates
+// between consonants and vowels (e.g., "tifomeruka"). Whether it starts with
+// a consonant or a vowel is chosen at random as well, weighted so that each
+// possible password is equally likely.
+func Pronounceable(n int) ([]byte, error) {
+	if n <= 0 {
+		return nil, errors.New("password length must be positive")
+	}
+	fromConsonant, total := pronounceableCount(n)
+	r, err := rand.Int(rand.Reader, total)
+	if err != nil {
+		return nil, err
+	}
+	sets := [2]string{VowelSet, ConsonantSet}
+	if r.Cmp(fromConsonant) < 0 {
+		sets[0], sets[1] = sets[1], sets[0]
+	}
+	p := make(Pattern, n)
+	for i := range p {
+		p[i] = sets[i%2]
+	}
+	return p.Generate()
+}
+
+// PronounceableEntropy returns the entropy of a password of n characters
+// generated by Pronounceable in bits.
+func PronounceableEntropy(n int) float64 {
+	if n <= 0 {
+		return 0
+	}
+	_, total := pronounceableCount(n)
+	return log2(total)
+}
+
+// pronounceableCount returns the number of pronounceable passwords of length
+// n that start with a consonant, and the total number.
+func pronounceableCount(n int) (fromConsonant, total *big.Int) {
+	c := big.NewInt(int64(len(ConsonantSet)))
+	v := big.NewInt(int64(len(VowelSet)))
+	a, b := big.NewInt(int64((n+1)/2)), big.NewInt(int64(n/2))
+
+	// c^a * v^b and v^a * c^b
+	fromConsonant = new(big.Int).Mul(new(big.Int).Exp(c, a, nil), new(big.Int).Exp(v, b, nil))
+	fromVowel := new(big.Int).Mul(new(big.Int).Exp(v, a, nil), new(big.Int).Exp(c, b, nil))
+	return fromConsonant, new(big.Int).Add(fromConsonant, fromVowel)
+}
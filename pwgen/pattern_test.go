@@ -0,0 +1,87 @@
+package pwgen
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestPattern(t *testing.T) {
+	p, err := ParsePattern(`Cvccvc-99-\9x`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := 2*math.Log2(21) + 2*math.Log2(21) + math.Log2(5) +
+		math.Log2(5) + 2*math.Log2(10) + math.Log2(94)
+	if bits := p.Entropy(); math.Abs(bits-expected) > 1e-9 {
+		t.Errorf("Entropy() = %f, expected %f", bits, expected)
+	}
+
+	for i := 0; i < 100; i++ {
+		password, err := p.Generate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := string(password)
+		if len(s) != 12 || s[6] != '-' || s[9] != '-' || s[10] != '9' {
+			t.Fatalf("%q doesn't match pattern", s)
+		}
+		for i, set := range []string{toUpper(ConsonantSet), VowelSet, ConsonantSet,
+			ConsonantSet, VowelSet, ConsonantSet} {
+			if strings.IndexByte(set, s[i]) < 0 {
+				t.Fatalf("%q: character %d not in %q", s, i, set)
+			}
+		}
+	}
+
+	for _, s := range []string{"", "-_-", `vc\`} {
+		if _, err := ParsePattern(s); err == nil {
+			t.Errorf("ParsePattern(%q) succeeded", s)
+		}
+	}
+}
+
+// isPronounceable reports whether s alternates between consonants and
+// vowels.
+func isPronounceable(s string) bool {
+	for i := 1; i < len(s); i++ {
+		if strings.IndexByte(VowelSet, s[i]) >= 0 == (strings.IndexByte(VowelSet, s[i-1]) >= 0) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPronounceable(t *testing.T) {
+	starts := make(map[bool]int)
+	for i := 0; i < 200; i++ {
+		p, err := Pronounceable(9)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(p) != 9 || !isPronounceable(string(p)) {
+			t.Fatalf("%q is not pronounceable", p)
+		}
+		starts[strings.IndexByte(VowelSet, p[0]) >= 0]++
+	}
+	// Passwords of odd length mostly start with a consonant
+	if starts[true] > starts[false] {
+		t.Errorf("%d of 200 passwords start with a vowel", starts[true])
+	}
+
+	// Count all pronounceable passwords of length 3 by brute force
+	count := 0
+	letters := VowelSet + ConsonantSet
+	for _, a := range letters {
+		for _, b := range letters {
+			for _, c := range letters {
+				if isPronounceable(string([]rune{a, b, c})) {
+					count++
+				}
+			}
+		}
+	}
+	if bits, expected := PronounceableEntropy(3), math.Log2(float64(count)); math.Abs(bits-expected) > 1e-9 {
+		t.Errorf("PronounceableEntropy(3) = %f, expected %f", bits, expected)
+	}
+}
@@ -0,0 +1,364 @@
+// Package pwgen generates passwords that follow site-specific rules.
+//
+// A Policy restricts the alphabet of a password and can require a minimum
+// number of characters from each character class. Passwords are drawn
+// uniformly from the set of all strings that satisfy the policy, so the
+// entropy of a password is the base-2 logarithm of the size of that set.
+package pwgen
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/tvdburgt/passman/store"
+	"io"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Character classes
+const (
+	Lower = iota
+	Upper
+	Digit
+	Symbol
+	other // Characters outside the classes above; never required
+	numClasses
+)
+
+const (
+	LowerSet  = "abcdefghijklmnopqrstuvwxyz"
+	UpperSet  = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	DigitSet  = "0123456789"
+	SymbolSet = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+
+	// AsciiSet contains all printable ASCII characters, except space.
+	AsciiSet = LowerSet + UpperSet + DigitSet + SymbolSet
+
+	// Lookalikes contains characters that are easily confused with one
+	// another in common fonts.
+	Lookalikes = "0Oo1lI|"
+)
+
+// Policy holds the rules for generated passwords. It is the policy stored
+// with entries, so it converts to and from store.Policy. The zero value allows
+// all printable ASCII characters without further constraints.
+type Policy store.Policy
+
+// Profiles contains the built-in named policies.
+var Profiles = map[string]*Policy{
+	"default":  {},
+	"alnum":    {Alphabet: LowerSet + UpperSet + DigitSet},
+	"pin":      {Length: 6, Alphabet: DigitSet},
+	"readable": {Length: 24, NoLookalikes: true, Exclude: "`'\"\\"},
+	"strict": {Length: 20, NoLookalikes: true, MinLower: 1, MinUpper: 1,
+		MinDigit: 1, MinSymbol: 1},
+}
+
+// classOf returns the character class of c.
+func classOf(c byte) int {
+	switch {
+	case 'a' <= c && c <= 'z':
+		return Lower
+	case 'A' <= c && c <= 'Z':
+		return Upper
+	case '0' <= c && c <= '9':
+		return Digit
+	case strings.IndexByte(SymbolSet, c) >= 0:
+		return Symbol
+	}
+	return other
+}
+
+// Charset returns the characters allowed by p, sorted and without
+// duplicates.
+func (p *Policy) Charset() []byte {
+	alphabet := p.Alphabet
+	if alphabet == "" {
+		alphabet = AsciiSet
+	}
+	exclude := p.Exclude
+	if p.NoLookalikes {
+		exclude += Lookalikes
+	}
+
+	var set []byte
+	seen := make(map[byte]bool)
+	for i := 0; i < len(alphabet); i++ {
+		c := alphabet[i]
+		if seen[c] || strings.IndexByte(exclude, c) >= 0 {
+			continue
+		}
+		seen[c] = true
+		set = append(set, c)
+	}
+	sort.Sort(byteSlice(set))
+	return set
+}
+
+type byteSlice []byte
+
+func (b byteSlice) Len() int           { return len(b) }
+func (b byteSlice) Less(i, j int) bool { return b[i] < b[j] }
+func (b byteSlice) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+
+func (p *Policy) minimums() [numClasses]int {
+	return [numClasses]int{p.MinLower, p.MinUpper, p.MinDigit, p.MinSymbol, 0}
+}
+
+// classes partitions the charset of p into character classes.
+func (p *Policy) classes() (sets [numClasses][]byte) {
+	for _, c := range p.Charset() {
+		k := classOf(c)
+		sets[k] = append(sets[k], c)
+	}
+	return
+}
+
+// Validate checks whether passwords of length n can satisfy p.
+func (p *Policy) Validate(n int) error {
+	if n <= 0 {
+		return errors.New("password length must be positive")
+	}
+	if p.MaxLength > 0 && n > p.MaxLength {
+		return fmt.Errorf("length %d exceeds maximum length %d", n, p.MaxLength)
+	}
+	sets := p.classes()
+	total, sum := 0, 0
+	for k, min := range p.minimums() {
+		if min < 0 {
+			return errors.New("negative minimum")
+		}
+		if min > 0 && len(sets[k]) == 0 {
+			return fmt.Errorf("no %s characters available", classNames[k])
+		}
+		sum += min
+		total += len(sets[k])
+	}
+	if total == 0 {
+		return errors.New("no characters available")
+	}
+	if sum > n {
+		return fmt.Errorf("length %d is too short for %d required characters", n, sum)
+	}
+	return nil
+}
+
+var classNames = [numClasses]string{"lowercase", "uppercase", "digit", "symbol", "other"}
+
+// DefaultLength returns the length of passwords when no length is given:
+// p.Length, or fallback capped to p.MaxLength.
+func (p *Policy) DefaultLength(fallback int) int {
+	if p.Length > 0 {
+		return p.Length
+	}
+	if p.MaxLength > 0 && fallback > p.MaxLength {
+		return p.MaxLength
+	}
+	return fallback
+}
+
+// Generate returns a random password of length n that satisfies p. Each
+// satisfying password is equally likely.
+func (p *Policy) Generate(n int) ([]byte, error) {
+	if err := p.Validate(n); err != nil {
+		return nil, err
+	}
+	c := newCounter(p)
+	need := p.minimums()
+	password := make([]byte, n)
+
+	for i := range password {
+		remaining := n - i
+
+		// Select a class, weighted by the number of completions
+		r, err := rand.Int(rand.Reader, c.count(remaining, need))
+		if err != nil {
+			return nil, err
+		}
+		k := 0
+		for ; k < numClasses; k++ {
+			w := c.weight(remaining, need, k)
+			if r.Cmp(w) < 0 {
+				break
+			}
+			r.Sub(r, w)
+		}
+
+		// Select a character of that class uniformly
+		set := c.sets[k]
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(len(set))))
+		if err != nil {
+			return nil, err
+		}
+		password[i] = set[j.Int64()]
+		if need[k] > 0 {
+			need[k]--
+		}
+	}
+	return password, nil
+}
+
+// Entropy returns the entropy in bits of a password of length n generated
+// by p. This is less than n*log2(len(charset)) if characters are required.
+func (p *Policy) Entropy(n int) float64 {
+	if p.Validate(n) != nil {
+		return 0
+	}
+	return log2(newCounter(p).count(n, p.minimums()))
+}
+
+// log2 returns the base-2 logarithm of a positive x.
+func log2(x *big.Int) float64 {
+	f := new(big.Float).SetInt(x)
+	mant := new(big.Float)
+	exp := f.MantExp(mant) // x = mant * 2^exp, 0.5 <= mant < 1
+	m, _ := mant.Float64()
+	return float64(exp) + math.Log2(m)
+}
+
+// counter counts the passwords that satisfy the minimums of a policy.
+type counter struct {
+	sets [numClasses][]byte
+	memo map[string]*big.Int
+}
+
+func newCounter(p *Policy) *counter {
+	return &counter{sets: p.classes(), memo: make(map[string]*big.Int)}
+}
+
+// count returns the number of strings of length n that contain at least
+// need[k] characters of each class k.
+func (c *counter) count(n int, need [numClasses]int) *big.Int {
+	sum := 0
+	for _, v := range need {
+		sum += v
+	}
+	if sum > n {
+		return new(big.Int)
+	}
+	if n == 0 {
+		return big.NewInt(1)
+	}
+
+	key := fmt.Sprint(n, need)
+	if v, ok := c.memo[key]; ok {
+		return v
+	}
+	total := new(big.Int)
+	for k := 0; k < numClasses; k++ {
+		total.Add(total, c.weight(n, need, k))
+	}
+	c.memo[key] = total
+	return total
+}
+
+// weight returns the number of satisfying strings of length n that start
+// with a character of class k.
+func (c *counter) weight(n int, need [numClasses]int, k int) *big.Int {
+	if len(c.sets[k]) == 0 {
+		return new(big.Int)
+	}
+	if need[k] > 0 {
+		need[k]--
+	}
+	w := big.NewInt(int64(len(c.sets[k])))
+	return w.Mul(w, c.count(n-1, need))
+}
+
+// String returns p in the format accepted by ParseSpec.
+func (p *Policy) String() string {
+	return (*store.Policy)(p).String()
+}
+
+// ParseSpec parses a comma-separated list of policy rules, e.g.
+// "max-length=16,min-digit=1,exclude=%&". Commas and backslashes in values
+// are escaped with a backslash. The rules are:
+//
+//	length=n		default password length
+//	max-length=n		maximum password length
+//	alphabet=chars		allowed characters (default: printable ASCII)
+//	exclude=chars		characters that are not allowed
+//	no-lookalikes		exclude easily confused characters (0Oo1lI|)
+//	min-lower=n		minimum number of lowercase letters
+//	min-upper=n		minimum number of uppercase letters
+//	min-digit=n		minimum number of digits
+//	min-symbol=n		minimum number of symbols
+func ParseSpec(spec string) (*Policy, error) {
+	p := new(Policy)
+	for _, field := range splitEscaped(spec) {
+		if field == "" {
+			continue
+		}
+		key, value := field, ""
+		if i := strings.Index(field, "="); i >= 0 {
+			key, value = field[:i], field[i+1:]
+		}
+
+		var ip *int
+		switch key {
+		case "length":
+			ip = &p.Length
+		case "max-length":
+			ip = &p.MaxLength
+		case "min-lower":
+			ip = &p.MinLower
+		case "min-upper":
+			ip = &p.MinUpper
+		case "min-digit":
+			ip = &p.MinDigit
+		case "min-symbol":
+			ip = &p.MinSymbol
+		case "alphabet":
+			p.Alphabet = value
+		case "exclude":
+			p.Exclude = value
+		case "no-lookalikes":
+			p.NoLookalikes = true
+		default:
+			return nil, fmt.Errorf("unknown policy rule %q", key)
+		}
+		if ip != nil {
+			v, err := strconv.Atoi(value)
+			if err != nil || v < 0 {
+				return nil, fmt.Errorf("invalid value for %s: %q", key, value)
+			}
+			*ip = v
+		}
+	}
+	return p, nil
+}
+
+// splitEscaped splits s at commas that are not escaped by a backslash.
+func splitEscaped(s string) []string {
+	var fields []string
+	var b bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s):
+			i++
+			b.WriteByte(s[i])
+		case s[i] == ',':
+			fields = append(fields, b.String())
+			b.Reset()
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return append(fields, b.String())
+}
+
+// LoadProfiles reads named policies from a JSON object that maps names to
+// policies, e.g. {"bank": {"max_length": 16, "alphabet": "..."}}.
+func LoadProfiles(r io.Reader) (map[string]*Policy, error) {
+	profiles := make(map[string]*Policy)
+	if err := json.NewDecoder(r).Decode(&profiles); err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
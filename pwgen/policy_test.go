@@ -0,0 +1,143 @@
+package pwgen
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// bruteCount counts the strings of length n over p's charset that satisfy
+// the class minimums.
+func bruteCount(p *Policy, n int) int {
+	set := p.Charset()
+	count := 0
+	idx := make([]int, n)
+	for {
+		var have [numClasses]int
+		for _, i := range idx {
+			have[classOf(set[i])]++
+		}
+		ok := true
+		for k, min := range p.minimums() {
+			if have[k] < min {
+				ok = false
+			}
+		}
+		if ok {
+			count++
+		}
+
+		// Next combination
+		i := 0
+		for ; i < n; i++ {
+			idx[i]++
+			if idx[i] < len(set) {
+				break
+			}
+			idx[i] = 0
+		}
+		if i == n {
+			return count
+		}
+	}
+}
+
+func TestEntropy(t *testing.T) {
+	policies := []*Policy{
+		{Alphabet: "abAB12!? "},
+		{Alphabet: "abAB12!? ", MinUpper: 1},
+		{Alphabet: "abAB12!? ", MinDigit: 2, MinSymbol: 1},
+		{Alphabet: "abAB12!? ", MinLower: 1, MinUpper: 1, MinDigit: 1, MinSymbol: 1},
+	}
+	for _, p := range policies {
+		for n := 4; n <= 5; n++ {
+			expected := math.Log2(float64(bruteCount(p, n)))
+			if bits := p.Entropy(n); math.Abs(bits-expected) > 1e-9 {
+				t.Errorf("Entropy(%d) of %q = %f, expected %f", n, p, bits, expected)
+			}
+		}
+	}
+
+	// Unconstrained passwords: n * log2(94)
+	p := new(Policy)
+	if bits, expected := p.Entropy(64), 64*math.Log2(94); math.Abs(bits-expected) > 1e-9 {
+		t.Errorf("Entropy(64) = %f, expected %f", bits, expected)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	p := &Policy{MinLower: 2, MinUpper: 2, MinDigit: 3, MinSymbol: 1,
+		Exclude: "%&", NoLookalikes: true}
+	for i := 0; i < 200; i++ {
+		password, err := p.Generate(8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(password) != 8 {
+			t.Fatalf("Generate(8) returned %d characters", len(password))
+		}
+		var have [numClasses]int
+		for _, c := range password {
+			have[classOf(c)]++
+			if strings.IndexByte("%&"+Lookalikes, c) >= 0 {
+				t.Fatalf("password %q contains excluded character %q", password, c)
+			}
+		}
+		if have[Lower] < 2 || have[Upper] < 2 || have[Digit] < 3 || have[Symbol] < 1 {
+			t.Fatalf("password %q violates class minimums", password)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	invalid := map[string]*Policy{
+		"too long":         {MaxLength: 8},
+		"too many minimum": {MinDigit: 5, MinUpper: 5},
+		"missing class":    {Alphabet: "abc", MinDigit: 1},
+		"empty charset":    {Alphabet: "01", NoLookalikes: true},
+	}
+	for name, p := range invalid {
+		if err := p.Validate(9); err == nil {
+			t.Errorf("%s: Validate succeeded for %q", name, p)
+		}
+		if _, err := p.Generate(9); err == nil {
+			t.Errorf("%s: Generate succeeded for %q", name, p)
+		}
+	}
+}
+
+func TestParseSpec(t *testing.T) {
+	spec := `length=20,max-length=32,alphabet=ab\,c\\,exclude=%&,no-lookalikes,min-lower=1,min-digit=2`
+	expected := &Policy{Length: 20, MaxLength: 32, Alphabet: `ab,c\`, Exclude: "%&",
+		NoLookalikes: true, MinLower: 1, MinDigit: 2}
+
+	p, err := ParseSpec(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p, expected) {
+		t.Errorf("ParseSpec(%q) = %#v, expected %#v", spec, p, expected)
+	}
+	if p.String() != spec {
+		t.Errorf("String() = %q, expected %q", p.String(), spec)
+	}
+
+	for _, spec := range []string{"min-digit=x", "min-upper=-1", "foo=1"} {
+		if _, err := ParseSpec(spec); err == nil {
+			t.Errorf("ParseSpec(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestLoadProfiles(t *testing.T) {
+	r := strings.NewReader(`{"bank": {"max_length": 16, "min_digit": 1}}`)
+	profiles, err := LoadProfiles(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &Policy{MaxLength: 16, MinDigit: 1}
+	if !reflect.DeepEqual(profiles["bank"], expected) {
+		t.Errorf("profile = %#v, expected %#v", profiles["bank"], expected)
+	}
+}
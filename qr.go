@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/tvdburgt/passman/qr"
+	"github.com/tvdburgt/passman/store"
+	"github.com/tvdburgt/passman/term"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var cmdQr = &Command{
+	UsageLine: "qr [-f file] [-field field] [-timeout duration] entry_id",
+	Short:     "show entry data as a QR code in the terminal",
+	Long: `
+qr shows the password or another field of an entry as a QR code, so it can be
+scanned with the camera of a phone instead of retyped. The code is drawn in
+the alternate screen of the terminal, which is cleared after the timeout or
+when a key is pressed. If stdin is not a terminal, only the timeout closes the
+code. If stdout is not a terminal, the code is written as plain text.
+
+    -field field
+	The data to encode:
+
+	    password	the password of the entry (default)
+	    otp		the otpauth:// URI of the one-time password key, for
+			authenticator apps (see 'passman help otp')
+	    wifi	a Wi-Fi network configuration; the network name is taken
+			from the "ssid" metadata (or the name of the entry), the
+			security type from the "security" metadata ("WPA"
+			(default), "WEP" or "nopass") and hidden networks are
+			marked with the metadata hidden=true
+
+    -timeout duration
+	Clear the screen after the given duration (default 1m). A nonpositive
+	duration waits for a key press.
+	`,
+}
+
+var (
+	qrField   = "password"
+	qrTimeout = time.Minute
+)
+
+func init() {
+	cmdQr.Run = runQr
+	cmdQr.Flag.StringVar(&qrField, "field", qrField, "")
+	cmdQr.Flag.DurationVar(&qrTimeout, "timeout", qrTimeout, "")
+	addFileFlag(cmdQr)
+}
+
+func runQr(cmd *Command, args []string) error {
+	if len(args) < 1 {
+		return errUsage
+	}
+	id := args[0]
+
+	s, err := openStore()
+	if err != nil {
+		return err
+	}
+	e, err := getEntry(s, id)
+	if err != nil {
+		return err
+	}
+	data, err := qrData(e, qrField)
+	if err != nil {
+		return fmt.Errorf("passman qr: %s", err)
+	}
+	c, err := qr.Encode(data, qr.M)
+	if err != nil {
+		return fmt.Errorf("passman qr: %s", err)
+	}
+
+	var b bytes.Buffer
+	if err := c.Render(&b, 4); err != nil { // Quiet zone required by the standard
+		return fmt.Errorf("passman qr: %s", err)
+	}
+	if !term.IsOutputTerminal() {
+		fmt.Print(b.String())
+		return nil
+	}
+
+	// Without a terminal on stdin, the code can't be closed with a key, only
+	// by the timeout
+	readKey := term.IsTerminal()
+	if !readKey && qrTimeout <= 0 {
+		return errors.New("passman qr: stdin is not a terminal, so a positive -timeout is required")
+	}
+	if err := showQr(&b, fmt.Sprintf("%s of %q", qrField, id), readKey); err != nil {
+		return fmt.Errorf("passman qr: %s", err)
+	}
+	return nil
+}
+
+// showQr draws the rendered code in the alternate screen of the terminal until
+// the timeout expires or, with readKey, a key is pressed. The screen is always
+// restored before returning.
+func showQr(code io.Reader, title string, readKey bool) error {
+	// Draw dark modules in black on white, whatever the colors of the
+	// terminal are
+	fmt.Print("\x1b[?1049h\x1b[H\x1b[2J")
+	defer fmt.Print("\x1b[?1049l")
+	if readKey {
+		fmt.Printf("%s (press any key to close)\n\n", title)
+	} else {
+		fmt.Printf("%s (closes in %s)\n\n", title, qrTimeout)
+	}
+	lines := bufio.NewScanner(code)
+	for lines.Scan() {
+		fmt.Printf("\x1b[30;107m%s\x1b[0m\n", lines.Text())
+	}
+	if readKey {
+		_, err := term.WaitKey(qrTimeout)
+		return err
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+	select {
+	case <-time.After(qrTimeout):
+	case <-interrupt:
+	}
+	return nil
+}
+
+// qrData returns the data of the given field of e to encode in a QR code.
+func qrData(e *store.Entry, field string) ([]byte, error) {
+	switch field {
+	case "password":
+		return e.Password, nil
+	case "otp":
+		if e.OTP == nil {
+			return nil, errors.New("entry has no one-time password key")
+		}
+		return []byte(e.OTP.URI()), nil
+	case "wifi":
+		return wifiConfig(e)
+	}
+	return nil, fmt.Errorf("invalid field %q (expected password, otp or wifi)", field)
+}
+
+// wifiConfig returns the Wi-Fi network configuration of e in the format
+// understood by phones: WIFI:T:WPA;S:ssid;P:password;;
+func wifiConfig(e *store.Entry) ([]byte, error) {
+	ssid := e.Metadata["ssid"]
+	if ssid == "" {
+		ssid = e.Name
+	}
+	if ssid == "" {
+		return nil, errors.New("no network name (set the ssid metadata)")
+	}
+	security := e.Metadata["security"]
+	if security == "" {
+		security = "WPA"
+	}
+
+	escape := strings.NewReplacer(`\`, `\\`, `;`, `\;`, `,`, `\,`, `:`, `\:`, `"`, `\"`)
+	config := "WIFI:T:" + security + ";S:" + escape.Replace(ssid) + ";"
+	if security != "nopass" {
+		config += "P:" + escape.Replace(string(e.Password)) + ";"
+	}
+	if e.Metadata["hidden"] == "true" {
+		config += "H:true;"
+	}
+	return []byte(config + ";"), nil
+}
@@ -0,0 +1,496 @@
+// Package qr encodes data as QR codes (ISO/IEC 18004) and renders them as
+// text for terminals.
+//
+// Only the byte mode and versions 1 to 10 are supported, which is enough for
+// up to 271 bytes: passwords, otpauth URIs and Wi-Fi network configurations.
+package qr
+
+import (
+	"errors"
+	"io"
+)
+
+// Level is an error correction level.
+type Level int
+
+// Error correction levels, recovering about 7%, 15%, 25% and 30% of the
+// codewords.
+const (
+	L Level = iota
+	M
+	Q
+	H
+)
+
+// MaxVersion is the largest supported version.
+const MaxVersion = 10
+
+// ErrTooLong is returned when the data doesn't fit in a version 10 code.
+var ErrTooLong = errors.New("qr: data too long")
+
+// Error correction codewords per block and number of blocks, indexed by
+// level and version.
+var (
+	eccPerBlock = [4][MaxVersion + 1]int{
+		{0, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18},
+		{0, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26},
+		{0, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24},
+		{0, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28},
+	}
+	numBlocks = [4][MaxVersion + 1]int{
+		{0, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4},
+		{0, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5},
+		{0, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8},
+		{0, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8},
+	}
+)
+
+// Center coordinates of alignment patterns, indexed by version
+var alignment = [MaxVersion + 1][]int{
+	nil, nil,
+	{6, 18}, {6, 22}, {6, 26}, {6, 30}, {6, 34},
+	{6, 22, 38}, {6, 24, 42}, {6, 26, 46}, {6, 28, 50},
+}
+
+// Format bits of the levels
+var levelBits = [4]int{1, 0, 3, 2}
+
+// Code is an encoded QR code.
+type Code struct {
+	Version int
+	Level   Level
+	Size    int      // Number of modules per side
+	Mask    int      // Mask pattern (0-7)
+	modules [][]bool // Dark modules, indexed by row and column
+
+	function [][]bool // Modules of function patterns
+}
+
+// Encode encodes data in the smallest code with at least the given error
+// correction level.
+func Encode(data []byte, level Level) (*Code, error) {
+	for v := 1; v <= MaxVersion; v++ {
+		if len(data) <= capacity(v, level) {
+			return encode(data, v, level), nil
+		}
+	}
+	return nil, ErrTooLong
+}
+
+// Black reports whether the module at column x and row y is dark.
+func (c *Code) Black(x, y int) bool {
+	return c.modules[y][x]
+}
+
+// capacity returns the number of bytes that fit in a code.
+func capacity(version int, level Level) int {
+	bits := dataCodewords(version, level)*8 - 4 - countBits(version)
+	return bits / 8
+}
+
+// countBits returns the length of the character count of the byte mode.
+func countBits(version int) int {
+	if version < 10 {
+		return 8
+	}
+	return 16
+}
+
+// rawCodewords returns the number of codewords of a version, including
+// error correction codewords.
+func rawCodewords(version int) int {
+	n := (16*version+128)*version + 64 // Modules outside finder, timing and format areas
+	if version >= 2 {
+		align := version/7 + 2
+		n -= (25*align-10)*align - 55
+		if version >= 7 {
+			n -= 36 // Version information
+		}
+	}
+	return n / 8
+}
+
+func dataCodewords(version int, level Level) int {
+	return rawCodewords(version) - eccPerBlock[level][version]*numBlocks[level][version]
+}
+
+func encode(data []byte, version int, level Level) *Code {
+	// Segment: byte mode indicator, character count and data
+	var bits bitBuffer
+	bits.append(4, 4)
+	bits.append(len(data), countBits(version))
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+	capacity := dataCodewords(version, level) * 8
+	terminator := capacity - len(bits)
+	if terminator > 4 {
+		terminator = 4
+	}
+	bits.append(0, terminator)
+	bits.append(0, (8-len(bits)%8)%8)
+	for pad := 0xec; len(bits) < capacity; pad ^= 0xec ^ 0x11 {
+		bits.append(pad, 8)
+	}
+
+	c := newCode(version, level)
+	c.drawFunctionPatterns()
+	c.drawCodewords(interleave(bits.bytes(), version, level))
+
+	// Pick the mask with the lowest penalty
+	best, min := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormat(mask)
+		if p := c.penalty(); min < 0 || p < min {
+			best, min = mask, p
+		}
+		c.applyMask(mask) // Undo
+	}
+	c.Mask = best
+	c.applyMask(best)
+	c.drawFormat(best)
+	c.function = nil
+	return c
+}
+
+func newCode(version int, level Level) *Code {
+	size := 17 + 4*version
+	c := &Code{Version: version, Level: level, Size: size}
+	c.modules = make([][]bool, size)
+	c.function = make([][]bool, size)
+	for i := range c.modules {
+		c.modules[i] = make([]bool, size)
+		c.function[i] = make([]bool, size)
+	}
+	return c
+}
+
+func (c *Code) set(x, y int, dark bool) {
+	c.modules[y][x] = dark
+	c.function[y][x] = true
+}
+
+func (c *Code) drawFunctionPatterns() {
+	// Timing patterns
+	for i := 0; i < c.Size; i++ {
+		c.set(6, i, i%2 == 0)
+		c.set(i, 6, i%2 == 0)
+	}
+
+	// Finder patterns with separators
+	c.drawFinder(3, 3)
+	c.drawFinder(c.Size-4, 3)
+	c.drawFinder(3, c.Size-4)
+
+	// Alignment patterns, except those overlapping finder patterns
+	pos := alignment[c.Version]
+	last := len(pos) - 1
+	for i, x := range pos {
+		for j, y := range pos {
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					c.set(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	// Reserve the format areas, drawn after masking
+	c.drawFormat(0)
+
+	// Version information
+	if c.Version >= 7 {
+		rem := c.Version
+		for i := 0; i < 12; i++ {
+			rem = rem<<1 ^ (rem>>11)*0x1f25
+		}
+		bits := c.Version<<12 | rem
+		for i := 0; i < 18; i++ {
+			dark := bits>>uint(i)&1 != 0
+			a, b := c.Size-11+i%3, i/3
+			c.set(a, b, dark)
+			c.set(b, a, dark)
+		}
+	}
+}
+
+func (c *Code) drawFinder(cx, cy int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			x, y := cx+dx, cy+dy
+			if x < 0 || x >= c.Size || y < 0 || y >= c.Size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			c.set(x, y, dist != 2 && dist != 4)
+		}
+	}
+}
+
+// formatBits returns the 15 bits of format information.
+func formatBits(level Level, mask int) int {
+	data := levelBits[level]<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+func (c *Code) drawFormat(mask int) {
+	bits := formatBits(c.Level, mask)
+	bit := func(i int) bool { return bits>>uint(i)&1 != 0 }
+
+	// Copy around the top left finder
+	for i := 0; i <= 5; i++ {
+		c.set(8, i, bit(i))
+	}
+	c.set(8, 7, bit(6))
+	c.set(8, 8, bit(7))
+	c.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.set(14-i, 8, bit(i))
+	}
+
+	// Copy split between the other finders
+	for i := 0; i < 8; i++ {
+		c.set(c.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.set(8, c.Size-15+i, bit(i))
+	}
+	c.set(8, c.Size-8, true) // Dark module
+}
+
+// interleave splits data into blocks, appends the error correction
+// codewords of each block and interleaves the blocks.
+func interleave(data []byte, version int, level Level) []byte {
+	n := numBlocks[level][version]
+	ecc := eccPerBlock[level][version]
+	raw := rawCodewords(version)
+	short := n - raw%n
+	shortLen := raw / n
+	gen := generator(ecc)
+
+	// Short blocks get a placeholder after their data, so all blocks have
+	// the same length
+	var blocks [][]byte
+	for i, k := 0, 0; i < n; i++ {
+		dataLen := shortLen - ecc
+		if i >= short {
+			dataLen++
+		}
+		block := append([]byte(nil), data[k:k+dataLen]...)
+		k += dataLen
+		check := remainder(block, gen)
+		if i < short {
+			block = append(block, 0)
+		}
+		blocks = append(blocks, append(block, check...))
+	}
+
+	var result []byte
+	for i := range blocks[0] {
+		for j, block := range blocks {
+			if i != shortLen-ecc || j >= short {
+				result = append(result, block[i])
+			}
+		}
+	}
+	return result
+}
+
+// drawCodewords places data in the zigzag pattern of the code.
+func (c *Code) drawCodewords(data []byte) {
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5 // Skip the vertical timing pattern
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert // Upward
+				}
+				if !c.function[y][x] && i < len(data)*8 {
+					c.modules[y][x] = data[i/8]>>uint(7-i%8)&1 != 0
+					i++
+				}
+			}
+		}
+	}
+}
+
+// masked reports whether mask pattern m inverts the module at (x, y).
+func masked(m, x, y int) bool {
+	switch m {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	}
+	return ((x+y)%2+x*y%3)%2 == 0
+}
+
+func (c *Code) applyMask(m int) {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !c.function[y][x] && masked(m, x, y) {
+				c.modules[y][x] = !c.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores the modules according to the rules of the standard, with
+// lower scores for codes that are easier to read.
+func (c *Code) penalty() int {
+	p := 0
+	dark := 0
+	for i := 0; i < c.Size; i++ {
+		row := make([]bool, c.Size)
+		col := make([]bool, c.Size)
+		for j := 0; j < c.Size; j++ {
+			row[j], col[j] = c.modules[i][j], c.modules[j][i]
+			if row[j] {
+				dark++
+			}
+		}
+		p += linePenalty(row) + linePenalty(col)
+	}
+
+	// 2x2 blocks of the same color
+	for y := 0; y < c.Size-1; y++ {
+		for x := 0; x < c.Size-1; x++ {
+			m := c.modules[y][x]
+			if m == c.modules[y][x+1] && m == c.modules[y+1][x] && m == c.modules[y+1][x+1] {
+				p += 3
+			}
+		}
+	}
+
+	// Balance of dark and light modules
+	total := c.Size * c.Size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	return p + k*10
+}
+
+// Finder-like pattern, preceded or followed by four light modules
+var finderLike = []bool{true, false, true, true, true, false, true}
+
+// linePenalty scores runs of the same color and finder-like patterns in a
+// row or column.
+func linePenalty(line []bool) int {
+	p := 0
+	run := 1
+	for i := 1; i <= len(line); i++ {
+		if i < len(line) && line[i] == line[i-1] {
+			run++
+			continue
+		}
+		if run >= 5 {
+			p += 3 + run - 5
+		}
+		run = 1
+	}
+
+	light := func(i int) bool { return i < 0 || i >= len(line) || !line[i] }
+	for i := 0; i+len(finderLike) <= len(line); i++ {
+		match := true
+		for j, m := range finderLike {
+			if line[i+j] != m {
+				match = false
+				break
+			}
+		}
+		if !match {
+			continue
+		}
+		before, after := true, true
+		for j := 1; j <= 4; j++ {
+			before = before && light(i-j)
+			after = after && light(i+len(finderLike)-1+j)
+		}
+		if before || after {
+			p += 40
+		}
+	}
+	return p
+}
+
+// Render writes the code to w with Unicode half block characters, two rows of
+// modules per line, surrounded by a light border of the given width. Dark
+// modules are drawn with the foreground color.
+func (c *Code) Render(w io.Writer, border int) error {
+	dark := func(x, y int) bool {
+		x, y = x-border, y-border
+		return x >= 0 && x < c.Size && y >= 0 && y < c.Size && c.modules[y][x]
+	}
+	n := c.Size + 2*border
+	var b []byte
+	for y := 0; y < n; y += 2 {
+		for x := 0; x < n; x++ {
+			switch top, bottom := dark(x, y), dark(x, y+1); {
+			case top && bottom:
+				b = append(b, "█"...)
+			case top:
+				b = append(b, "▀"...)
+			case bottom:
+				b = append(b, "▄"...)
+			default:
+				b = append(b, ' ')
+			}
+		}
+		b = append(b, '\n')
+	}
+	_, err := w.Write(b)
+	return err
+}
+
+type bitBuffer []bool
+
+func (b *bitBuffer) append(v, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*b = append(*b, v>>uint(i)&1 != 0)
+	}
+}
+
+func (b bitBuffer) bytes() []byte {
+	result := make([]byte, len(b)/8)
+	for i, bit := range b {
+		if bit {
+			result[i/8] |= 1 << uint(7-i%8)
+		}
+	}
+	return result
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,282 @@
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// parseRendered converts the output of Render back to a matrix of modules.
+func parseRendered(s string, border int) ([][]bool, error) {
+	var rows [][]bool
+	for _, line := range strings.Split(strings.TrimSuffix(s, "\n"), "\n") {
+		var top, bottom []bool
+		for _, r := range line {
+			switch r {
+			case '█':
+				top, bottom = append(top, true), append(bottom, true)
+			case '▀':
+				top, bottom = append(top, true), append(bottom, false)
+			case '▄':
+				top, bottom = append(top, false), append(bottom, true)
+			case ' ':
+				top, bottom = append(top, false), append(bottom, false)
+			default:
+				return nil, fmt.Errorf("unexpected character %q", r)
+			}
+		}
+		rows = append(rows, top, bottom)
+	}
+
+	// Strip the border; the last row is padding if the size is odd
+	n := len(rows[0]) - 2*border
+	if len(rows) < n+2*border {
+		return nil, errors.New("too few rows")
+	}
+	modules := make([][]bool, n)
+	for y := range modules {
+		modules[y] = rows[y+border][border : border+n]
+	}
+	return modules, nil
+}
+
+// decode reads the data from a matrix of modules.
+func decode(modules [][]bool) ([]byte, error) {
+	size := len(modules)
+	version := (size - 17) / 4
+	if version < 1 || version > MaxVersion || size != 17+4*version {
+		return nil, fmt.Errorf("invalid size %d", size)
+	}
+
+	// Format information around the top left finder
+	format := 0
+	bit := func(x, y, i int) {
+		if modules[y][x] {
+			format |= 1 << uint(i)
+		}
+	}
+	for i := 0; i <= 5; i++ {
+		bit(8, i, i)
+	}
+	bit(8, 7, 6)
+	bit(8, 8, 7)
+	bit(7, 8, 8)
+	for i := 9; i < 15; i++ {
+		bit(14-i, 8, i)
+	}
+	level, mask := Level(-1), -1
+	for l := L; l <= H; l++ {
+		for m := 0; m < 8; m++ {
+			if formatBits(l, m) == format {
+				level, mask = l, m
+			}
+		}
+	}
+	if level < 0 {
+		return nil, fmt.Errorf("invalid format bits %015b", format)
+	}
+
+	// Read the codewords in zigzag order, skipping function patterns
+	ref := newCode(version, level)
+	ref.drawFunctionPatterns()
+	var data []byte
+	n := 0
+	for right := size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = size - 1 - vert
+				}
+				if ref.function[y][x] {
+					continue
+				}
+				if n%8 == 0 {
+					data = append(data, 0)
+				}
+				if modules[y][x] != masked(mask, x, y) {
+					data[n/8] |= 1 << uint(7-n%8)
+				}
+				n++
+			}
+		}
+	}
+	data = data[:rawCodewords(version)]
+
+	// Deinterleave the blocks and check their syndromes
+	blocks := numBlocks[level][version]
+	ecc := eccPerBlock[level][version]
+	short := blocks - len(data)%blocks
+	shortLen := len(data) / blocks
+	dataBlocks := make([][]byte, blocks)
+	eccBlocks := make([][]byte, blocks)
+	k := 0
+	for i := 0; i <= shortLen; i++ {
+		for j := 0; j < blocks; j++ {
+			dataLen := shortLen - ecc
+			if j >= short {
+				dataLen++
+			}
+			if i < dataLen {
+				dataBlocks[j] = append(dataBlocks[j], data[k])
+				k++
+			}
+		}
+	}
+	for i := 0; i < ecc; i++ {
+		for j := 0; j < blocks; j++ {
+			eccBlocks[j] = append(eccBlocks[j], data[k])
+			k++
+		}
+	}
+	var stream []byte
+	for j := range dataBlocks {
+		codewords := append(append([]byte(nil), dataBlocks[j]...), eccBlocks[j]...)
+		x := byte(1)
+		for i := 0; i < ecc; i++ {
+			// Evaluate the block polynomial at 2^i
+			var sum byte
+			for _, c := range codewords {
+				sum = gfMul(sum, x) ^ c
+			}
+			if sum != 0 {
+				return nil, fmt.Errorf("block %d: nonzero syndrome %d", j, i)
+			}
+			x = gfMul(x, 2)
+		}
+		stream = append(stream, dataBlocks[j]...)
+	}
+
+	// Byte mode segment
+	pos := 0
+	read := func(n int) int {
+		v := 0
+		for i := 0; i < n; i++ {
+			v = v<<1 | int(stream[pos/8]>>uint(7-pos%8)&1)
+			pos++
+		}
+		return v
+	}
+	if mode := read(4); mode != 4 {
+		return nil, fmt.Errorf("unexpected mode %04b", mode)
+	}
+	count := read(countBits(version))
+	if pos+count*8 > len(stream)*8 {
+		return nil, errors.New("character count exceeds data")
+	}
+	result := make([]byte, count)
+	for i := range result {
+		result[i] = byte(read(8))
+	}
+	return result, nil
+}
+
+func TestEncode(t *testing.T) {
+	var inputs [][]byte
+	for _, s := range []string{
+		"",
+		"HELLO WORLD",
+		"correct-horse-battery-staple",
+		"otpauth://totp/ACME%20Co:john.doe@email.com?secret=HXDMVJECJJWSRB3HWIZR4IFUGFTMXBOZ&issuer=ACME%20Co",
+		`WIFI:T:WPA;S:home\;net;P:p@ss\:word;;`,
+	} {
+		inputs = append(inputs, []byte(s))
+	}
+	for _, n := range []int{17, 64, 100, 200, 271} {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = byte(i * 7)
+		}
+		inputs = append(inputs, b)
+	}
+
+	for _, input := range inputs {
+		for level := L; level <= H; level++ {
+			c, err := Encode(input, level)
+			if err == ErrTooLong {
+				if len(input) <= capacity(MaxVersion, level) {
+					t.Errorf("%d bytes at level %d: unexpected ErrTooLong", len(input), level)
+				}
+				continue
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			var b bytes.Buffer
+			if err := c.Render(&b, 2); err != nil {
+				t.Fatal(err)
+			}
+			modules, err := parseRendered(b.String(), 2)
+			if err != nil {
+				t.Fatalf("%d bytes at level %d: %s", len(input), level, err)
+			}
+			output, err := decode(modules)
+			if err != nil {
+				t.Fatalf("%d bytes at level %d (version %d, mask %d): %s",
+					len(input), level, c.Version, c.Mask, err)
+			}
+			if !bytes.Equal(output, input) {
+				t.Errorf("decoded %q, expected %q", output, input)
+			}
+		}
+	}
+}
+
+// Maximum number of bytes per version for level L and H
+func TestCapacity(t *testing.T) {
+	expected := [][2]int{
+		{17, 7}, {32, 14}, {53, 24}, {78, 34}, {106, 44},
+		{134, 58}, {154, 64}, {192, 84}, {230, 98}, {271, 119},
+	}
+	for v, e := range expected {
+		if l, h := capacity(v+1, L), capacity(v+1, H); l != e[0] || h != e[1] {
+			t.Errorf("version %d: capacity %d (L), %d (H), expected %d, %d", v+1, l, h, e[0], e[1])
+		}
+	}
+	if _, err := Encode(make([]byte, 272), L); err != ErrTooLong {
+		t.Errorf("encoded 272 bytes, expected ErrTooLong")
+	}
+}
+
+// A version 1 code has its finder patterns in the corners
+func TestFinderPatterns(t *testing.T) {
+	c, err := Encode([]byte("passman"), M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Version != 1 || c.Size != 21 {
+		t.Fatalf("version %d, size %d, expected version 1", c.Version, c.Size)
+	}
+	for _, corner := range [][2]int{{0, 0}, {14, 0}, {0, 14}} {
+		for dy := 0; dy < 7; dy++ {
+			for dx := 0; dx < 7; dx++ {
+				dist := max(abs(dx-3), abs(dy-3))
+				if c.Black(corner[0]+dx, corner[1]+dy) != (dist != 2) {
+					t.Fatalf("finder pattern at %v: unexpected module (%d, %d)", corner, dx, dy)
+				}
+			}
+		}
+	}
+}
+
+// Format information from the table in annex C of the standard
+func TestFormatBits(t *testing.T) {
+	tests := []struct {
+		level    Level
+		mask     int
+		expected int
+	}{
+		{L, 0, 0x77c4}, // 111011111000100
+		{M, 0, 0x5412}, // 101010000010010
+		{H, 7, 0x083b}, // 000100000111011
+	}
+	for _, test := range tests {
+		if bits := formatBits(test.level, test.mask); bits != test.expected {
+			t.Errorf("level %d, mask %d: %015b, expected %015b", test.level, test.mask, bits, test.expected)
+		}
+	}
+}
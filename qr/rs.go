@@ -0,0 +1,47 @@
+package qr
+
+// Reed-Solomon error correction over GF(2^8) with the primitive polynomial
+// x^8 + x^4 + x^3 + x^2 + 1.
+
+// gfMul multiplies in GF(2^8).
+func gfMul(x, y byte) byte {
+	var z byte
+	for i := 7; i >= 0; i-- {
+		z = z<<1 ^ (z>>7)*0x1d
+		z ^= (y >> uint(i) & 1) * x
+	}
+	return z
+}
+
+// generator returns the coefficients of the generator polynomial of the given
+// degree, from the highest to the lowest power, excluding the leading 1.
+func generator(degree int) []byte {
+	g := make([]byte, degree)
+	g[degree-1] = 1 // Start with the polynomial 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		// Multiply by (x - 2^i)
+		for j := range g {
+			g[j] = gfMul(g[j], root)
+			if j+1 < len(g) {
+				g[j] ^= g[j+1]
+			}
+		}
+		root = gfMul(root, 2)
+	}
+	return g
+}
+
+// remainder returns the error correction codewords of data.
+func remainder(data, gen []byte) []byte {
+	r := make([]byte, len(gen))
+	for _, b := range data {
+		factor := b ^ r[0]
+		copy(r, r[1:])
+		r[len(r)-1] = 0
+		for i, g := range gen {
+			r[i] ^= gfMul(g, factor)
+		}
+	}
+	return r
+}
@@ -2,8 +2,11 @@ package main
 
 import (
 	"errors"
+	"flag"
 	"fmt"
 	"github.com/tvdburgt/passman/crypto"
+	"github.com/tvdburgt/passman/otp"
+	"github.com/tvdburgt/passman/pwgen"
 	"github.com/tvdburgt/passman/store"
 	"strings"
 )
@@ -16,23 +19,84 @@ long description
 
 	-n -name <name>		set name
 	-p -password		prompt for password
+	-gen <method[:length]>	set a generated password without prompting
+				(e.g., ascii:32, hex:40 or diceware:6)
+	-policy <policy>	generate the password with a policy profile or
+				rules (see 'passman help gen'); the policy is
+				kept with the entry for later regeneration, and
+				"none" removes it
+	-expires <date|interval>
+				expire the password at a date (2006-01-02) or
+				an interval after each change (e.g., 90d);
+				"none" removes the expiry
+	-otp <uri|secret>	set the one-time password key from an otpauth://
+				URI or a base32 TOTP secret; "none" removes it
+	-wordlist, -separator, -capitalize, -digits, -pattern
+				generator options (see 'passman help gen')
 	-id <identifier>	change id of existing entry
 	`,
 }
 
-var (
-	setName     string
-	setId       string
-	setPassword = false
-	setMeta     = make(metadata)
+// setOptions holds the modifications 'passman set' applies to an entry.
+type setOptions struct {
+	name     string
+	id       string
+	password bool
+	gen      string
+	policy   string
+	expires  string
+	otp      string
+	meta     metadata
+
+	// secret, if non-nil, is used as the new password of the entry.
+	secret []byte
+
+	// readPassword obtains a new password if secret is nil and a password
+	// is required. A nil readPassword makes this an error instead.
+	readPassword func(policy *pwgen.Policy) ([]byte, error)
+}
+
+// expiryNone and otpNone clear the expiry and one-time password key of an
+// entry.
+const (
+	expiryNone = "none"
+	otpNone    = "none"
 )
 
+var setOpts = newSetOptions()
+
+func newSetOptions() *setOptions {
+	return &setOptions{
+		meta:         make(metadata),
+		readPassword: readPassword,
+	}
+}
+
 func init() {
 	cmdSet.Run = runSet
-	cmdSet.Flag.StringVar(&setName, "name", "", "")
-	cmdSet.Flag.BoolVar(&setPassword, "password", setPassword, "")
-	cmdSet.Flag.StringVar(&setId, "id", "", "")
-	cmdSet.Flag.Var(setMeta, "meta", "")
+	addSetFlags(&cmdSet.Flag, setOpts)
+	addGeneratorFlags(&cmdSet.Flag)
+	addFileFlag(cmdSet)
+}
+
+// addSetFlags defines the flags of 'passman set' on fs, storing their values
+// in o.
+func addSetFlags(fs *flag.FlagSet, o *setOptions) {
+	fs.StringVar(&o.name, "name", "", "")
+	fs.BoolVar(&o.password, "password", o.password, "")
+	fs.StringVar(&o.gen, "gen", "", "")
+	fs.StringVar(&o.policy, "policy", "", "")
+	fs.StringVar(&o.expires, "expires", "", "")
+	fs.StringVar(&o.otp, "otp", "", "")
+	fs.StringVar(&o.id, "id", "", "")
+	fs.Var(o.meta, "meta", "")
+}
+
+// empty reports whether o does not contain any modifications.
+func (o *setOptions) empty() bool {
+	return o.name == "" && o.id == "" && !o.password && o.gen == "" &&
+		o.policy == "" && o.expires == "" && o.otp == "" &&
+		o.secret == nil && len(o.meta) == 0
 }
 
 type metadata store.Metadata
@@ -54,43 +118,129 @@ func (m metadata) Set(data string) (err error) {
 	return
 }
 
-func runSet(cmd *Command, args []string) {
+func runSet(cmd *Command, args []string) error {
 	if len(args) < 1 {
-		cmd.Usage()
+		return errUsage
 	}
 	id := args[0]
 
-	s, passphrase := openRwStore()
+	s, passphrase, err := openRwStore()
+	if err != nil {
+		return err
+	}
 	defer crypto.Clear(passphrase)
 
-	// Fetch entry
+	if _, ok := s.Entries[id]; ok {
+		fmt.Printf("Found entry %q\n", id)
+	} else {
+		fmt.Printf("Entry %q doesn't exist, creating...\n", id)
+	}
+
+	e, err := setEntry(s, id, setOpts)
+	if err == errAborted {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("passman set: %s", err)
+	}
+
+	if err := saveStore(s, passphrase); err != nil {
+		return err
+	}
+
+	fmt.Print(e)
+	return nil
+}
+
+// setEntry applies the modifications in o to the entry with the given id.
+// The entry is created if it doesn't exist yet.
+func setEntry(s *store.Store, id string, o *setOptions) (*store.Entry, error) {
 	e, ok := s.Entries[id]
+	password := o.password || o.gen != "" || o.secret != nil ||
+		(o.policy != "" && o.policy != policyNone)
 	if !ok {
-		fmt.Printf("Entry %q doesn't exist, creating...\n", id)
 		e = store.NewEntry()
-		s.Entries[id] = e
-		setPassword = true // Always prompt for password for new entries
-	} else {
-		fmt.Printf("Found entry %q\n", id)
-		if cmd.Flag.NFlag() == 0 {
-			fatalf("passman set: no arguments to set for %q", id)
+		password = true // Always prompt for password for new entries
+	} else if o.empty() {
+		return nil, fmt.Errorf("no arguments to set for %q", id)
+	}
+
+	// A new policy replaces the policy of the entry
+	policy := (*pwgen.Policy)(e.Policy)
+	if o.policy != "" {
+		var err error
+		if policy, err = resolvePolicy(o.policy); err != nil {
+			return nil, err
+		}
+	}
+
+	var expires *store.Expiry
+	if o.expires != "" && o.expires != expiryNone {
+		var err error
+		if expires, err = store.ParseExpiry(o.expires); err != nil {
+			return nil, err
+		}
+	}
+
+	var otpKey *otp.Key
+	if o.otp != "" && o.otp != otpNone {
+		var err error
+		if otpKey, err = otp.Parse(o.otp); err != nil {
+			return nil, err
+		}
+	}
+
+	// Obtain password first, so an aborted prompt leaves the store as is
+	p := o.secret
+	if (o.gen != "" || o.policy != "" && o.policy != policyNone) && password && p == nil {
+		var err error
+		if p, err = o.generate(policy); err != nil {
+			return nil, err
 		}
 	}
+	if password && p == nil {
+		if o.readPassword == nil {
+			return nil, fmt.Errorf("no password given for %q", id)
+		}
+		var err error
+		if p, err = o.readPassword(policy); err != nil {
+			return nil, err
+		}
+	}
+
+	if o.id != "" {
+		if _, ok := s.Entries[o.id]; ok {
+			return nil, fmt.Errorf("entry with id %q already exists", o.id)
+		}
+	}
+
+	if !ok {
+		s.Entries[id] = e
+	}
+
+	if o.name != "" {
+		e.Name = o.name
+	}
+
+	if o.policy != "" {
+		e.Policy = (*store.Policy)(policy)
+	}
+
+	if o.expires != "" {
+		e.Expires = expires
+	}
 
-	if setName != "" {
-		e.Name = setName
+	if o.otp != "" {
+		e.OTP = otpKey
 	}
 
-	if setId != "" {
-		if _, ok := s.Entries[setId]; ok {
-			fatalf("passman set: entry with id %q already exists", setId)
+	if o.id != "" {
+		if err := moveEntry(s, id, o.id); err != nil {
+			return nil, err
 		}
-		s.Entries[setId] = e
-		delete(s.Entries, id)
 	}
 
 	// Merge metadata modifications with entry
-	for key, val := range setMeta {
+	for key, val := range o.meta {
 		if len(val) == 0 {
 			delete(e.Metadata, key)
 		} else {
@@ -98,18 +248,33 @@ func runSet(cmd *Command, args []string) {
 		}
 	}
 
-	if setPassword {
-		if password, err := readPassword(); err != nil {
-			fatalf("passman set: %s", err)
-		} else {
-			// TODO: clear password, use []byte
-			e.Password = password
-		}
+	if password {
+		// TODO: clear password, use []byte
+		e.Password = p
 		// Update modification time
 		e.Touch()
 	}
 
-	writeStore(s, passphrase)
+	return e, nil
+}
 
-	fmt.Print(e)
+// generate returns a password generated according to o.gen, which defaults
+// to ascii. The policy only applies to ascii passwords; it is an error to
+// request another method together with -policy.
+func (o *setOptions) generate(policy *pwgen.Policy) ([]byte, error) {
+	method, n := methodAscii, 0
+	if o.gen != "" {
+		var err error
+		if method, n, err = parseGenSpec(o.gen); err != nil {
+			return nil, err
+		}
+	}
+	if method != methodAscii && o.policy == "" {
+		policy = nil // Ignore the policy of the entry
+	}
+	if n == 0 {
+		n = defaultLength(method, policy)
+	}
+	p, _, err := genPassword(method, n, policy)
+	return p, err
 }
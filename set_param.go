@@ -16,7 +16,7 @@ exact match. To search or display multiple entries, see passman list.
 	`,
 }
 
-func runSetParam(cmd *Command, args []string) {
+func runSetParam(cmd *Command, args []string) error {
 	// if len(args) < 2 {
 	// 	// TODO: show usage
 	// 	fatalf("passman set-param: missing identifier")
@@ -60,4 +60,5 @@ func runSetParam(cmd *Command, args []string) {
 	// writeStore(s, passphrase)
 
 	// fmt.Printf("Changed '%s' to '%s'\n", name, value)
+	return nil
 }
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"github.com/tvdburgt/passman/pwgen"
+	"github.com/tvdburgt/passman/store"
+	"testing"
+)
+
+// A new entry with -policy none prompts for its password instead of
+// generating one.
+func TestSetEntryPolicyNone(t *testing.T) {
+	s := store.NewStore()
+	o := newSetOptions()
+	o.policy = policyNone
+	prompted := false
+	o.readPassword = func(policy *pwgen.Policy) ([]byte, error) {
+		prompted = true
+		return []byte("typed"), nil
+	}
+	e, err := setEntry(s, "new", o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !prompted || string(e.Password) != "typed" || e.Policy != nil {
+		t.Errorf("prompted %t, entry %+v", prompted, e)
+	}
+}
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/tvdburgt/passman/crypto"
+	"github.com/tvdburgt/passman/store"
+	"github.com/tvdburgt/passman/term"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+var cmdShell = &Command{
+	UsageLine: "shell [-f file] [-idle duration]",
+	Short:     "run commands in an interactive shell",
+	Long: `
+The shell command unlocks the store once and then reads commands from the
+terminal until 'exit' is entered (or Ctrl-D is pressed). This saves typing the
+passphrase for every single command when making many changes. Line editing,
+history and tab completion of commands and entry ids are available.
+
+The following commands are supported; their flags are the same as those of the
+corresponding passman subcommands:
+
+    get id
+    set [-name name] [-password] [-id new_id] [-meta key=value] id
+    clip [-fields field_list] [-timeout duration] [-persist] id
+    otp id
+    list [-expired] [-expiring interval] [pattern]
+    mv old_id new_id
+    delete id
+    save
+    help
+    exit
+
+Modifications are kept in memory until they are written to the store with
+'save' or on exit.
+
+    -idle duration
+	Locks the store after it has been idle for the given duration (default
+	5m). Pending modifications are saved before locking. A nonpositive
+	duration disables locking.
+	`,
+}
+
+var shellIdle = 5 * time.Minute
+
+func init() {
+	cmdShell.Run = runShell
+	cmdShell.Flag.DurationVar(&shellIdle, "idle", shellIdle, "")
+	addFileFlag(cmdShell)
+}
+
+// shell is an interactive session on an unlocked store.
+type shell struct {
+	store      *store.Store
+	passphrase []byte
+	modified   bool
+	lines      *term.LineReader
+}
+
+// A shellCommand is a command that can be run in a shell session. The first
+// word of its usage line is taken to be its name.
+type shellCommand struct {
+	usage string
+	run   func(sh *shell, args []string) error
+}
+
+func (c *shellCommand) name() string {
+	return strings.Fields(c.usage)[0]
+}
+
+var shellCommands []*shellCommand
+
+func init() {
+	shellCommands = []*shellCommand{
+		{"get id", (*shell).get},
+		{"set [options] id", (*shell).set},
+		{"clip [options] id", (*shell).clip},
+		{"otp id", (*shell).otp},
+		{"list [-expired] [-expiring interval] [pattern]", (*shell).list},
+		{"mv old_id new_id", (*shell).move},
+		{"delete id", (*shell).delete},
+		{"save", (*shell).save},
+		{"help", (*shell).help},
+		{"exit", nil},
+	}
+}
+
+// errExit is returned when the shell session ends.
+var errExit = errors.New("exit")
+
+func runShell(cmd *Command, args []string) error {
+	lines, err := term.NewLineReader("passman> ")
+	if err != nil {
+		return fmt.Errorf("passman shell: %s", err)
+	}
+
+	sh := &shell{lines: lines}
+	if sh.store, sh.passphrase, err = openRwStore(); err != nil {
+		return err
+	}
+	defer crypto.Clear(sh.passphrase)
+	lines.Complete = sh.complete
+
+	fmt.Printf("Unlocked %q. Type 'help' for a list of commands.\n", storeFile)
+	if err := sh.loop(); err != nil {
+		return fmt.Errorf("passman shell: %s", err)
+	}
+	return nil
+}
+
+type readResult struct {
+	line string
+	err  error
+}
+
+// loop reads and runs commands until the session ends.
+func (sh *shell) loop() error {
+	for {
+		result := make(chan readResult, 1)
+		go func() {
+			line, err := sh.lines.ReadLine()
+			result <- readResult{line, err}
+		}()
+
+		var idle <-chan time.Time
+		if shellIdle > 0 {
+			idle = time.After(shellIdle)
+		}
+
+		select {
+		case r := <-result:
+			if r.err == io.EOF {
+				fmt.Println()
+				return sh.exit()
+			} else if r.err != nil {
+				return r.err
+			}
+			if err := sh.run(r.line); err == errExit {
+				return sh.exit()
+			} else if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		case <-idle:
+			sh.lines.Restore()
+			fmt.Printf("\nLocking store after %s of inactivity.\n", shellIdle)
+			return sh.exit()
+		}
+	}
+}
+
+// run parses and runs a single command line.
+func (sh *shell) run(line string) error {
+	args, err := splitLine(line)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return nil
+	}
+	for _, c := range shellCommands {
+		if c.name() != args[0] {
+			continue
+		}
+		if c.run == nil {
+			return errExit
+		}
+		if err := c.run(sh, args[1:]); err != nil {
+			return fmt.Errorf("%s: %s", args[0], err)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown command %q (type 'help' for a list of commands)", args[0])
+}
+
+// exit saves pending modifications before the session ends.
+func (sh *shell) exit() error {
+	if sh.modified {
+		return sh.save(nil)
+	}
+	return nil
+}
+
+func (sh *shell) get(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: get id")
+	}
+	e, err := getEntry(sh.store, args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Print(e)
+	warnExpired(args[0], e)
+	return nil
+}
+
+func (sh *shell) set(args []string) error {
+	o := newSetOptions()
+	fs := newShellFlagSet("set")
+	addSetFlags(fs, o)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: set [options] id")
+	}
+	e, err := setEntry(sh.store, fs.Arg(0), o)
+	if err == errAborted {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	sh.modified = true
+	fmt.Print(e)
+	return nil
+}
+
+func (sh *shell) clip(args []string) error {
+	o := newClipOptions()
+	fs := newShellFlagSet("clip")
+	addClipFlags(fs, o)
+	o.save = func() error {
+		sh.modified = true
+		return nil
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: clip [options] id")
+	}
+	e, err := getEntry(sh.store, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if err = clipEntry(e, o); err == errClipTimeout {
+		return nil
+	}
+	return err
+}
+
+func (sh *shell) otp(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: otp id")
+	}
+	e, err := getEntry(sh.store, args[0])
+	if err != nil {
+		return err
+	}
+	code, modified, err := otpCode(e)
+	if err != nil {
+		return err
+	}
+	if modified {
+		sh.modified = true
+	}
+	fmt.Println(formatCode(e.OTP, code))
+	return nil
+}
+
+func (sh *shell) list(args []string) error {
+	o := &listOptions{}
+	fs := newShellFlagSet("list")
+	addListFlags(fs, o)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return listEntries(os.Stdout, sh.store, fs.Args(), o)
+}
+
+func (sh *shell) move(args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: mv old_id new_id")
+	}
+	if err := moveEntry(sh.store, args[0], args[1]); err != nil {
+		return err
+	}
+	sh.modified = true
+	fmt.Printf("Renamed entry %q to %q\n", args[0], args[1])
+	return nil
+}
+
+func (sh *shell) delete(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: delete id")
+	}
+	if err := deleteEntry(sh.store, args[0]); err != nil {
+		return err
+	}
+	sh.modified = true
+	fmt.Printf("Removed entry %q from store\n", args[0])
+	return nil
+}
+
+func (sh *shell) save(args []string) error {
+	if err := saveStore(sh.store, sh.passphrase); err != nil {
+		return err
+	}
+	sh.modified = false
+	fmt.Printf("Saved changes to %q.\n", storeFile)
+	return nil
+}
+
+func (sh *shell) help(args []string) error {
+	fmt.Println("Available commands:")
+	for _, c := range shellCommands {
+		fmt.Printf("    %s\n", c.usage)
+	}
+	fmt.Println("See 'passman help shell' for details.")
+	return nil
+}
+
+// newShellFlagSet returns a flag set that reports parse errors to the caller
+// instead of printing usage information and exiting.
+func newShellFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	return fs
+}
+
+// complete completes the word before the cursor to a command name (for the
+// first word) or an entry id.
+func (sh *shell) complete(line string, pos int) (string, int, bool) {
+	start := strings.LastIndexAny(line[:pos], " \t") + 1
+	prefix := line[start:pos]
+	if strings.HasPrefix(prefix, "-") {
+		return "", 0, false
+	}
+
+	var candidates []string
+	if strings.TrimSpace(line[:start]) == "" {
+		for _, c := range shellCommands {
+			candidates = append(candidates, c.name())
+		}
+	} else {
+		candidates = sh.store.Ids(nil)
+	}
+
+	var matches []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, prefix) {
+			matches = append(matches, c)
+		}
+	}
+	if len(matches) == 0 {
+		return "", 0, false
+	}
+
+	word := commonPrefix(matches)
+	if len(matches) == 1 {
+		word += " "
+	}
+	return line[:start] + word + line[pos:], start + len(word), true
+}
+
+// commonPrefix returns the longest common prefix of the given strings.
+func commonPrefix(words []string) string {
+	sort.Strings(words)
+	first, last := words[0], words[len(words)-1]
+	i := 0
+	for i < len(first) && i < len(last) && first[i] == last[i] {
+		i++
+	}
+	return first[:i]
+}
+
+// splitLine splits a command line into words. Words are separated by
+// whitespace; single and double quotes group words and a backslash escapes
+// the next character (except within single quotes).
+func splitLine(line string) (args []string, err error) {
+	var word []rune
+	var quote rune
+	inWord, escaped := false, false
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			word = append(word, r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inWord = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word = append(word, r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t':
+			if inWord {
+				args = append(args, string(word))
+				word, inWord = word[:0], false
+			}
+		default:
+			word = append(word, r)
+			inWord = true
+		}
+	}
+
+	if quote != 0 || escaped {
+		return nil, errors.New("unterminated quote or escape")
+	}
+	if inWord {
+		args = append(args, string(word))
+	}
+	return
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"github.com/tvdburgt/passman/store"
+	"testing"
+)
+
+// Failing commands are reported to the shell, which keeps the session (and
+// its modifications) open.
+func TestShellRun(t *testing.T) {
+	s := store.NewStore()
+	s.Entries["a"] = store.NewEntry()
+	sh := &shell{store: s}
+
+	for _, line := range []string{"get missing", "mv missing b", "set -bogus a", "frobnicate", `get "a`} {
+		if err := sh.run(line); err == nil || err == errExit {
+			t.Errorf("%s: error %v", line, err)
+		}
+	}
+	if err := sh.run("mv a b"); err != nil {
+		t.Fatal(err)
+	}
+	if s.Entries["b"] == nil || !sh.modified {
+		t.Errorf("mv not applied: %v", s.Ids(nil))
+	}
+	if err := sh.run("exit"); err != errExit {
+		t.Errorf("exit: error %v", err)
+	}
+}
@@ -22,20 +22,20 @@ func init() {
 	addFileFlag(cmdStat)
 }
 
-func runStat(cmd *Command, args []string) {
+func runStat(cmd *Command, args []string) error {
 	file, err := os.Open(storeFile)
 	if err != nil {
-		fatalf("%s", err)
+		return err
 	}
 
 	h := store.Header{}
 	if err := h.Unmarshal(file); err != nil {
-		fatalf("%s", err)
+		return err
 	}
 
 	fi, err := file.Stat()
 	if err != nil {
-		fatalf("%s", err)
+		return err
 	}
 
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
@@ -47,4 +47,5 @@ func runStat(cmd *Command, args []string) {
 	fmt.Fprintf(w, "Scrypt params\t: N=%d r=%d p=%d\n",
 		1<<h.Params.LogN, h.Params.R, h.Params.P)
 	w.Flush()
+	return nil
 }
@@ -3,7 +3,9 @@ package store
 import (
 	"bytes"
 	"fmt"
+	"github.com/tvdburgt/passman/otp"
 	"reflect"
+	"strings"
 	"text/tabwriter"
 	"time"
 )
@@ -13,9 +15,19 @@ type Metadata map[string]string
 type Entry struct {
 	Name     string    `json:"name"`
 	Password []byte    `json:"password"`
-	Ctime    time.Time `json:"ctime"`    // Creation time
-	Mtime    time.Time `json:"mtime"`    // Modification time
-	Metadata Metadata  `json:"metadata"` // Map for custom fields
+	Ctime    time.Time `json:"ctime"` // Creation time
+	Mtime    time.Time `json:"mtime"` // Modification time
+
+	// Policy for generating the password (optional)
+	Policy *Policy `json:"policy,omitempty"`
+
+	// Expiry of the password (optional)
+	Expires *Expiry `json:"expires,omitempty"`
+
+	// One-time password generator (optional)
+	OTP *otp.Key `json:"otp,omitempty"`
+
+	Metadata Metadata `json:"metadata"` // Map for custom fields
 }
 
 func NewEntry() *Entry {
@@ -41,10 +53,19 @@ func (e Entry) String() string {
 	// Use reflect to print each entry with json tags
 	valof := reflect.ValueOf(e)
 	for i := 0; i < valof.NumField(); i++ {
-		switch val := valof.Field(i).Interface().(type) {
+		field := valof.Field(i)
+		tag := strings.Split(valof.Type().Field(i).Tag.Get("json"), ",")
+
+		// Skip optional fields that are not set
+		zero := reflect.Zero(field.Type()).Interface()
+		if len(tag) > 1 && tag[1] == "omitempty" &&
+			reflect.DeepEqual(field.Interface(), zero) {
+			continue
+		}
+
+		switch val := field.Interface().(type) {
 		default:
-			tag := valof.Type().Field(i).Tag.Get("json")
-			fmt.Fprintf(w, "%s\t : %s\n", tag, val)
+			fmt.Fprintf(w, "%s\t : %s\n", tag[0], val)
 		case Metadata:
 			for k, v := range val {
 				fmt.Fprintf(w, "%s\t : %s\n", k, v)
@@ -0,0 +1,18 @@
+package store
+
+import (
+	"strings"
+	"testing"
+)
+
+// The policy of an entry is printed as its rules.
+func TestEntryStringPolicy(t *testing.T) {
+	e := NewEntry()
+	e.Name = "octocat"
+	e.Policy = &Policy{Length: 16, Exclude: `a,\`, NoLookalikes: true, MinDigit: 2}
+	s := e.String()
+	if !strings.Contains(s, `policy   : length=16,exclude=a\,\\,no-lookalikes,min-digit=2`+"\n") ||
+		strings.Contains(s, "%!") {
+		t.Errorf("printed entry:\n%s", s)
+	}
+}
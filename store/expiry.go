@@ -0,0 +1,111 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Layout of expiry dates
+const dateLayout = "2006-01-02"
+
+const day = 24 * time.Hour
+
+// Expiry determines when the password of an entry has to be changed: either
+// at a fixed date, or after an interval since the last modification.
+type Expiry struct {
+	Date     time.Time     // Fixed expiry date (if Interval is zero)
+	Interval time.Duration // Rotation interval
+}
+
+// ParseExpiry parses a date ("2006-01-02", in local time) or an interval
+// (see ParseInterval).
+func ParseExpiry(s string) (*Expiry, error) {
+	if t, err := time.ParseInLocation(dateLayout, s, time.Local); err == nil {
+		return &Expiry{Date: t}, nil
+	}
+	d, err := ParseInterval(s)
+	if err != nil || d <= 0 {
+		return nil, fmt.Errorf("invalid expiry %q (expected a date like 2006-01-02 or an interval like 90d)", s)
+	}
+	return &Expiry{Interval: d}, nil
+}
+
+// ParseInterval parses a duration as accepted by time.ParseDuration, with
+// additional units for days ("d") and weeks ("w"), e.g. "90d".
+func ParseInterval(s string) (time.Duration, error) {
+	units := map[string]time.Duration{"d": day, "w": 7 * day}
+	for suffix, unit := range units {
+		if strings.HasSuffix(s, suffix) {
+			n, err := strconv.Atoi(strings.TrimSuffix(s, suffix))
+			if err != nil {
+				return 0, fmt.Errorf("invalid interval %q", s)
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid interval %q", s)
+	}
+	return d, nil
+}
+
+// At returns the expiry time of a password last modified at mtime.
+func (x *Expiry) At(mtime time.Time) time.Time {
+	if x.Interval > 0 {
+		return mtime.Add(x.Interval)
+	}
+	return x.Date
+}
+
+// String returns x in the format accepted by ParseExpiry.
+func (x *Expiry) String() string {
+	switch {
+	case x.Interval > 0 && x.Interval%day == 0:
+		return fmt.Sprintf("%dd", x.Interval/day)
+	case x.Interval > 0:
+		return x.Interval.String()
+	}
+	return x.Date.Format(dateLayout)
+}
+
+func (x *Expiry) MarshalJSON() ([]byte, error) {
+	return json.Marshal(x.String())
+}
+
+func (x *Expiry) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v, err := ParseExpiry(s)
+	if err != nil {
+		return err
+	}
+	*x = *v
+	return nil
+}
+
+// ExpiresAt returns the expiry time of the password of e. The result is
+// false if e doesn't expire.
+func (e *Entry) ExpiresAt() (time.Time, bool) {
+	if e.Expires == nil {
+		return time.Time{}, false
+	}
+	return e.Expires.At(e.Mtime), true
+}
+
+// ExpiresWithin reports whether the password of e expires within d from now.
+// Expired passwords expire within any d >= 0.
+func (e *Entry) ExpiresWithin(d time.Duration) bool {
+	t, ok := e.ExpiresAt()
+	return ok && !getCurrentTime().Add(d).Before(t)
+}
+
+// Expired reports whether the password of e has expired.
+func (e *Entry) Expired() bool {
+	return e.ExpiresWithin(0)
+}
@@ -23,6 +23,27 @@ type ScryptParams struct {
 	P    uint32 `json:"p"`     // Parallelization factor
 }
 
+// Range of the work factor of stores. New stores are created with the
+// minimum.
+const (
+	MinLogN = 14
+	MaxLogN = 30
+)
+
+// Validate returns an error if p is too weak or can't be used by scrypt.
+func (p ScryptParams) Validate() error {
+	switch {
+	case p.LogN < MinLogN || p.LogN > MaxLogN:
+		return fmt.Errorf("invalid scrypt parameters: log_n %d is not within [%d, %d]",
+			p.LogN, MinLogN, MaxLogN)
+	case p.R == 0 || p.P == 0:
+		return errors.New("invalid scrypt parameters: r and p must be positive")
+	case uint64(p.R)*uint64(p.P) >= 1<<30:
+		return errors.New("invalid scrypt parameters: r*p must be less than 2^30")
+	}
+	return nil
+}
+
 type Header struct {
 	Signature [7]byte      `json:"-"`
 	Version   byte         `json:"version"`
@@ -34,7 +55,7 @@ func NewHeader() *Header {
 	return &Header{
 		Version:   Version,
 		Signature: signature,
-		Params:    ScryptParams{14, 8, 1},
+		Params:    ScryptParams{MinLogN, 8, 1},
 	}
 }
 
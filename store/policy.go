@@ -0,0 +1,54 @@
+package store
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Policy holds the rules for generating the password of an entry. The
+// generators of package pwgen define their Policy as this type, so the store
+// doesn't depend on them.
+type Policy struct {
+	Length       int    `json:"length,omitempty"`     // Default length
+	MaxLength    int    `json:"max_length,omitempty"` // Zero means unlimited
+	Alphabet     string `json:"alphabet,omitempty"`   // Allowed characters (default all printable ASCII)
+	Exclude      string `json:"exclude,omitempty"`    // Excluded characters
+	NoLookalikes bool   `json:"no_lookalikes,omitempty"`
+	MinLower     int    `json:"min_lower,omitempty"`
+	MinUpper     int    `json:"min_upper,omitempty"`
+	MinDigit     int    `json:"min_digit,omitempty"`
+	MinSymbol    int    `json:"min_symbol,omitempty"`
+}
+
+// String returns p as a comma-separated list of rules, in the format of
+// pwgen.ParseSpec.
+func (p *Policy) String() string {
+	var fields []string
+	add := func(key string, v int) {
+		if v != 0 {
+			fields = append(fields, key+"="+strconv.Itoa(v))
+		}
+	}
+	add("length", p.Length)
+	add("max-length", p.MaxLength)
+	if p.Alphabet != "" {
+		fields = append(fields, "alphabet="+escapeValue(p.Alphabet))
+	}
+	if p.Exclude != "" {
+		fields = append(fields, "exclude="+escapeValue(p.Exclude))
+	}
+	if p.NoLookalikes {
+		fields = append(fields, "no-lookalikes")
+	}
+	add("min-lower", p.MinLower)
+	add("min-upper", p.MinUpper)
+	add("min-digit", p.MinDigit)
+	add("min-symbol", p.MinSymbol)
+	return strings.Join(fields, ",")
+}
+
+// escapeValue escapes the commas (and backslashes) in a rule value.
+func escapeValue(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	return strings.Replace(s, ",", `\,`, -1)
+}
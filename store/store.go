@@ -45,7 +45,11 @@ func NewStore() *Store {
 
 // TODO: move to list.go?
 func (s *Store) List(out io.Writer, pattern *regexp.Regexp) {
-	ids := s.ids(pattern)
+	s.ListIds(out, s.Ids(pattern))
+}
+
+// ListIds writes a table of the entries with the given ids to out.
+func (s *Store) ListIds(out io.Writer, ids []string) {
 	if len(ids) == 0 {
 		fmt.Fprintln(out, "No entries found.")
 		return
@@ -95,7 +99,7 @@ func (s *Store) String() string {
 
 	fmt.Fprintln(w, "Id:\tName:\tPassword:")
 
-	for _, id := range s.ids(nil) {
+	for _, id := range s.Ids(nil) {
 		e := s.Entries[id]
 		fmt.Fprintf(w, "%s\t%s\t%s\n",
 			id,
@@ -107,8 +111,9 @@ func (s *Store) String() string {
 	return b.String()
 }
 
-// Returns entry ids with given prefix in sorted order
-func (s *Store) ids(pattern *regexp.Regexp) []string {
+// Ids returns the entry ids matching pattern in sorted order. A nil pattern
+// matches all entries.
+func (s *Store) Ids(pattern *regexp.Regexp) []string {
 	ids := make([]string, 0, len(s.Entries))
 	for id := range s.Entries {
 		if pattern == nil || pattern.MatchString(id) {
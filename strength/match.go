@@ -0,0 +1,673 @@
+package strength
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// omnimatch returns all matches of all patterns in password.
+func omnimatch(password string, userInputs []string) []*Match {
+	dicts := rankedDictionaries(userInputs)
+	var matches []*Match
+	matches = append(matches, dictionaryMatch(password, dicts)...)
+	matches = append(matches, reverseDictionaryMatch(password, dicts)...)
+	matches = append(matches, l33tMatch(password, dicts)...)
+	matches = append(matches, spatialMatch(password)...)
+	matches = append(matches, repeatMatch(password)...)
+	matches = append(matches, sequenceMatch(password)...)
+	matches = append(matches, dateMatch(password)...)
+	return matches
+}
+
+// dictionary maps words to their rank (starting at 1).
+type dictionary map[string]int
+
+var (
+	dictsOnce sync.Once
+	dicts     map[string]dictionary
+)
+
+// rankedDictionaries returns the frequency lists and a dictionary of the
+// user inputs.
+func rankedDictionaries(userInputs []string) map[string]dictionary {
+	dictsOnce.Do(func() {
+		dicts = map[string]dictionary{
+			"passwords":    rank(strings.Fields(passwords)),
+			"english":      rank(strings.Fields(english)),
+			"surnames":     rank(strings.Fields(surnames)),
+			"female_names": rank(strings.Fields(femaleNames)),
+			"male_names":   rank(strings.Fields(maleNames)),
+		}
+	})
+	if len(userInputs) == 0 {
+		return dicts
+	}
+	var words []string
+	for _, input := range userInputs {
+		input = toLower(input)
+		words = append(words, input)
+		words = append(words, strings.FieldsFunc(input, func(r rune) bool {
+			return !('a' <= r && r <= 'z' || '0' <= r && r <= '9')
+		})...)
+	}
+	all := map[string]dictionary{"user inputs": rank(words)}
+	for name, d := range dicts {
+		all[name] = d
+	}
+	return all
+}
+
+func rank(words []string) dictionary {
+	d := make(dictionary, len(words))
+	for i, w := range words {
+		if _, ok := d[w]; !ok && w != "" {
+			d[w] = i + 1
+		}
+	}
+	return d
+}
+
+// Words longer than this are not looked up
+const maxWordLen = 32
+
+// toLower converts the ASCII letters of s to lowercase. Unlike
+// strings.ToLower, it preserves the byte offsets.
+func toLower(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+	return string(b)
+}
+
+func dictionaryMatch(password string, dicts map[string]dictionary) []*Match {
+	var matches []*Match
+	lower := toLower(password)
+	for i := 0; i < len(password); i++ {
+		for j := i; j < len(password) && j-i < maxWordLen; j++ {
+			word := lower[i : j+1]
+			for name, d := range dicts {
+				if r, ok := d[word]; ok {
+					matches = append(matches, &Match{Pattern: "dictionary",
+						I: i, J: j, Token: password[i : j+1],
+						Dictionary: name, Rank: r})
+				}
+			}
+		}
+	}
+	return matches
+}
+
+func reverse(s string) string {
+	b := make([]byte, len(s))
+	for i := range b {
+		b[i] = s[len(s)-1-i]
+	}
+	return string(b)
+}
+
+func reverseDictionaryMatch(password string, dicts map[string]dictionary) []*Match {
+	n := len(password)
+	matches := dictionaryMatch(reverse(password), dicts)
+	for _, m := range matches {
+		m.Token = reverse(m.Token)
+		m.Reversed = true
+		m.I, m.J = n-1-m.J, n-1-m.I
+	}
+	return matches
+}
+
+// l33tTable maps letters to the characters that commonly replace them.
+var l33tTable = map[byte]string{
+	'a': "4@",
+	'b': "8",
+	'c': "({[<",
+	'e': "3",
+	'g': "69",
+	'i': "1!|",
+	'l': "1|7",
+	'o': "0",
+	's': "$5",
+	't': "+7",
+	'x': "%",
+	'z': "2",
+}
+
+// Maximum number of substitution tables tried per password
+const maxL33tSubs = 64
+
+// l33tSubs returns the possible substitution tables (l33t character to
+// letter) for the l33t characters in password.
+func l33tSubs(password string) []map[byte]byte {
+	candidates := make(map[byte][]byte)
+	for letter, chars := range l33tTable {
+		for i := 0; i < len(chars); i++ {
+			if strings.IndexByte(password, chars[i]) >= 0 {
+				candidates[chars[i]] = append(candidates[chars[i]], letter)
+			}
+		}
+	}
+	var keys []byte
+	for c := range candidates {
+		keys = append(keys, c)
+		sort.Sort(byteSlice(candidates[c]))
+	}
+	sort.Sort(byteSlice(keys))
+
+	subs := []map[byte]byte{{}}
+	for _, c := range keys {
+		var next []map[byte]byte
+		for _, sub := range subs {
+			for _, letter := range candidates[c] {
+				s := map[byte]byte{c: letter}
+				for k, v := range sub {
+					s[k] = v
+				}
+				next = append(next, s)
+			}
+		}
+		if len(next) > maxL33tSubs {
+			break
+		}
+		subs = next
+	}
+	if len(subs) == 1 && len(subs[0]) == 0 {
+		return nil
+	}
+	return subs
+}
+
+type byteSlice []byte
+
+func (b byteSlice) Len() int           { return len(b) }
+func (b byteSlice) Less(i, j int) bool { return b[i] < b[j] }
+func (b byteSlice) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+
+func l33tMatch(password string, dicts map[string]dictionary) []*Match {
+	var matches []*Match
+	seen := make(map[string]bool)
+	for _, sub := range l33tSubs(password) {
+		b := []byte(password)
+		for i, c := range b {
+			if letter, ok := sub[c]; ok {
+				b[i] = letter
+			}
+		}
+		for _, m := range dictionaryMatch(string(b), dicts) {
+			token := password[m.I : m.J+1]
+			used := make(map[byte]byte)
+			for i := 0; i < len(token); i++ {
+				if letter, ok := sub[token[i]]; ok {
+					used[token[i]] = letter
+				}
+			}
+			// Skip single characters and words without substitutions
+			key := strconv.Itoa(m.I) + ":" + strconv.Itoa(m.J) + ":" + m.Dictionary
+			if len(token) < 2 || len(used) == 0 || seen[key] {
+				continue
+			}
+			seen[key] = true
+			m.Token, m.L33t, m.sub = token, true, used
+			matches = append(matches, m)
+		}
+	}
+	return matches
+}
+
+func dictionaryGuesses(m *Match) float64 {
+	g := float64(m.Rank) * uppercaseVariations(m.Token) * l33tVariations(m)
+	if m.Reversed {
+		g *= 2
+	}
+	return g
+}
+
+// uppercaseVariations returns the number of ways a word could be
+// capitalized, weighted towards common capitalizations.
+func uppercaseVariations(word string) float64 {
+	upper, lower := 0, 0
+	for i := 0; i < len(word); i++ {
+		switch c := word[i]; {
+		case 'A' <= c && c <= 'Z':
+			upper++
+		case 'a' <= c && c <= 'z':
+			lower++
+		}
+	}
+	if upper == 0 {
+		return 1
+	}
+	first := 'A' <= word[0] && word[0] <= 'Z'
+	last := 'A' <= word[len(word)-1] && word[len(word)-1] <= 'Z'
+	if lower == 0 || upper == 1 && (first || last) {
+		return 2
+	}
+	return variations(upper, lower)
+}
+
+func l33tVariations(m *Match) float64 {
+	if !m.L33t {
+		return 1
+	}
+	v := 1.0
+	token := toLower(m.Token)
+	for subbed, letter := range m.sub {
+		s := strings.Count(token, string(subbed))
+		u := strings.Count(token, string(letter))
+		if s == 0 || u == 0 {
+			v *= 2
+		} else {
+			v *= variations(s, u)
+		}
+	}
+	return v
+}
+
+// variations returns the number of ways to choose up to min(a, b) of a+b
+// positions.
+func variations(a, b int) float64 {
+	v := 0.0
+	for i := 1; i <= a && i <= b; i++ {
+		v += binomial(a+b, i)
+	}
+	return v
+}
+
+// Keyboard layout, with rows shifted by half a key. Each key lists its
+// unshifted and shifted character.
+var qwertyRows = []string{
+	"`~ 1! 2@ 3# 4$ 5% 6^ 7& 8* 9( 0) -_ =+",
+	"qQ wW eE rR tT yY uU iI oO pP [{ ]} \\|",
+	"aA sS dD fF gG hH jJ kK lL ;: '\"",
+	"zZ xX cC vV bB nN mM ,< .> /?",
+}
+
+var (
+	qwerty       = keyboardGraph(qwertyRows)
+	qwertyStarts = float64(len(qwerty))
+	qwertyDegree = averageDegree(qwerty)
+)
+
+// keyboardGraph returns for every character the keys next to it, in
+// clockwise order starting left. Missing neighbors are empty.
+func keyboardGraph(rows []string) map[byte][]string {
+	type pos struct{ x, y int }
+	keys := make(map[pos]string)
+	for y, row := range rows {
+		for i, key := range strings.Fields(row) {
+			x := i
+			if y > 0 {
+				x++ // The first letter row starts below 1
+			}
+			keys[pos{x, y}] = key
+		}
+	}
+	graph := make(map[byte][]string)
+	for p, key := range keys {
+		adjacent := []pos{{p.x - 1, p.y}, {p.x, p.y - 1}, {p.x + 1, p.y - 1},
+			{p.x + 1, p.y}, {p.x, p.y + 1}, {p.x - 1, p.y + 1}}
+		neighbors := make([]string, len(adjacent))
+		for i, a := range adjacent {
+			neighbors[i] = keys[a]
+		}
+		for i := 0; i < len(key); i++ {
+			graph[key[i]] = neighbors
+		}
+	}
+	return graph
+}
+
+func averageDegree(graph map[byte][]string) float64 {
+	total := 0
+	for _, neighbors := range graph {
+		for _, n := range neighbors {
+			if n != "" {
+				total++
+			}
+		}
+	}
+	return float64(total) / float64(len(graph))
+}
+
+// spatialMatch finds walks of at least three adjacent keys.
+func spatialMatch(password string) []*Match {
+	var matches []*Match
+	for i := 0; i < len(password)-1; {
+		j := i + 1
+		lastDirection, turns, shifted := -1, 0, 0
+		if isShifted(password[i]) {
+			shifted++
+		}
+		for ; j < len(password); j++ {
+			direction := -1
+			for d, adj := range qwerty[password[j-1]] {
+				if k := strings.IndexByte(adj, password[j]); k >= 0 {
+					direction = d
+					if k == 1 {
+						shifted++
+					}
+					break
+				}
+			}
+			if direction < 0 {
+				break
+			}
+			if direction != lastDirection {
+				turns++
+				lastDirection = direction
+			}
+		}
+		if j-i > 2 {
+			matches = append(matches, &Match{Pattern: "spatial", I: i, J: j - 1,
+				Token: password[i:j], turns: turns, shifted: shifted})
+		}
+		i = j
+	}
+	return matches
+}
+
+func isShifted(c byte) bool {
+	for _, row := range qwertyRows {
+		for _, key := range strings.Fields(row) {
+			if key[1] == c {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func spatialGuesses(m *Match) float64 {
+	g := 0.0
+	n := len(m.Token)
+	for i := 2; i <= n; i++ {
+		for j := 1; j <= m.turns && j <= i-1; j++ {
+			g += binomial(i-1, j-1) * qwertyStarts * math.Pow(qwertyDegree, float64(j))
+		}
+	}
+	if m.shifted > 0 {
+		unshifted := n - m.shifted
+		if unshifted == 0 {
+			g *= 2
+		} else {
+			g *= variations(m.shifted, unshifted)
+		}
+	}
+	return g
+}
+
+// repeatMatch finds substrings that are repeated at least twice in a row,
+// such as "aaa" or "abcabc".
+func repeatMatch(password string) []*Match {
+	var matches []*Match
+	for i := 0; i < len(password); {
+		base, count := 0, 0
+		for b := 1; i+2*b <= len(password); b++ {
+			k := 1
+			for i+(k+1)*b <= len(password) &&
+				password[i+k*b:i+(k+1)*b] == password[i:i+b] {
+				k++
+			}
+			if k > 1 && k*b > base*count {
+				base, count = b, k
+			}
+		}
+		if count == 0 {
+			i++
+			continue
+		}
+		baseToken := password[i : i+base]
+		baseGuesses, _ := mostGuessable(baseToken, omnimatch(baseToken, nil))
+		j := i + base*count
+		matches = append(matches, &Match{Pattern: "repeat", I: i, J: j - 1,
+			Token: password[i:j], baseGuesses: baseGuesses, repeatCount: count})
+		i = j
+	}
+	return matches
+}
+
+// Maximum difference between characters of a sequence
+const maxSequenceDelta = 5
+
+// sequenceMatch finds runs of characters with a constant difference, such as
+// "abcd", "9753" or "acegi".
+func sequenceMatch(password string) []*Match {
+	var matches []*Match
+	add := func(i, j, delta int) {
+		if (j-i > 1 || abs(delta) == 1) && delta != 0 && abs(delta) <= maxSequenceDelta {
+			matches = append(matches, &Match{Pattern: "sequence", I: i, J: j,
+				Token: password[i : j+1], descending: delta < 0})
+		}
+	}
+	if len(password) < 2 {
+		return nil
+	}
+	i, last := 0, int(password[1])-int(password[0])
+	for k := 2; k < len(password); k++ {
+		delta := int(password[k]) - int(password[k-1])
+		if delta == last {
+			continue
+		}
+		add(i, k-1, last)
+		i, last = k-1, delta
+	}
+	add(i, len(password)-1, last)
+	return matches
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func sequenceGuesses(m *Match) float64 {
+	var base float64
+	switch c := m.Token[0]; {
+	case strings.IndexByte("aAzZ019", c) >= 0:
+		base = 4 // Obvious starting points
+	case '0' <= c && c <= '9':
+		base = 10
+	default:
+		base = 26
+	}
+	if m.descending {
+		base *= 2
+	}
+	return base * float64(len(m.Token))
+}
+
+const (
+	minYear      = 1000
+	maxYear      = 2050
+	minYearSpace = 20
+)
+
+// Reference year for the guesses of dates
+var referenceYear = time.Now().Year()
+
+// Positions at which digit strings without separators are split into day,
+// month and year, by length.
+var dateSplits = map[int][][2]int{
+	4: {{1, 2}, {2, 3}},         // 1 1 91, 11 1 91
+	5: {{1, 3}, {2, 3}},         // 1 11 91, 11 1 91
+	6: {{1, 2}, {2, 4}, {4, 5}}, // 1 1 1991, 11 11 91, 1991 1 1
+	7: {{1, 3}, {2, 3}, {4, 5}, {4, 6}},
+	8: {{2, 4}, {4, 6}}, // 11 11 1991, 1991 11 11
+}
+
+// dateMatch finds dates with and without separators, and recent years.
+func dateMatch(password string) []*Match {
+	var matches []*Match
+	for i := 0; i < len(password); i++ {
+		for j := i + 3; j < len(password) && j-i < 10; j++ {
+			token := password[i : j+1]
+			var year int
+			var ok, sep bool
+			if isDigits(token) {
+				year, ok = dateWithoutSeparator(token)
+			} else {
+				year, ok = dateWithSeparator(token)
+				sep = true
+			}
+			if ok {
+				matches = append(matches, &Match{Pattern: "date", I: i, J: j,
+					Token: token, year: year, separator: sep})
+			}
+		}
+	}
+
+	// Remove dates that are part of a larger date
+	var result []*Match
+outer:
+	for _, m := range matches {
+		for _, other := range matches {
+			if m != other && other.I <= m.I && other.J >= m.J {
+				continue outer
+			}
+		}
+		result = append(result, m)
+	}
+
+	// Recent years on their own
+	for i := 0; i+4 <= len(password); i++ {
+		token := password[i : i+4]
+		if y, err := strconv.Atoi(token); err == nil && isDigits(token) &&
+			1900 <= y && y <= referenceYear {
+			result = append(result, &Match{Pattern: "date", I: i, J: i + 3,
+				Token: token, year: y, yearOnly: true})
+		}
+	}
+	return result
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return len(s) > 0
+}
+
+func dateWithoutSeparator(token string) (int, bool) {
+	best, found := 0, false
+	for _, split := range dateSplits[len(token)] {
+		a, _ := strconv.Atoi(token[:split[0]])
+		b, _ := strconv.Atoi(token[split[0]:split[1]])
+		c, _ := strconv.Atoi(token[split[1]:])
+		if year, ok := dmyYear(a, b, c); ok {
+			if !found || abs(year-referenceYear) < abs(best-referenceYear) {
+				best, found = year, true
+			}
+		}
+	}
+	return best, found
+}
+
+// dateWithSeparator matches dates like 1/1/91 or 1991-01-01: up to four
+// digits, a separator, one or two digits, the same separator and up to four
+// digits.
+func dateWithSeparator(token string) (int, bool) {
+	if len(token) < 6 {
+		return 0, false
+	}
+	var parts []string
+	var sep byte
+	start := 0
+	for i := 0; i < len(token); i++ {
+		c := token[i]
+		if '0' <= c && c <= '9' {
+			continue
+		}
+		if strings.IndexByte(" /\\_.-", c) < 0 || (sep != 0 && c != sep) {
+			return 0, false
+		}
+		sep = c
+		parts = append(parts, token[start:i])
+		start = i + 1
+	}
+	parts = append(parts, token[start:])
+	if len(parts) != 3 || len(parts[0]) < 1 || len(parts[0]) > 4 ||
+		len(parts[1]) < 1 || len(parts[1]) > 2 || len(parts[2]) < 1 || len(parts[2]) > 4 {
+		return 0, false
+	}
+	a, _ := strconv.Atoi(parts[0])
+	b, _ := strconv.Atoi(parts[1])
+	c, _ := strconv.Atoi(parts[2])
+	return dmyYear(a, b, c)
+}
+
+// dmyYear checks whether the integers form a date in some order and returns
+// its year.
+func dmyYear(a, b, c int) (int, bool) {
+	if b > 31 || b <= 0 {
+		return 0, false
+	}
+	over12, over31, under1 := 0, 0, 0
+	for _, v := range []int{a, b, c} {
+		if 99 < v && v < minYear || v > maxYear {
+			return 0, false
+		}
+		if v > 31 {
+			over31++
+		}
+		if v > 12 {
+			over12++
+		}
+		if v <= 0 {
+			under1++
+		}
+	}
+	if over31 >= 2 || over12 == 3 || under1 >= 2 {
+		return 0, false
+	}
+
+	// Year at the end or at the start
+	splits := [][3]int{{c, a, b}, {a, b, c}}
+	for _, s := range splits {
+		if minYear <= s[0] && s[0] <= maxYear {
+			return s[0], isDayMonth(s[1], s[2])
+		}
+	}
+	for _, s := range splits {
+		if isDayMonth(s[1], s[2]) {
+			return twoToFourDigitYear(s[0]), true
+		}
+	}
+	return 0, false
+}
+
+func isDayMonth(a, b int) bool {
+	return 1 <= a && a <= 31 && 1 <= b && b <= 12 ||
+		1 <= b && b <= 31 && 1 <= a && a <= 12
+}
+
+func twoToFourDigitYear(y int) int {
+	switch {
+	case y > 99:
+		return y
+	case y > 50:
+		return 1900 + y
+	}
+	return 2000 + y
+}
+
+func dateGuesses(m *Match) float64 {
+	space := math.Max(float64(abs(m.year-referenceYear)), minYearSpace)
+	if m.yearOnly {
+		return space
+	}
+	g := space * 365
+	if m.separator {
+		g *= 4
+	}
+	return g
+}
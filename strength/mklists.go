@@ -0,0 +1,80 @@
+//go:build ignore
+// +build ignore
+
+// mklists generates lists.go from the frequency lists of zxcvbn
+// (https://github.com/dropbox/zxcvbn, MIT license), as distributed in JSON
+// form ({"List": [...]}, most frequent first) with zxcvbn-go:
+//
+//	go run mklists.go path/to/zxcvbn-go/data/data
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Lists with the maximum number of words to include, as the tails of the
+// larger lists add little but size.
+var lists = []struct {
+	name, file string
+	max        int
+}{
+	{"passwords", "Passwords.json", 10000},
+	{"english", "English.json", 20000},
+	{"surnames", "Surnames.json", 10000},
+	{"femaleNames", "FemaleNames.json", 4000},
+	{"maleNames", "MaleNames.json", 1000},
+}
+
+func main() {
+	if len(os.Args) != 2 {
+		log.Fatal("usage: go run mklists.go datadir")
+	}
+	var b bytes.Buffer
+	fmt.Fprint(&b, "// Code generated by mklists.go; DO NOT EDIT.\n\npackage strength\n")
+	for _, l := range lists {
+		words, err := readList(filepath.Join(os.Args[1], l.file))
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(words) > l.max {
+			words = words[:l.max]
+		}
+		fmt.Fprintf(&b, "\n// %s contains %d words from %s, most frequent first.\n", l.name, len(words), l.file)
+		fmt.Fprintf(&b, "const %s = `\n%s\n`\n", l.name, strings.Join(words, "\n"))
+	}
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile("lists.go", src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func readList(name string) ([]string, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var v struct{ List []string }
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	var words []string
+	for _, w := range v.List {
+		w = strings.ToLower(strings.TrimSpace(w))
+		if w == "" || strings.ContainsAny(w, "`\n") {
+			continue
+		}
+		words = append(words, w)
+	}
+	return words, nil
+}
@@ -0,0 +1,328 @@
+// Package strength estimates the strength of passwords, following the
+// approach of zxcvbn (https://github.com/dropbox/zxcvbn).
+//
+// A password is matched against patterns that are easy to guess: words from
+// frequency lists (also reversed or with l33t substitutions), keyboard walks,
+// repeats, sequences and dates. Each match is assigned a number of guesses an
+// attacker needs to find it. The estimate is the minimum number of guesses
+// over all ways to cover the password with matches and brute-forced
+// characters in between.
+package strength
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Match is a part of a password that matches a pattern.
+type Match struct {
+	Pattern string // dictionary, spatial, repeat, sequence, date or bruteforce
+	I, J    int    // Start and end index (inclusive)
+	Token   string
+	Guesses float64
+
+	Dictionary string // Name of the word list (dictionary)
+	Rank       int    // Rank in the word list (dictionary)
+	Reversed   bool   // Matched reversed (dictionary)
+	L33t       bool   // Matched with l33t substitutions (dictionary)
+
+	sub         map[byte]byte // L33t substitutions (dictionary)
+	turns       int           // Direction changes (spatial)
+	shifted     int           // Number of shifted keys (spatial)
+	baseGuesses float64       // Guesses for the repeated string (repeat)
+	repeatCount int           // Number of repetitions (repeat)
+	descending  bool          // Sequence runs backwards (sequence)
+	year        int           // Year (date)
+	separator   bool          // Date has separators (date)
+	yearOnly    bool          // Year without day and month (date)
+}
+
+// Result is the strength estimate of a password.
+type Result struct {
+	Guesses  float64  // Estimated number of guesses
+	Score    int      // 0 (too guessable) to 4 (very unguessable)
+	Sequence []*Match // Matches that cover the password
+	Warning  string   // Explanation of a weak password, if any
+}
+
+// Score thresholds: a password scores n if it takes fewer guesses than
+// scoreGuesses[n].
+var scoreGuesses = [...]float64{1e3, 1e6, 1e8, 1e10}
+
+// Rate of guesses per second that CrackTime assumes: an offline attack on a
+// store protected by a slow key derivation function.
+const GuessesPerSecond = 1e4
+
+// Estimate returns the strength of password. Words in userInputs (such as
+// the user name or the site of an entry) are treated as very common words.
+func Estimate(password string, userInputs ...string) *Result {
+	matches := omnimatch(password, userInputs)
+	r := &Result{}
+	r.Guesses, r.Sequence = mostGuessable(password, matches)
+	r.Score = len(scoreGuesses)
+	for i, g := range scoreGuesses {
+		if r.Guesses < g+5 {
+			r.Score = i
+			break
+		}
+	}
+	r.Warning = warning(r)
+	return r
+}
+
+// CrackTime returns the time an attacker needs to find the password at
+// GuessesPerSecond.
+func (r *Result) CrackTime() time.Duration {
+	seconds := r.Guesses / GuessesPerSecond
+	if seconds > float64(math.MaxInt64)/float64(time.Second) {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// CrackTimeString returns CrackTime in words (e.g., "3 hours").
+func (r *Result) CrackTimeString() string {
+	seconds := r.Guesses / GuessesPerSecond
+	units := []struct {
+		name    string
+		seconds float64
+	}{
+		{"century", 100 * 365.25 * 86400},
+		{"year", 365.25 * 86400},
+		{"month", 30.44 * 86400},
+		{"day", 86400},
+		{"hour", 3600},
+		{"minute", 60},
+		{"second", 1},
+	}
+	if seconds < 1 {
+		return "less than a second"
+	}
+	if seconds >= 100*units[0].seconds {
+		return "centuries"
+	}
+	for _, u := range units {
+		if seconds >= u.seconds {
+			n := int(seconds / u.seconds)
+			switch {
+			case n == 1:
+				return "1 " + u.name
+			case u.name == "century":
+				return fmt.Sprintf("%d centuries", n)
+			}
+			return fmt.Sprintf("%d %ss", n, u.name)
+		}
+	}
+	panic("unreachable")
+}
+
+// String summarizes r in one line.
+func (r *Result) String() string {
+	return fmt.Sprintf("score %d/4, about %.0e guesses, cracked in %s",
+		r.Score, r.Guesses, r.CrackTimeString())
+}
+
+// warning explains the weakest part of a password with a low score.
+func warning(r *Result) string {
+	if r.Score > 2 {
+		return ""
+	}
+	if len(r.Sequence) == 0 {
+		return "The password is empty."
+	}
+	var worst *Match
+	for _, m := range r.Sequence {
+		if m.Pattern != "bruteforce" && (worst == nil || len(m.Token) > len(worst.Token)) {
+			worst = m
+		}
+	}
+	if worst == nil {
+		return "Use a longer password."
+	}
+	switch worst.Pattern {
+	case "dictionary":
+		common := worst.Dictionary == "passwords" && len(r.Sequence) == 1 &&
+			!worst.Reversed && !worst.L33t
+		switch {
+		case common && worst.Rank <= 100:
+			return "This is a top-100 common password."
+		case common:
+			return "This is a very common password."
+		case worst.Dictionary == "user inputs":
+			return "Passwords based on the name of the entry are easy to guess."
+		case worst.Reversed:
+			return "Reversed words aren't much harder to guess."
+		case worst.L33t:
+			return "Predictable substitutions like '@' instead of 'a' don't help very much."
+		case worst.Dictionary == "english":
+			return "A word by itself is easy to guess."
+		case worst.Dictionary == "passwords":
+			return "This is similar to a commonly used password."
+		}
+		return "Names and surnames are easy to guess."
+	case "spatial":
+		return "Patterns of keys on the keyboard are easy to guess."
+	case "repeat":
+		return "Repeats like \"aaa\" or \"abcabc\" are easy to guess."
+	case "sequence":
+		return "Sequences like \"abc\" or \"6543\" are easy to guess."
+	case "date":
+		return "Dates are often easy to guess."
+	}
+	return ""
+}
+
+const (
+	bruteforceCardinality           = 10
+	minGuessesBeforeGrowingSequence = 10000
+	minSubmatchGuessesSingleChar    = 10
+	minSubmatchGuessesMultiChar     = 50
+)
+
+// guesses returns the number of guesses for m, computing it if needed.
+func guesses(password string, m *Match) float64 {
+	if m.Guesses > 0 {
+		return m.Guesses
+	}
+	min := 1.0
+	if len(m.Token) < len(password) {
+		// Matches that are part of a larger password need a minimum
+		// number of guesses, as there are many ways to combine them
+		min = minSubmatchGuessesMultiChar
+		if len(m.Token) == 1 {
+			min = minSubmatchGuessesSingleChar
+		}
+	}
+	var g float64
+	switch m.Pattern {
+	case "bruteforce":
+		g = math.Pow(bruteforceCardinality, float64(len(m.Token)))
+		if len(m.Token) == 1 {
+			g = math.Max(g, minSubmatchGuessesSingleChar+1)
+		} else {
+			g = math.Max(g, minSubmatchGuessesMultiChar+1)
+		}
+	case "dictionary":
+		g = dictionaryGuesses(m)
+	case "spatial":
+		g = spatialGuesses(m)
+	case "repeat":
+		g = m.baseGuesses * float64(m.repeatCount)
+	case "sequence":
+		g = sequenceGuesses(m)
+	case "date":
+		g = dateGuesses(m)
+	}
+	m.Guesses = math.Max(g, min)
+	return m.Guesses
+}
+
+// mostGuessable finds the sequence of non-overlapping matches and
+// brute-forced substrings that covers password with the fewest guesses.
+// A sequence of l matches takes l! * prod(guesses) guesses, plus a term that
+// favors shorter sequences.
+func mostGuessable(password string, matches []*Match) (float64, []*Match) {
+	n := len(password)
+	if n == 0 {
+		return 1, nil
+	}
+	byEnd := make([][]*Match, n)
+	for _, m := range matches {
+		byEnd[m.J] = append(byEnd[m.J], m)
+	}
+
+	// For each end index k and sequence length l: the best last match,
+	// the product of guesses and the overall guesses.
+	type state struct {
+		m  *Match
+		pi float64
+		g  float64
+	}
+	optimal := make([]map[int]state, n)
+	for k := range optimal {
+		optimal[k] = make(map[int]state)
+	}
+
+	update := func(m *Match, l int) {
+		k := m.J
+		pi := guesses(password, m)
+		if l > 1 {
+			pi *= optimal[m.I-1][l-1].pi
+		}
+		g := factorial(l) * pi
+		g += math.Pow(minGuessesBeforeGrowingSequence, float64(l-1))
+		for cl, s := range optimal[k] {
+			if cl <= l && s.g <= g {
+				return
+			}
+		}
+		optimal[k][l] = state{m, pi, g}
+	}
+	bruteforce := func(i, j int) *Match {
+		return &Match{Pattern: "bruteforce", I: i, J: j, Token: password[i : j+1]}
+	}
+
+	for k := 0; k < n; k++ {
+		for _, m := range byEnd[k] {
+			if m.I > 0 {
+				for l := range optimal[m.I-1] {
+					update(m, l+1)
+				}
+			} else {
+				update(m, 1)
+			}
+		}
+
+		// Brute force the whole prefix or extend a sequence that
+		// doesn't end with brute force
+		update(bruteforce(0, k), 1)
+		for i := 1; i <= k; i++ {
+			for l, s := range optimal[i-1] {
+				if s.m.Pattern != "bruteforce" {
+					update(bruteforce(i, k), l+1)
+				}
+			}
+		}
+	}
+
+	// Unwind the optimal sequence
+	best, bestL := math.Inf(1), 0
+	for l, s := range optimal[n-1] {
+		if s.g < best || (s.g == best && l < bestL) {
+			best, bestL = s.g, l
+		}
+	}
+	var seq []*Match
+	for k, l := n-1, bestL; k >= 0 && l > 0; l-- {
+		m := optimal[k][l].m
+		seq = append([]*Match{m}, seq...)
+		k = m.I - 1
+	}
+	return best, seq
+}
+
+func factorial(n int) float64 {
+	f := 1.0
+	for i := 2; i <= n; i++ {
+		f *= float64(i)
+	}
+	return f
+}
+
+// binomial returns n choose k.
+func binomial(n, k int) float64 {
+	if k > n {
+		return 0
+	}
+	if k == 0 {
+		return 1
+	}
+	r := 1.0
+	for d := 1; d <= k; d++ {
+		r *= float64(n)
+		r /= float64(d)
+		n--
+	}
+	return r
+}
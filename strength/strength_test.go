@@ -0,0 +1,90 @@
+package strength
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEstimate(t *testing.T) {
+	tests := []struct {
+		password string
+		pattern  string // Pattern of the only match
+		score    int
+	}{
+		{"password", "dictionary", 0},
+		{"drowssap", "dictionary", 0},
+		{"P@ssw0rd", "dictionary", 0},
+		{"aaaaaaaaaaaa", "repeat", 0},
+		{"abcabcabc", "repeat", 0},
+		{"abcdefgh", "sequence", 0},
+		{"13/05/1987", "date", 1},
+		{"xcvbnm,.", "spatial", 1},
+		{"x8#Lq!2vZ@9m", "bruteforce", 4},
+	}
+	for _, test := range tests {
+		r := Estimate(test.password)
+		if r.Score != test.score {
+			t.Errorf("%q: score %d, expected %d (%s)", test.password, r.Score, test.score, r)
+		}
+		if len(r.Sequence) != 1 || r.Sequence[0].Pattern != test.pattern {
+			t.Errorf("%q: expected a single %s match, got %v", test.password, test.pattern, r.Sequence)
+		}
+		if test.score < 3 && r.Warning == "" {
+			t.Errorf("%q: no warning", test.password)
+		}
+	}
+
+	if r := Estimate(""); r.Score != 0 || r.Warning == "" {
+		t.Errorf("empty password: score %d, warning %q", r.Score, r.Warning)
+	}
+	if r := Estimate("correcthorsebatterystaple"); r.Score < 3 {
+		t.Errorf("passphrase: score %d", r.Score)
+	}
+}
+
+func TestUserInputs(t *testing.T) {
+	without, with := Estimate("tvdburgt123"), Estimate("tvdburgt123", "tvdburgt")
+	if with.Guesses >= without.Guesses {
+		t.Errorf("user input doesn't reduce guesses: %g >= %g", with.Guesses, without.Guesses)
+	}
+}
+
+func TestGuesses(t *testing.T) {
+	tests := []struct {
+		m       *Match
+		guesses float64
+	}{
+		// Sequences: obvious start (4) or digit (10), times two if
+		// descending, times the length
+		{&Match{Pattern: "sequence", Token: "abcd"}, 4 * 4},
+		{&Match{Pattern: "sequence", Token: "7654", descending: true}, 10 * 2 * 4},
+
+		// Dates: years from the reference year (at least 20) times 365
+		// days, times 4 for separators
+		{&Match{Pattern: "date", Token: "1.1.1900", year: 1900, separator: true},
+			float64(referenceYear-1900) * 365 * 4},
+		{&Match{Pattern: "date", Token: "2020", year: referenceYear, yearOnly: true}, 20},
+
+		// Capitalization and l33t substitutions
+		{&Match{Pattern: "dictionary", Token: "Word", Rank: 10}, 10 * 2},
+		{&Match{Pattern: "dictionary", Token: "WoRd", Rank: 10}, 10 * (4 + 6)},
+		{&Match{Pattern: "dictionary", Token: "p4ss", Rank: 3, L33t: true,
+			sub: map[byte]byte{'4': 'a'}}, 3 * 2},
+		{&Match{Pattern: "dictionary", Token: "drow", Rank: 5, Reversed: true}, 5 * 2},
+	}
+	for _, test := range tests {
+		if g := guesses("", test.m); math.Abs(g-test.guesses) > 1e-6 {
+			t.Errorf("%s %q: %g guesses, expected %g", test.m.Pattern, test.m.Token, g, test.guesses)
+		}
+	}
+}
+
+func TestKeyboardGraph(t *testing.T) {
+	// Neighbors of 'g': f, t, y, h, b, v
+	expected := []string{"fF", "tT", "yY", "hH", "bB", "vV"}
+	for i, n := range qwerty['G'] {
+		if n != expected[i] {
+			t.Errorf("neighbor %d of G = %q, expected %q", i, n, expected[i])
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package term
+
+import (
+	"errors"
+	"golang.org/x/crypto/ssh/terminal"
+	"io"
+	"os"
+	"sync"
+)
+
+// LineReader reads lines from the terminal with line editing, history and
+// tab completion. The terminal is only put into raw mode while a line is
+// being read, so other output and prompts behave as usual in between.
+type LineReader struct {
+	// Complete, if non-nil, is called when tab is pressed. It receives the
+	// current line and cursor position and returns the completed line and
+	// new cursor position, or ok set to false if nothing was completed.
+	Complete func(line string, pos int) (newLine string, newPos int, ok bool)
+
+	fd    int
+	t     *terminal.Terminal
+	mu    sync.Mutex
+	state *terminal.State
+}
+
+// NewLineReader returns a LineReader that reads from stdin and displays the
+// given prompt. An error is returned if stdin is not a terminal.
+func NewLineReader(prompt string) (*LineReader, error) {
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		return nil, errors.New("stdin is not a terminal")
+	}
+	lr := &LineReader{fd: fd}
+	rw := struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}
+	lr.t = terminal.NewTerminal(rw, prompt)
+	lr.t.AutoCompleteCallback = func(line string, pos int, key rune) (string, int, bool) {
+		if key != '\t' || lr.Complete == nil {
+			return "", 0, false
+		}
+		return lr.Complete(line, pos)
+	}
+	return lr, nil
+}
+
+// ReadLine reads a single line. Entered lines are added to the history.
+// io.EOF is returned when the user presses Ctrl-D or Ctrl-C.
+func (lr *LineReader) ReadLine() (string, error) {
+	state, err := terminal.MakeRaw(lr.fd)
+	if err != nil {
+		return "", err
+	}
+	lr.mu.Lock()
+	lr.state = state
+	lr.mu.Unlock()
+	defer lr.Restore()
+
+	if w, h, err := terminal.GetSize(lr.fd); err == nil && w > 0 {
+		lr.t.SetSize(w, h)
+	}
+	return lr.t.ReadLine()
+}
+
+// Restore puts the terminal back into the mode it was in before ReadLine
+// was called. It is safe to call Restore while ReadLine is blocked.
+func (lr *LineReader) Restore() {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	if lr.state != nil {
+		terminal.Restore(lr.fd, lr.state)
+		lr.state = nil
+	}
+}
@@ -0,0 +1,38 @@
+package term
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// A Prompter interacts with the user to obtain passphrases. Implementations
+// exist for the terminal (Terminal) and for pinentry programs (see package
+// pinentry).
+type Prompter interface {
+	// ReadPassphrase shows prompt and reads a passphrase.
+	ReadPassphrase(prompt string) ([]byte, error)
+
+	// Confirm asks the user a yes/no question.
+	Confirm(prompt string) (bool, error)
+}
+
+// Terminal is a Prompter that reads from the terminal attached to stdin.
+type Terminal struct{}
+
+func (Terminal) ReadPassphrase(prompt string) ([]byte, error) {
+	return ReadPassphrase("%s", prompt)
+}
+
+// Confirm prints prompt and reads an answer from stdin. An empty answer
+// counts as yes.
+func (Terminal) Confirm(prompt string) (bool, error) {
+	fmt.Printf("%s [Y/n] ", prompt)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false, err
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "" || answer == "y" || answer == "yes", nil
+}
@@ -1,20 +1,35 @@
 package term
 
 import (
+	"errors"
 	"fmt"
 	"golang.org/x/crypto/ssh/terminal"
 	"os"
 	"os/signal"
 	"strings"
+	"time"
 )
 
+// ErrNoTerminal is returned by ReadPassphrase if stdin is not a terminal.
+var ErrNoTerminal = errors.New("stdin is not a terminal")
+
+// IsTerminal reports whether stdin is a terminal.
+func IsTerminal() bool {
+	return terminal.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// IsOutputTerminal reports whether stdout is a terminal.
+func IsOutputTerminal() bool {
+	return terminal.IsTerminal(int(os.Stdout.Fd()))
+}
+
 // ReadPassphrase prints a prompt and interactively reads a passphrase from the
 // terminal. The entered passphrase is not echoed.
-func ReadPassphrase(prompt string, args ...interface{}) []byte {
+func ReadPassphrase(prompt string, args ...interface{}) ([]byte, error) {
 	fd := int(os.Stdin.Fd())
 	state, err := terminal.GetState(fd)
 	if err != nil {
-		panic("could not get state of terminal: " + err.Error())
+		return nil, ErrNoTerminal
 	}
 
 	prompt = fmt.Sprintf(prompt, args...)
@@ -36,12 +51,39 @@ func ReadPassphrase(prompt string, args ...interface{}) []byte {
 
 	phrase, err := terminal.ReadPassword(fd)
 	if err != nil {
-		panic("failed to read passphrase: " + err.Error())
+		return nil, fmt.Errorf("failed to read passphrase: %s", err)
 	}
 
-	return phrase
+	return phrase, nil
 }
 
 func clear(line string) {
 	fmt.Print("\r", strings.Repeat(" ", len(line)), "\r")
 }
+
+// WaitKey waits until a key is pressed or the timeout expires. A nonpositive
+// timeout waits indefinitely. It reports whether a key was pressed.
+func WaitKey(timeout time.Duration) (bool, error) {
+	fd := int(os.Stdin.Fd())
+	state, err := terminal.MakeRaw(fd)
+	if err != nil {
+		return false, ErrNoTerminal
+	}
+	defer terminal.Restore(fd, state)
+
+	key := make(chan error, 1)
+	go func() {
+		_, err := os.Stdin.Read(make([]byte, 16))
+		key <- err
+	}()
+	var expired <-chan time.Time
+	if timeout > 0 {
+		expired = time.After(timeout)
+	}
+	select {
+	case err := <-key:
+		return err == nil, err
+	case <-expired:
+		return false, nil
+	}
+}